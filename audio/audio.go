@@ -0,0 +1,59 @@
+// Package audio describes the format of streams of audio samples, so that the packages that
+// generate, process and encode them (sound, musical and gotracker) agree on it.
+package audio
+
+import (
+	"time"
+)
+
+// Format is the format of a stream of samples.
+type Format struct {
+	SampleRate float64 // Samples per second, per channel
+	Channels   int     // 1 for mono, 2 for stereo
+}
+
+var (
+	// CD is 44.1 kHz stereo, the default format of the sound package.
+	CD = Format{SampleRate: 44100, Channels: 2}
+
+	// DAT is 48 kHz stereo.
+	DAT = Format{SampleRate: 48000, Channels: 2}
+)
+
+// Mono returns the format with a single channel.
+func (f Format) Mono() (mono Format) {
+	f.Channels = 1
+	return f
+}
+
+// WithRate returns the format with the given sample rate.
+func (f Format) WithRate(rate float64) (g Format) {
+	f.SampleRate = rate
+	return f
+}
+
+// SampleTime returns the time between consecutive samples.
+func (f Format) SampleTime() (d time.Duration) {
+	return time.Duration(float64(time.Second) / f.SampleRate)
+}
+
+// NumSamples returns the number of samples needed to cover d, rounding up.
+func (f Format) NumSamples(d time.Duration) (n int) {
+	if d <= 0 {
+		return 0
+	}
+
+	exact := d.Seconds() * f.SampleRate
+	n = int(exact)
+
+	if float64(n) < exact {
+		n++
+	}
+
+	return n
+}
+
+// Duration returns the length of n samples.
+func (f Format) Duration(n int) (d time.Duration) {
+	return time.Duration(float64(n) / f.SampleRate * float64(time.Second))
+}
@@ -1,11 +1,17 @@
 // Package binaryimage implements binary images, as produced by assemblers and required by
 // emulators.
+//
+// An image maps addresses to bytes. It can also describe how it should be loaded: named segments
+// (code, data or BSS) with access attributes, the address to start executing at, and a table of
+// symbols. Each file format keeps as much of this as it can represent; raw binary keeps only the
+// contents; Intel HEX and Motorola S-records keep the contents without filling the gaps between
+// segments, and the entry point; TI-TXT keeps only the contents, without the gaps; and ELF keeps
+// everything. ReadAuto reads any of them, working out which from the start of the file.
 package binaryimage
 
 import (
-	"encoding/hex"
 	"fmt"
-	"github.com/kierdavis/goutil"
+	"github.com/kierdavis/go/ihex"
 	"io"
 )
 
@@ -29,13 +35,17 @@ func min(a, b uint64) (r uint64) {
 
 // Image represents a binary image.
 type Image struct {
-	data map[uint64]byte
-	max  uint64
+	data     map[uint64]byte
+	max      uint64
+	segments []Segment
+	entry    uint64
+	hasEntry bool
+	symbols  map[string]uint64
 }
 
 // New creates and returns a new Image.
 func New() (image *Image) {
-	return &Image{make(map[uint64]byte), 0}
+	return &Image{data: make(map[uint64]byte), symbols: make(map[string]uint64)}
 }
 
 // Put adds data to the image at address addr.
@@ -64,9 +74,13 @@ func (image *Image) Get(addr uint64) (data byte) {
 // number of bytes found. It may be less than len(data) if addr+len(data) is greater than
 // image.Max().
 func (image *Image) GetBytes(addr uint64, data []byte) (n uint64) {
+	if addr > image.max {
+		return 0
+	}
+
 	n = uint64(len(data))
-	if n > image.max-addr {
-		n = image.max - addr
+	if n > image.max-addr+1 {
+		n = image.max - addr + 1
 	}
 
 	for i := uint64(0); i < n; i++ {
@@ -81,6 +95,14 @@ func (image *Image) Max() (max uint64) {
 	return image.max
 }
 
+// Bytes returns the flattened image, from address 0 to image.Max(). Addresses that have never been
+// written to are zero.
+func (image *Image) Bytes() (data []byte) {
+	data = make([]byte, image.max+1)
+	image.GetBytes(0, data)
+	return data
+}
+
 // ReadRaw copies raw binary data into the image from r.
 func (image *Image) ReadRaw(r io.Reader) (err error) {
 	_, err = io.Copy(NewImageWriter(image), r)
@@ -93,101 +115,84 @@ func (image *Image) WriteRaw(w io.Writer) (err error) {
 	return err
 }
 
-// ReadIHex reads Intel HEX records from r and adds the data to the image.
-func (image *Image) ReadIHex(r io.Reader) (err error) {
-	lineChan, errChan := util.IterLines(r)
-	lineno := 0
+// WriteRawSegment writes the contents of the named segment to w as raw binary data.
+func (image *Image) WriteRawSegment(w io.Writer, name string) (err error) {
+	seg, ok := image.Segment(name)
+	if !ok {
+		return fmt.Errorf("No segment named '%s'", name)
+	}
 
-	var baseAddress uint64
+	if seg.Kind == BSS {
+		return fmt.Errorf("Segment '%s' is BSS, so has no contents", name)
+	}
 
-	for line := range lineChan {
-		lineno++
+	data := make([]byte, seg.Size)
+	for i := range data {
+		data[i] = image.Get(seg.Addr + uint64(i))
+	}
 
-		if len(line) == 0 {
-			continue
-		}
+	_, err = w.Write(data)
+	return err
+}
 
-		if line[0] != ':' {
-			return fmt.Errorf("[line %d] Invalid record start byte: expected a colon (:), found %q", lineno, line[0])
+// ReadIHex reads Intel HEX records from r and adds the data to the image. A start address record
+// (03 or 05) sets the entry point. The records are read and validated by ihex.Reader, and the
+// first problem with them is returned as an *ihex.Error.
+func (image *Image) ReadIHex(r io.Reader) (err error) {
+	rd := ihex.NewReader(r)
+
+	for {
+		rec, err := rd.Next()
+		if err == io.EOF {
+			return nil
 		}
 
-		record, err := hex.DecodeString(line[1:])
 		if err != nil {
 			return err
 		}
 
-		last := len(record) - 1
-
-		if ihexChecksum(record[:last]) != record[last] {
-			return fmt.Errorf("[line %d] Checksum mismatch", lineno)
-		}
-
-		length := record[0]
-		address := baseAddress
-		address += uint64(record[1]) << 8
-		address += uint64(record[2])
-		recordType := record[3]
-		data := record[4:last]
-
-		if len(data) != int(length) {
-			return fmt.Errorf("[line %d] Data length mismatch", lineno)
-		}
-
-		switch recordType {
-		case 0x00:
-			image.PutBytes(address, data)
-
-		case 0x01:
-			return nil
-
-		case 0x02:
-			if length != 2 {
-				return fmt.Errorf("[line %d] Expected data of length 2 for 02 record", lineno)
-			}
-
-			baseAddress = uint64(data[0]) << (8 + 4)
-			baseAddress += uint64(data[1]) << 4
-
-		case 0x04:
-			if length != 2 {
-				return fmt.Errorf("[line %d] Expected data of length 2 for 04 record", lineno)
-			}
+		switch rec.Type {
+		case ihex.Data:
+			image.PutBytes(uint64(rec.Addr), rec.Data)
 
-			baseAddress = uint64(data[0]) << (8 + 16)
-			baseAddress += uint64(data[1]) << 16
+		case ihex.StartSegmentAddress, ihex.StartLinearAddress:
+			image.SetEntry(uint64(rec.Addr))
 		}
 	}
-
-	return <-errChan
 }
 
-// WriteIHex writes the image in the form of Intel HEX records to w.
+// WriteIHex writes the image in the form of Intel HEX records to w, using ihex.Writer. Only
+// addresses that hold data are written, so gaps between segments (and BSS segments) are left out.
+// The entry point, if set, is written as a start linear address (05) record.
 func (image *Image) WriteIHex(w io.Writer) (err error) {
-	var addr, baseAddr uint64
+	if image.Max() > 0xFFFFFFFF {
+		return fmt.Errorf("Address 0x%X is too high for Intel HEX", image.Max())
+	}
+
+	wr := ihex.NewWriter(w)
+	buffer := make([]byte, 4096)
 
-	buffer := make([]byte, 16)
+	for _, run := range image.Runs() {
+		for addr := run.Addr; addr < run.Addr+run.Size; {
+			l := image.GetBytes(addr, buffer[:min(uint64(len(buffer)), run.Addr+run.Size-addr)])
 
-	for addr <= image.max {
-		thisBase := addr & 0xFFFF0000
-		if thisBase != baseAddr {
-			err = emitIHexRecord(w, 0x04, 0, []byte{byte(thisBase >> 24), byte(thisBase >> 16)})
+			err = wr.WriteData(uint(addr), buffer[:l])
 			if err != nil {
 				return err
 			}
 
-			baseAddr = thisBase
+			addr += l
 		}
+	}
 
-		l := image.GetBytes(addr, buffer)
-		err = emitIHexRecord(w, 0x00, uint16(addr&0xFFFF), buffer[:l])
+	if entry, ok := image.Entry(); ok {
+		err = wr.WriteStart(uint(entry))
 		if err != nil {
 			return err
 		}
-
-		addr += l
 	}
 
-	return emitIHexRecord(w, 0x01, 0, nil)
+	return wr.Close()
 }
 
 // ImageWriter implements io.Writer by writing data into a binary image.
@@ -246,9 +251,15 @@ func (r *ImageReader) Seek(offset uint64) {
 }
 
 // Read reads data from the image at offset, and increments the offset by the length of the data.
+// It returns io.EOF once the offset passes the end of the image.
 func (r *ImageReader) Read(data []byte) (n int, err error) {
 	l := r.image.GetBytes(r.offset, data)
 	r.offset += l
+
+	if l == 0 && len(data) > 0 {
+		return 0, io.EOF
+	}
+
 	return int(l), nil
 }
 
@@ -259,36 +270,9 @@ func ReadRaw(r io.Reader) (image *Image, err error) {
 	return image, err
 }
 
-// ReadRaw creates a new image based on the Intel HEX records from r.
+// ReadIHex creates a new image based on the Intel HEX records from r.
 func ReadIHex(r io.Reader) (image *Image, err error) {
 	image = New()
 	err = image.ReadIHex(r)
 	return image, err
 }
-
-// ihexChecksum computes the checksum of the given data, using the algorithm from Intel HEX.
-func ihexChecksum(data []byte) (sum byte) {
-	for _, b := range data {
-		sum += b
-	}
-
-	return ^sum
-}
-
-// emitIHexRecord formats an Intel HEX record using recordType, address and data and writes it to w.
-func emitIHexRecord(w io.Writer, recordType byte, address uint16, data []byte) (err error) {
-	record := make([]byte, len(data)+5)
-	record[0] = byte(len(data))
-	record[1] = byte(address >> 8)
-	record[2] = byte(address)
-	record[3] = recordType
-	copy(record[4:], data)
-	record[len(record)-1] = ihexChecksum(record[:len(record)-1])
-
-	buffer := make([]byte, hex.EncodedLen(len(record))+1)
-	buffer[0] = ':'
-	hex.Encode(buffer[1:], record)
-
-	_, err = w.Write(buffer)
-	return err
-}
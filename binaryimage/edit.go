@@ -0,0 +1,168 @@
+package binaryimage
+
+import (
+	"fmt"
+)
+
+// Has returns whether addr holds data.
+func (image *Image) Has(addr uint64) (ok bool) {
+	_, ok = image.data[addr]
+	return ok
+}
+
+// Fill sets every address from start up to (but not including) end that doesn't hold data to
+// value.
+func (image *Image) Fill(start uint64, end uint64, value byte) {
+	for addr := start; addr < end; addr++ {
+		if !image.Has(addr) {
+			image.Put(addr, value)
+		}
+	}
+}
+
+// Crop returns a new image holding only the part of the image from start up to (but not
+// including) end. Segments are clipped to the range, and symbols and the entry point are kept if
+// they lie within it. If end is not after start, the new image is empty.
+func (image *Image) Crop(start uint64, end uint64) (cropped *Image) {
+	cropped = New()
+	if end <= start {
+		return cropped
+	}
+
+	for _, run := range image.Runs() {
+		for addr := max(run.Addr, start); addr < min(run.Addr+run.Size, end); addr++ {
+			cropped.Put(addr, image.Get(addr))
+		}
+	}
+
+	for _, seg := range image.segments {
+		if seg.Addr < end && start < seg.End() {
+			clipped := seg
+			clipped.Addr = max(seg.Addr, start)
+			clipped.Size = min(seg.End(), end) - clipped.Addr
+			cropped.segments = append(cropped.segments, clipped)
+		}
+	}
+
+	for name, addr := range image.symbols {
+		if addr >= start && addr < end {
+			cropped.symbols[name] = addr
+		}
+	}
+
+	if entry, ok := image.Entry(); ok && entry >= start && entry < end {
+		cropped.SetEntry(entry)
+	}
+
+	return cropped
+}
+
+// Shift returns a new image with everything in the image, including segments, symbols and the
+// entry point, moved by delta bytes. It is an error for anything to move below address 0.
+func (image *Image) Shift(delta int64) (shifted *Image, err error) {
+	move := func(addr uint64) (moved uint64, err error) {
+		if delta < 0 && addr < uint64(-delta) {
+			return 0, fmt.Errorf("Address 0x%X would move below 0", addr)
+		}
+
+		return addr + uint64(delta), nil
+	}
+
+	shifted = New()
+
+	for addr, b := range image.data {
+		moved, err := move(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		shifted.Put(moved, b)
+	}
+
+	for _, seg := range image.segments {
+		seg.Addr, err = move(seg.Addr)
+		if err != nil {
+			return nil, err
+		}
+
+		shifted.segments = append(shifted.segments, seg)
+	}
+
+	for name, addr := range image.symbols {
+		shifted.symbols[name], err = move(addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if entry, ok := image.Entry(); ok {
+		entry, err = move(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		shifted.SetEntry(entry)
+	}
+
+	return shifted, nil
+}
+
+// Overlaps returns the ranges of addresses that hold data in both the image and other.
+func (image *Image) Overlaps(other *Image) (runs []Run) {
+	for _, run := range other.Runs() {
+		for addr := run.Addr; addr < run.Addr+run.Size; addr++ {
+			if !image.Has(addr) {
+				continue
+			}
+
+			if n := len(runs); n > 0 && runs[n-1].Addr+runs[n-1].Size == addr {
+				runs[n-1].Size++
+			} else {
+				runs = append(runs, Run{addr, 1})
+			}
+		}
+	}
+
+	return runs
+}
+
+// Merge copies the data, segments and symbols of other into the image. Where both hold data at
+// the same address, other's is kept; use Overlaps first to find out whether that will happen.
+// The image's entry point is kept if it has one, and other's is used if not. It is an error for a
+// segment of other to overlap one in the image, in which case the image is left unchanged. A
+// segment of other whose name is already taken is renamed with a number, e.g. "text.2".
+func (image *Image) Merge(other *Image) (err error) {
+	for _, seg := range other.segments {
+		for _, mine := range image.segments {
+			if seg.overlaps(mine) {
+				return fmt.Errorf("Segment '%s' (0x%X-0x%X) overlaps segment '%s' (0x%X-0x%X)",
+					seg.Name, seg.Addr, seg.End(), mine.Name, mine.Addr, mine.End())
+			}
+		}
+	}
+
+	for addr, b := range other.data {
+		image.Put(addr, b)
+	}
+
+	for _, seg := range other.segments {
+		seg.Name = image.freeSegmentName(seg.Name)
+
+		err = image.AddSegment(seg)
+		if err != nil {
+			return err
+		}
+	}
+
+	for name, addr := range other.symbols {
+		image.symbols[name] = addr
+	}
+
+	if _, ok := image.Entry(); !ok {
+		if entry, ok := other.Entry(); ok {
+			image.SetEntry(entry)
+		}
+	}
+
+	return nil
+}
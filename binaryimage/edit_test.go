@@ -0,0 +1,77 @@
+package binaryimage
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// elfImage builds an image with a text and a data segment at base, and returns what reading it
+// back from an ELF file gives.
+func elfImage(t *testing.T, base uint64, entry string) (image *Image) {
+	image = New()
+	image.PutBytes(base, []byte{1, 2, 3, 4})
+	image.PutBytes(base+0x100, []byte{5, 6})
+	image.AddSegment(NewSegment("text", Code, base, 4))
+	image.AddSegment(NewSegment("data", Data, base+0x100, 2))
+	image.AddSymbol(entry, base)
+	image.SetEntry(base)
+
+	var buf bytes.Buffer
+	if err := image.WriteELF(&buf, ELFK750); err != nil {
+		t.Fatalf("WriteELF: %s", err)
+	}
+
+	image, err := ReadELF(bytes.NewReader(buf.Bytes()), ELFK750)
+	if err != nil {
+		t.Fatalf("ReadELF: %s", err)
+	}
+
+	return image
+}
+
+func TestMergeELF(t *testing.T) {
+	image := elfImage(t, 0x0000, "boot")
+	app := elfImage(t, 0x1000, "main")
+
+	if err := image.Merge(app); err != nil {
+		t.Fatalf("Merge: %s", err)
+	}
+
+	expected := []Segment{
+		NewSegment("text", Code, 0x0000, 4),
+		NewSegment("data", Data, 0x0100, 2),
+		NewSegment("text.2", Code, 0x1000, 4),
+		NewSegment("data.2", Data, 0x1100, 2),
+	}
+
+	if segs := image.Segments(); !reflect.DeepEqual(segs, expected) {
+		t.Errorf("Segments() = %v, expected %v", segs, expected)
+	}
+
+	data := make([]byte, 2)
+	if image.GetBytes(0x1100, data); !bytes.Equal(data, []byte{5, 6}) {
+		t.Errorf("data at 0x1100 is % X, expected 05 06", data)
+	}
+
+	if addr, ok := image.Symbol("main"); !ok || addr != 0x1000 {
+		t.Errorf("Symbol(\"main\") = 0x%X, %v, expected 0x1000, true", addr, ok)
+	}
+
+	if entry, ok := image.Entry(); !ok || entry != 0 {
+		t.Errorf("Entry() = 0x%X, %v, expected the first image's entry point 0", entry, ok)
+	}
+}
+
+func TestMergeOverlap(t *testing.T) {
+	image := elfImage(t, 0x0000, "boot")
+	app := elfImage(t, 0x0002, "main")
+
+	if err := image.Merge(app); err == nil {
+		t.Fatalf("Merge of overlapping segments succeeded")
+	}
+
+	if len(image.Segments()) != 2 || image.Has(0x0005) {
+		t.Errorf("Merge changed the image despite failing")
+	}
+}
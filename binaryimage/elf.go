@@ -0,0 +1,405 @@
+package binaryimage
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ELFConfig describes the machine that ELF files are read for or written for.
+type ELFConfig struct {
+	// Machine is the e_machine number. When reading, elf.EM_NONE accepts files for any machine.
+	Machine elf.Machine
+
+	// ByteOrder is the byte order of the file's headers, which should match that of the machine.
+	// When reading, nil accepts either.
+	ByteOrder binary.ByteOrder
+}
+
+// Machine numbers for the K680 and K750. These are unofficial: neither CPU has been assigned an
+// e_machine number, so they are picked from the 0x9000 and up range used historically for
+// unassigned machines. Other ELF tools won't recognise them.
+const (
+	ELFMachineK680 elf.Machine = 0x9680
+	ELFMachineK750 elf.Machine = 0x9750
+)
+
+var (
+	// ELFK680 is the configuration for the K680, which has 32-bit words stored big-endian.
+	ELFK680 = ELFConfig{Machine: ELFMachineK680, ByteOrder: binary.BigEndian}
+
+	// ELFK750 is the configuration for the K750, which stores halfwords and words big-endian.
+	ELFK750 = ELFConfig{Machine: ELFMachineK750, ByteOrder: binary.BigEndian}
+)
+
+// ReadELF reads a 32-bit ELF file from r and adds the contents of its loadable program headers to
+// the image, at their physical addresses. Allocated sections become segments, the symbol table
+// (if there is one) becomes the image's symbols, and the entry point of an executable is kept. A
+// file for a different machine or byte order than config gives is an error.
+func (image *Image) ReadELF(r io.ReaderAt, config ELFConfig) (err error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	if f.Class != elf.ELFCLASS32 {
+		return fmt.Errorf("Expected a 32-bit ELF file, found %s", f.Class)
+	}
+
+	if config.Machine != elf.EM_NONE && f.Machine != config.Machine {
+		return fmt.Errorf("Expected an ELF file for machine 0x%X, found 0x%X", uint16(config.Machine), uint16(f.Machine))
+	}
+
+	if config.ByteOrder != nil && f.ByteOrder != config.ByteOrder {
+		return fmt.Errorf("Expected a %s ELF file, found %s", config.ByteOrder, f.ByteOrder)
+	}
+
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_LOAD || prog.Filesz == 0 {
+			continue
+		}
+
+		data := make([]byte, prog.Filesz)
+		if _, err := prog.ReadAt(data, 0); err != nil {
+			return err
+		}
+
+		image.PutBytes(prog.Paddr, data)
+	}
+
+	for _, sec := range f.Sections {
+		if sec.Flags&elf.SHF_ALLOC == 0 || sec.Size == 0 {
+			continue
+		}
+
+		seg := Segment{Name: sec.Name, Kind: Data, Attr: Read, Addr: sec.Addr, Size: sec.Size}
+
+		switch {
+		case sec.Type == elf.SHT_NOBITS:
+			seg.Kind = BSS
+		case sec.Flags&elf.SHF_EXECINSTR != 0:
+			seg.Kind = Code
+		}
+
+		if sec.Flags&elf.SHF_WRITE != 0 {
+			seg.Attr |= Write
+		}
+
+		if sec.Flags&elf.SHF_EXECINSTR != 0 {
+			seg.Attr |= Exec
+		}
+
+		if err := image.AddSegment(seg); err != nil {
+			return err
+		}
+	}
+
+	// Symbols returns an error if there is no symbol table, which isn't a problem here.
+	symbols, _ := f.Symbols()
+
+	for _, sym := range symbols {
+		typ := elf.ST_TYPE(sym.Info)
+		if sym.Name != "" && typ != elf.STT_SECTION && typ != elf.STT_FILE {
+			image.AddSymbol(sym.Name, sym.Value)
+		}
+	}
+
+	if f.Type == elf.ET_EXEC {
+		image.SetEntry(f.Entry)
+	}
+
+	return nil
+}
+
+// elfPiece is a range of addresses written as one program header, and as one section if it has a
+// name.
+type elfPiece struct {
+	seg    Segment
+	offset uint32
+}
+
+// WriteELF writes the image to w as a 32-bit ELF executable for the machine given by config. Each
+// segment gets a loadable program header and a section, and data outside of any segment gets an
+// unnamed program header of its own. Symbols are written to a symbol table.
+func (image *Image) WriteELF(w io.Writer, config ELFConfig) (err error) {
+	order := config.ByteOrder
+	if order == nil {
+		return fmt.Errorf("No byte order given for ELF file")
+	}
+
+	if image.Max() > 0xFFFFFFFF {
+		return fmt.Errorf("Address 0x%X is too high for a 32-bit ELF file", image.Max())
+	}
+
+	pieces := image.elfPieces()
+
+	headerSize := uint32(binary.Size(elf.Header32{}))
+	progSize := uint32(binary.Size(elf.Prog32{}))
+	sectSize := uint32(binary.Size(elf.Section32{}))
+	symSize := uint32(binary.Size(elf.Sym32{}))
+
+	// The file is laid out as the ELF header, program headers, segment contents, symbol table,
+	// string tables and finally section headers.
+	var body bytes.Buffer
+
+	offset := headerSize + progSize*uint32(len(pieces))
+
+	for i := range pieces {
+		piece := &pieces[i]
+		piece.offset = offset
+
+		if piece.seg.Kind != BSS {
+			data := make([]byte, piece.seg.Size)
+			image.GetBytes(piece.seg.Addr, data)
+			body.Write(data)
+			offset += uint32(len(data))
+		}
+	}
+
+	// Section 0 is always null. Named pieces are numbered from 1, followed by the tables.
+	shstrtab := newELFStrtab()
+	strtab := newELFStrtab()
+	sections := []elf.Section32{{}}
+	sectionOf := make(map[int]uint16)
+
+	for i, piece := range pieces {
+		if piece.seg.Name == "" {
+			continue
+		}
+
+		sec := elf.Section32{
+			Name:      shstrtab.add(piece.seg.Name),
+			Type:      uint32(elf.SHT_PROGBITS),
+			Flags:     uint32(elf.SHF_ALLOC),
+			Addr:      uint32(piece.seg.Addr),
+			Off:       piece.offset,
+			Size:      uint32(piece.seg.Size),
+			Addralign: 1,
+		}
+
+		if piece.seg.Kind == BSS {
+			sec.Type = uint32(elf.SHT_NOBITS)
+		}
+
+		if piece.seg.Attr&Write != 0 {
+			sec.Flags |= uint32(elf.SHF_WRITE)
+		}
+
+		if piece.seg.Attr&Exec != 0 {
+			sec.Flags |= uint32(elf.SHF_EXECINSTR)
+		}
+
+		sectionOf[i] = uint16(len(sections))
+		sections = append(sections, sec)
+	}
+
+	// The symbol table starts with a null symbol; the rest are global, and belong to the section
+	// containing their address or are absolute.
+	syms := []elf.Sym32{{}}
+
+	for _, sym := range image.Symbols() {
+		shndx := uint16(elf.SHN_ABS)
+
+		for i, piece := range pieces {
+			if n, ok := sectionOf[i]; ok && piece.seg.Contains(sym.Addr) {
+				shndx = n
+			}
+		}
+
+		syms = append(syms, elf.Sym32{
+			Name:  strtab.add(sym.Name),
+			Value: uint32(sym.Addr),
+			Info:  elf.ST_INFO(elf.STB_GLOBAL, elf.STT_NOTYPE),
+			Shndx: shndx,
+		})
+	}
+
+	pad := func() {
+		for offset%4 != 0 {
+			body.WriteByte(0)
+			offset++
+		}
+	}
+
+	pad()
+	symtabIndex := uint32(len(sections))
+	symtabOff := offset
+	binary.Write(&body, order, syms)
+	offset += symSize * uint32(len(syms))
+
+	strtabOff := offset
+	body.Write(strtab.Bytes())
+	offset += uint32(strtab.Len())
+
+	symtabName := shstrtab.add(".symtab")
+	strtabName := shstrtab.add(".strtab")
+	shstrtabName := shstrtab.add(".shstrtab")
+
+	shstrtabOff := offset
+	body.Write(shstrtab.Bytes())
+	offset += uint32(shstrtab.Len())
+
+	sections = append(sections,
+		elf.Section32{
+			Name:      symtabName,
+			Type:      uint32(elf.SHT_SYMTAB),
+			Off:       symtabOff,
+			Size:      symSize * uint32(len(syms)),
+			Link:      symtabIndex + 1,
+			Info:      1, // Index of the first global symbol
+			Addralign: 4,
+			Entsize:   symSize,
+		},
+		elf.Section32{
+			Name:      strtabName,
+			Type:      uint32(elf.SHT_STRTAB),
+			Off:       strtabOff,
+			Size:      uint32(strtab.Len()),
+			Addralign: 1,
+		},
+		elf.Section32{
+			Name:      shstrtabName,
+			Type:      uint32(elf.SHT_STRTAB),
+			Off:       shstrtabOff,
+			Size:      uint32(shstrtab.Len()),
+			Addralign: 1,
+		},
+	)
+
+	pad()
+	shoff := offset
+
+	header := elf.Header32{
+		Type:      uint16(elf.ET_EXEC),
+		Machine:   uint16(config.Machine),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     headerSize,
+		Shoff:     shoff,
+		Ehsize:    uint16(headerSize),
+		Phentsize: uint16(progSize),
+		Phnum:     uint16(len(pieces)),
+		Shentsize: uint16(sectSize),
+		Shnum:     uint16(len(sections)),
+		Shstrndx:  uint16(len(sections) - 1),
+	}
+
+	if entry, ok := image.Entry(); ok {
+		header.Entry = uint32(entry)
+	}
+
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS32)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	if order == binary.BigEndian {
+		header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2MSB)
+	} else {
+		header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	}
+
+	progs := make([]elf.Prog32, len(pieces))
+
+	for i, piece := range pieces {
+		prog := &progs[i]
+		prog.Type = uint32(elf.PT_LOAD)
+		prog.Off = piece.offset
+		prog.Vaddr = uint32(piece.seg.Addr)
+		prog.Paddr = uint32(piece.seg.Addr)
+		prog.Memsz = uint32(piece.seg.Size)
+		prog.Align = 1
+
+		if piece.seg.Kind != BSS {
+			prog.Filesz = uint32(piece.seg.Size)
+		}
+
+		if piece.seg.Attr&Read != 0 {
+			prog.Flags |= uint32(elf.PF_R)
+		}
+
+		if piece.seg.Attr&Write != 0 {
+			prog.Flags |= uint32(elf.PF_W)
+		}
+
+		if piece.seg.Attr&Exec != 0 {
+			prog.Flags |= uint32(elf.PF_X)
+		}
+	}
+
+	var out bytes.Buffer
+	binary.Write(&out, order, header)
+	binary.Write(&out, order, progs)
+	out.Write(body.Bytes())
+	binary.Write(&out, order, sections)
+
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+// elfPieces returns the image's segments, along with unnamed readable, writable and executable
+// pieces for each run of data outside of them, in order of address.
+func (image *Image) elfPieces() (pieces []elfPiece) {
+	segs := image.Segments()
+
+	for _, seg := range segs {
+		pieces = append(pieces, elfPiece{seg: seg})
+	}
+
+	for _, run := range image.Runs() {
+		addr, end := run.Addr, run.Addr+run.Size
+
+		for addr < end {
+			// Skip past any segment at addr, then take everything up to the next one.
+			if seg, ok := image.SegmentAt(addr); ok {
+				addr = seg.End()
+				continue
+			}
+
+			next := end
+			for _, seg := range segs {
+				if seg.Size > 0 && seg.Addr > addr && seg.Addr < next {
+					next = seg.Addr
+				}
+			}
+
+			pieces = append(pieces, elfPiece{seg: Segment{Kind: Data, Attr: Read | Write | Exec, Addr: addr, Size: next - addr}})
+			addr = next
+		}
+	}
+
+	sort.Slice(pieces, func(i, j int) bool {
+		return pieces[i].seg.Addr < pieces[j].seg.Addr
+	})
+
+	return pieces
+}
+
+// elfStrtab builds an ELF string table, which starts with an empty string.
+type elfStrtab struct {
+	bytes.Buffer
+}
+
+func newELFStrtab() (t *elfStrtab) {
+	t = new(elfStrtab)
+	t.WriteByte(0)
+	return t
+}
+
+// add appends s to the table and returns its offset.
+func (t *elfStrtab) add(s string) (offset uint32) {
+	offset = uint32(t.Len())
+	t.WriteString(s)
+	t.WriteByte(0)
+	return offset
+}
+
+// ReadELF creates a new image based on the ELF file from r.
+func ReadELF(r io.ReaderAt, config ELFConfig) (image *Image, err error) {
+	image = New()
+	err = image.ReadELF(r, config)
+	return image, err
+}
@@ -0,0 +1,112 @@
+package binaryimage
+
+import (
+	"bufio"
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Format is a file format that an image can be read from.
+type Format uint8
+
+const (
+	Raw Format = iota
+	IHex
+	SRec
+	TITXT
+	ELF
+)
+
+func (format Format) String() (str string) {
+	switch format {
+	case Raw:
+		return "raw"
+	case IHex:
+		return "ihex"
+	case SRec:
+		return "srec"
+	case TITXT:
+		return "titxt"
+	case ELF:
+		return "elf"
+	}
+
+	return fmt.Sprintf("Format(%d)", uint8(format))
+}
+
+// Detect guesses the format of a file from its first bytes. ELF files are recognised by their
+// magic number, and the text formats by the character that their first line starts with (: for
+// Intel HEX, S for S-records and @ for TI-TXT), provided that the line is printable text; anything
+// else is taken to be raw binary.
+func Detect(prefix []byte) (format Format) {
+	if bytes.HasPrefix(prefix, []byte(elf.ELFMAG)) {
+		return ELF
+	}
+
+	text := bytes.TrimLeft(prefix, " \t\r\n")
+	if len(text) == 0 {
+		return Raw
+	}
+
+	line := text
+	if i := bytes.IndexByte(text, '\n'); i >= 0 {
+		line = text[:i]
+	}
+
+	for _, b := range bytes.TrimRight(line, "\r") {
+		if b < ' ' || b > '~' {
+			return Raw
+		}
+	}
+
+	switch text[0] {
+	case ':':
+		return IHex
+	case 'S':
+		return SRec
+	case '@':
+		return TITXT
+	}
+
+	return Raw
+}
+
+// ReadFormat reads data in the given format from r and adds it to the image.
+func (image *Image) ReadFormat(r io.Reader, format Format) (err error) {
+	switch format {
+	case Raw:
+		return image.ReadRaw(r)
+	case IHex:
+		return image.ReadIHex(r)
+	case SRec:
+		return image.ReadSRec(r)
+	case TITXT:
+		return image.ReadTITXT(r)
+
+	case ELF:
+		// Reading ELF needs random access, and accepts a file for any machine.
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		return image.ReadELF(bytes.NewReader(data), ELFConfig{})
+	}
+
+	return fmt.Errorf("Unknown format: %s", format)
+}
+
+// ReadAuto creates a new image from r, in whichever format Detect finds.
+func ReadAuto(r io.Reader) (image *Image, err error) {
+	br := bufio.NewReader(r)
+
+	// Peek returns an error when the file is shorter than requested, which isn't a problem here.
+	prefix, _ := br.Peek(512)
+
+	image = New()
+	err = image.ReadFormat(br, Detect(prefix))
+	return image, err
+}
@@ -0,0 +1,248 @@
+package binaryimage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SegmentKind says what a segment holds.
+type SegmentKind uint8
+
+const (
+	// Code holds instructions.
+	Code SegmentKind = iota
+
+	// Data holds initialised variables.
+	Data
+
+	// BSS holds variables that start out zeroed. It occupies addresses but has no contents in the
+	// image, so it is never written out as data.
+	BSS
+)
+
+func (kind SegmentKind) String() (str string) {
+	switch kind {
+	case Code:
+		return "code"
+	case Data:
+		return "data"
+	case BSS:
+		return "bss"
+	}
+
+	return fmt.Sprintf("SegmentKind(%d)", uint8(kind))
+}
+
+// Attr is a set of permissions that a segment should be loaded with.
+type Attr uint8
+
+const (
+	Read Attr = 1 << iota
+	Write
+	Exec
+)
+
+// String returns the attributes in the style of ls, e.g. "r-x".
+func (attr Attr) String() (str string) {
+	b := []byte("---")
+
+	if attr&Read != 0 {
+		b[0] = 'r'
+	}
+
+	if attr&Write != 0 {
+		b[1] = 'w'
+	}
+
+	if attr&Exec != 0 {
+		b[2] = 'x'
+	}
+
+	return string(b)
+}
+
+// Segment is a named, contiguous region of an image.
+type Segment struct {
+	Name string
+	Kind SegmentKind
+	Attr Attr
+	Addr uint64
+	Size uint64
+}
+
+// KindForName returns the kind of segment conventionally given a name: "text" holds code, "bss"
+// is BSS and anything else holds data. A leading dot is ignored.
+func KindForName(name string) (kind SegmentKind) {
+	switch strings.TrimPrefix(name, ".") {
+	case "text":
+		return Code
+	case "bss":
+		return BSS
+	}
+
+	return Data
+}
+
+// NewSegment returns a segment with the usual attributes for its kind: code is readable and
+// executable, and data and BSS are readable and writable.
+func NewSegment(name string, kind SegmentKind, addr uint64, size uint64) (seg Segment) {
+	attr := Read | Write
+	if kind == Code {
+		attr = Read | Exec
+	}
+
+	return Segment{name, kind, attr, addr, size}
+}
+
+// End returns the address after the last byte of the segment.
+func (seg Segment) End() (end uint64) {
+	return seg.Addr + seg.Size
+}
+
+// Contains returns whether addr lies within the segment.
+func (seg Segment) Contains(addr uint64) (ok bool) {
+	return addr >= seg.Addr && addr < seg.End()
+}
+
+// overlaps returns whether the segment and other share an address. Empty segments overlap nothing.
+func (seg Segment) overlaps(other Segment) (ok bool) {
+	return seg.Size > 0 && other.Size > 0 && seg.Addr < other.End() && other.Addr < seg.End()
+}
+
+// Symbol is a name for an address in an image.
+type Symbol struct {
+	Name string
+	Addr uint64
+}
+
+// AddSegment adds seg to the image's list of segments. It is an error for it to have the same name
+// as, or to overlap, a segment that is already there. Adding a segment doesn't change the contents
+// of the image.
+func (image *Image) AddSegment(seg Segment) (err error) {
+	for _, other := range image.segments {
+		if other.Name == seg.Name {
+			return fmt.Errorf("Segment '%s' already exists", seg.Name)
+		}
+
+		if seg.overlaps(other) {
+			return fmt.Errorf("Segment '%s' (0x%X-0x%X) overlaps segment '%s' (0x%X-0x%X)",
+				seg.Name, seg.Addr, seg.End(), other.Name, other.Addr, other.End())
+		}
+	}
+
+	image.segments = append(image.segments, seg)
+
+	sort.SliceStable(image.segments, func(i, j int) bool {
+		return image.segments[i].Addr < image.segments[j].Addr
+	})
+
+	return nil
+}
+
+// freeSegmentName returns name if the image has no segment called that, or otherwise name
+// followed by the lowest number from 2 upwards that makes it unique, e.g. "text.2".
+func (image *Image) freeSegmentName(name string) (free string) {
+	free = name
+
+	for n := 2; ; n++ {
+		if _, taken := image.Segment(free); !taken {
+			return free
+		}
+
+		free = fmt.Sprintf("%s.%d", name, n)
+	}
+}
+
+// Segments returns the image's segments in order of address.
+func (image *Image) Segments() (segs []Segment) {
+	return append([]Segment(nil), image.segments...)
+}
+
+// Segment returns the segment with the given name.
+func (image *Image) Segment(name string) (seg Segment, ok bool) {
+	for _, seg := range image.segments {
+		if seg.Name == name {
+			return seg, true
+		}
+	}
+
+	return Segment{}, false
+}
+
+// SegmentAt returns the segment containing addr.
+func (image *Image) SegmentAt(addr uint64) (seg Segment, ok bool) {
+	for _, seg := range image.segments {
+		if seg.Contains(addr) {
+			return seg, true
+		}
+	}
+
+	return Segment{}, false
+}
+
+// SetEntry sets the address that execution of the image should start at.
+func (image *Image) SetEntry(addr uint64) {
+	image.entry = addr
+	image.hasEntry = true
+}
+
+// Entry returns the address that execution of the image should start at, if one has been set.
+func (image *Image) Entry() (addr uint64, ok bool) {
+	return image.entry, image.hasEntry
+}
+
+// AddSymbol names the address addr. A symbol that is added again is moved to the new address.
+func (image *Image) AddSymbol(name string, addr uint64) {
+	image.symbols[name] = addr
+}
+
+// Symbol returns the address of the named symbol.
+func (image *Image) Symbol(name string) (addr uint64, ok bool) {
+	addr, ok = image.symbols[name]
+	return addr, ok
+}
+
+// Symbols returns the image's symbols, sorted by address and then by name.
+func (image *Image) Symbols() (symbols []Symbol) {
+	for name, addr := range image.symbols {
+		symbols = append(symbols, Symbol{name, addr})
+	}
+
+	sort.Slice(symbols, func(i, j int) bool {
+		if symbols[i].Addr != symbols[j].Addr {
+			return symbols[i].Addr < symbols[j].Addr
+		}
+
+		return symbols[i].Name < symbols[j].Name
+	})
+
+	return symbols
+}
+
+// Run is a range of consecutive addresses that hold data.
+type Run struct {
+	Addr uint64
+	Size uint64
+}
+
+// Runs returns the ranges of addresses that hold data, in order. Gaps between them have never been
+// written to.
+func (image *Image) Runs() (runs []Run) {
+	addrs := make([]uint64, 0, len(image.data))
+	for addr := range image.data {
+		addrs = append(addrs, addr)
+	}
+
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+
+	for _, addr := range addrs {
+		if n := len(runs); n > 0 && runs[n-1].Addr+runs[n-1].Size == addr {
+			runs[n-1].Size++
+		} else {
+			runs = append(runs, Run{addr, 1})
+		}
+	}
+
+	return runs
+}
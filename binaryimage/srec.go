@@ -0,0 +1,187 @@
+package binaryimage
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// srecAddrLen gives the length in bytes of the address field of each S-record type.
+var srecAddrLen = [10]int{2, 2, 3, 4, 0, 2, 3, 4, 3, 2}
+
+// ReadSRec reads Motorola S-records from r and adds the data to the image. The header (S0) is
+// ignored, the record count (S5 or S6), if present, is checked against the number of data records
+// read, and the termination record (S7, S8 or S9) sets the entry point.
+func (image *Image) ReadSRec(r io.Reader) (err error) {
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+	count := uint64(0)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineno++
+
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		if len(line) < 4 || line[0] != 'S' || line[1] < '0' || line[1] > '9' || line[1] == '4' {
+			return fmt.Errorf("[line %d] Invalid record start: expected S0-S3 or S5-S9, found %q", lineno, line[:min(uint64(len(line)), 2)])
+		}
+
+		recordType := int(line[1] - '0')
+
+		record, err := hex.DecodeString(line[2:])
+		if err != nil {
+			return fmt.Errorf("[line %d] %s", lineno, err.Error())
+		}
+
+		if len(record) < 1 || int(record[0]) != len(record)-1 {
+			return fmt.Errorf("[line %d] Record length mismatch", lineno)
+		}
+
+		last := len(record) - 1
+
+		if srecChecksum(record[:last]) != record[last] {
+			return fmt.Errorf("[line %d] Checksum mismatch", lineno)
+		}
+
+		addrLen := srecAddrLen[recordType]
+		if last-1 < addrLen {
+			return fmt.Errorf("[line %d] Record too short for an S%d record", lineno, recordType)
+		}
+
+		var address uint64
+		for _, b := range record[1 : 1+addrLen] {
+			address = address<<8 | uint64(b)
+		}
+
+		data := record[1+addrLen : last]
+
+		switch recordType {
+		case 1, 2, 3:
+			image.PutBytes(address, data)
+			count++
+
+		case 5, 6:
+			if address != count {
+				return fmt.Errorf("[line %d] Record count mismatch: file says %d, found %d", lineno, address, count)
+			}
+
+		case 7, 8, 9:
+			image.SetEntry(address)
+			return nil
+		}
+	}
+
+	return scanner.Err()
+}
+
+// WriteSRec writes the image in the form of Motorola S-records to w. The smallest data record type
+// that can hold the highest address is used (S1, S2 or S3), and is followed by a record count and
+// a termination record holding the entry point, or zero if it isn't set. The header record holds
+// name, which may be empty. As with WriteIHex, only addresses that hold data are written.
+func (image *Image) WriteSRec(w io.Writer, name string) (err error) {
+	highest := image.Max()
+	if entry, ok := image.Entry(); ok {
+		highest = max(highest, entry)
+	}
+
+	var dataType int
+	switch {
+	case highest <= 0xFFFF:
+		dataType = 1
+	case highest <= 0xFFFFFF:
+		dataType = 2
+	case highest <= 0xFFFFFFFF:
+		dataType = 3
+	default:
+		return fmt.Errorf("Address 0x%X is too high for S-records", highest)
+	}
+
+	// The length of a record is held in a byte, which must also cover the address and checksum.
+	if len(name) > 0xFF-3 {
+		name = name[:0xFF-3]
+	}
+
+	err = emitSRecord(w, 0, 0, []byte(name))
+	if err != nil {
+		return err
+	}
+
+	buffer := make([]byte, 16)
+	count := uint64(0)
+
+	for _, run := range image.Runs() {
+		for addr := run.Addr; addr < run.Addr+run.Size; {
+			l := image.GetBytes(addr, buffer[:min(uint64(len(buffer)), run.Addr+run.Size-addr)])
+
+			err = emitSRecord(w, dataType, addr, buffer[:l])
+			if err != nil {
+				return err
+			}
+
+			addr += l
+			count++
+		}
+	}
+
+	// The count record is optional, and can't be written if the count doesn't fit in it.
+	switch {
+	case count <= 0xFFFF:
+		err = emitSRecord(w, 5, count, nil)
+	case count <= 0xFFFFFF:
+		err = emitSRecord(w, 6, count, nil)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	entry, _ := image.Entry()
+	return emitSRecord(w, 10-dataType, entry, nil)
+}
+
+// ReadSRec creates a new image based on the Motorola S-records from r.
+func ReadSRec(r io.Reader) (image *Image, err error) {
+	image = New()
+	err = image.ReadSRec(r)
+	return image, err
+}
+
+// srecChecksum computes the checksum of the given data, using the algorithm from Motorola
+// S-records: the one's complement of the sum of the bytes.
+func srecChecksum(data []byte) (sum byte) {
+	for _, b := range data {
+		sum += b
+	}
+
+	return ^sum
+}
+
+// emitSRecord formats an S-record using recordType, address and data and writes it to w.
+func emitSRecord(w io.Writer, recordType int, address uint64, data []byte) (err error) {
+	addrLen := srecAddrLen[recordType]
+
+	record := make([]byte, 1+addrLen+len(data)+1)
+	record[0] = byte(len(record) - 1)
+
+	for i := 0; i < addrLen; i++ {
+		record[addrLen-i] = byte(address >> (8 * uint(i)))
+	}
+
+	copy(record[1+addrLen:], data)
+	record[len(record)-1] = srecChecksum(record[:len(record)-1])
+
+	buffer := make([]byte, hex.EncodedLen(len(record))+3)
+	buffer[0] = 'S'
+	buffer[1] = '0' + byte(recordType)
+	hex.Encode(buffer[2:], record)
+	buffer[len(buffer)-1] = '\n'
+
+	_, err = w.Write([]byte(strings.ToUpper(string(buffer))))
+	return err
+}
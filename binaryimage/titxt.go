@@ -0,0 +1,105 @@
+package binaryimage
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadTITXT reads a TI-TXT file from r and adds the data to the image. The format has no
+// checksums, so only the syntax of the file is checked: each section starts with an address line
+// (@ followed by the address in hex) and is followed by lines of bytes in hex separated by spaces,
+// and the file ends with a line holding q.
+func (image *Image) ReadTITXT(r io.Reader) (err error) {
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+
+	var addr uint64
+	inSection := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineno++
+
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		switch {
+		case line[0] == '@':
+			addr, err = strconv.ParseUint(line[1:], 16, 64)
+			if err != nil {
+				return fmt.Errorf("[line %d] Invalid address: %q", lineno, line[1:])
+			}
+
+			inSection = true
+
+		case line[0] == 'q' || line[0] == 'Q':
+			return nil
+
+		case !inSection:
+			return fmt.Errorf("[line %d] Data before the first address line", lineno)
+
+		default:
+			for _, field := range strings.Fields(line) {
+				b, err := hex.DecodeString(field)
+				if err != nil || len(b) != 1 {
+					return fmt.Errorf("[line %d] Invalid byte: %q", lineno, field)
+				}
+
+				image.Put(addr, b[0])
+				addr++
+			}
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("[line %d] Unexpected end of file: expected q", lineno)
+}
+
+// WriteTITXT writes the image in the form of a TI-TXT file to w, with a section for each run of
+// addresses that hold data. The format can't represent the entry point or segments.
+func (image *Image) WriteTITXT(w io.Writer) (err error) {
+	buffer := make([]byte, 16)
+
+	for _, run := range image.Runs() {
+		_, err = fmt.Fprintf(w, "@%04X\n", run.Addr)
+		if err != nil {
+			return err
+		}
+
+		for addr := run.Addr; addr < run.Addr+run.Size; {
+			l := image.GetBytes(addr, buffer[:min(uint64(len(buffer)), run.Addr+run.Size-addr)])
+
+			fields := make([]string, l)
+			for i, b := range buffer[:l] {
+				fields[i] = fmt.Sprintf("%02X", b)
+			}
+
+			_, err = fmt.Fprintln(w, strings.Join(fields, " "))
+			if err != nil {
+				return err
+			}
+
+			addr += l
+		}
+	}
+
+	_, err = fmt.Fprintln(w, "q")
+	return err
+}
+
+// ReadTITXT creates a new image based on the TI-TXT file from r.
+func ReadTITXT(r io.Reader) (image *Image, err error) {
+	image = New()
+	err = image.ReadTITXT(r)
+	return image, err
+}
@@ -0,0 +1,441 @@
+// Command binimg converts and edits binary images, using binaryimage.
+//
+//	binimg convert [-base addr] [-to format] in out
+//	binimg merge [-overwrite] [-to format] -o out in...
+//	binimg fill [-value byte] [-start addr] [-end addr] [-to format] in out
+//	binimg crop -start addr -end addr [-to format] in out
+//	binimg offset -by n [-to format] in out
+//	binimg checksum [-algo name] [-start addr] [-end addr] [-at addr] [-little] [-to format] in [out]
+//	binimg info in
+//	binimg dump [-start addr] [-end addr] in
+//
+// Input files may be in any format that binaryimage.ReadAuto recognises; raw binary is loaded at
+// the address given by -base (0 by default). The format of an output file is given by -to, or
+// otherwise by its extension: .hex or .ihex for Intel HEX, .srec, .s19, .s28, .s37 or .mot for
+// Motorola S-records, .txt for TI-TXT, .elf for ELF and anything else for raw binary. ELF files
+// are written for the machine given by -machine (k680 or k750, the default).
+//
+// merge combines images, refusing to if any of them hold data at the same address unless
+// -overwrite is given, in which case later images win. fill sets the addresses between -start and
+// -end that hold no data to -value (0xFF by default); the range defaults to that of the data.
+// crop keeps only the data from -start up to -end, and offset moves everything in the image by -by
+// bytes, which may be negative.
+//
+// checksum computes a checksum of the data from -start up to -end (by default, all of it) and
+// prints it. The algorithm is one of crc32 (the default; IEEE), sum8, sum16 or sum32 (the sum of
+// the bytes, truncated). If -at is given, the checksum is also stored at that address, big-endian
+// unless -little is given, and the image is written to out.
+//
+// info prints a summary of an image: its data ranges, segments, entry point and symbols. dump
+// prints its contents in hex, showing addresses that hold no data as "--".
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/kierdavis/go/binaryimage"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const usage = `usage: binimg convert [-base addr] [-to format] in out
+       binimg merge [-overwrite] [-to format] -o out in...
+       binimg fill [-value byte] [-start addr] [-end addr] [-to format] in out
+       binimg crop -start addr -end addr [-to format] in out
+       binimg offset -by n [-to format] in out
+       binimg checksum [-algo name] [-start addr] [-end addr] [-at addr] [-little] [-to format] in [out]
+       binimg info in
+       binimg dump [-start addr] [-end addr] in
+`
+
+// Options shared by all subcommands.
+var (
+	base    uint64
+	to      string
+	machine string
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cmd, args := os.Args[1], os.Args[2:]
+
+	flags := flag.NewFlagSet("binimg "+cmd, flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flags.Uint64Var(&base, "base", 0, "address to load raw binary input at")
+	flags.StringVar(&to, "to", "", "output format: raw, ihex, srec, titxt or elf (default: from the output file name)")
+	flags.StringVar(&machine, "machine", "k750", "machine to write ELF files for: k680 or k750")
+
+	switch cmd {
+	case "convert":
+		flags.Parse(args)
+		needArgs(flags, 2, 2)
+		write(read(flags.Arg(0)), flags.Arg(1))
+
+	case "merge":
+		outName := flags.String("o", "", "output file")
+		overwrite := flags.Bool("overwrite", false, "let later images overwrite data from earlier ones")
+		flags.Parse(args)
+		needArgs(flags, 1, -1)
+
+		if *outName == "" {
+			die(fmt.Errorf("No output file given (-o)"))
+		}
+
+		image := binaryimage.New()
+
+		for _, name := range flags.Args() {
+			other := read(name)
+
+			if overlaps := image.Overlaps(other); len(overlaps) > 0 && !*overwrite {
+				for _, run := range overlaps {
+					fmt.Fprintf(os.Stderr, "%s: data at 0x%X-0x%X overlaps an earlier image\n", name, run.Addr, run.Addr+run.Size-1)
+				}
+
+				os.Exit(1)
+			}
+
+			die(image.Merge(other))
+		}
+
+		write(image, *outName)
+
+	case "fill":
+		value := flags.Uint("value", 0xFF, "byte to fill gaps with")
+		start := flags.Uint64("start", 0, "first address to fill (default: lowest address holding data)")
+		end := flags.Uint64("end", 0, "address after the last to fill (default: after the highest address holding data)")
+		flags.Parse(args)
+		needArgs(flags, 2, 2)
+
+		if *value > 0xFF {
+			die(fmt.Errorf("Fill value 0x%X doesn't fit in a byte", *value))
+		}
+
+		image := read(flags.Arg(0))
+		lo, hi := dataRange(image)
+		image.Fill(addrFlag(flags, "start", *start, lo), addrFlag(flags, "end", *end, hi), byte(*value))
+		write(image, flags.Arg(1))
+
+	case "crop":
+		start := flags.Uint64("start", 0, "first address to keep")
+		end := flags.Uint64("end", 0, "address after the last to keep")
+		flags.Parse(args)
+		needArgs(flags, 2, 2)
+
+		if !isSet(flags, "start") || !isSet(flags, "end") {
+			die(fmt.Errorf("crop needs both -start and -end"))
+		}
+
+		if *end <= *start {
+			die(fmt.Errorf("Empty range: 0x%X-0x%X", *start, *end))
+		}
+
+		write(read(flags.Arg(0)).Crop(*start, *end), flags.Arg(1))
+
+	case "offset":
+		by := flags.Int64("by", 0, "number of bytes to move the image by")
+		flags.Parse(args)
+		needArgs(flags, 2, 2)
+
+		image, err := read(flags.Arg(0)).Shift(*by)
+		die(err)
+		write(image, flags.Arg(1))
+
+	case "checksum":
+		algo := flags.String("algo", "crc32", "algorithm: crc32, sum8, sum16 or sum32")
+		start := flags.Uint64("start", 0, "first address to include (default: lowest address holding data)")
+		end := flags.Uint64("end", 0, "address after the last to include (default: after the highest address holding data)")
+		at := flags.Uint64("at", 0, "address to store the checksum at")
+		little := flags.Bool("little", false, "store the checksum little-endian")
+		flags.Parse(args)
+		needArgs(flags, 1, 2)
+
+		image := read(flags.Arg(0))
+		lo, hi := dataRange(image)
+		lo, hi = addrFlag(flags, "start", *start, lo), addrFlag(flags, "end", *end, hi)
+
+		if hi < lo {
+			die(fmt.Errorf("Empty range: 0x%X-0x%X", lo, hi))
+		}
+
+		data := make([]byte, hi-lo)
+		for i := range data {
+			data[i] = image.Get(lo + uint64(i))
+		}
+
+		sum, size, err := checksum(*algo, data)
+		die(err)
+		fmt.Printf("%s 0x%X-0x%X: 0x%0*X\n", *algo, lo, hi, size*2, sum)
+
+		if isSet(flags, "at") {
+			if flags.NArg() < 2 {
+				die(fmt.Errorf("No output file given for the image with the checksum inserted"))
+			}
+
+			for i := 0; i < size; i++ {
+				shift := uint(8 * (size - 1 - i))
+				if *little {
+					shift = uint(8 * i)
+				}
+
+				image.Put(*at+uint64(i), byte(sum>>shift))
+			}
+
+			write(image, flags.Arg(1))
+		}
+
+	case "info":
+		flags.Parse(args)
+		needArgs(flags, 1, 1)
+		info(os.Stdout, read(flags.Arg(0)))
+
+	case "dump":
+		start := flags.Uint64("start", 0, "first address to dump (default: lowest address holding data)")
+		end := flags.Uint64("end", 0, "address after the last to dump (default: after the highest address holding data)")
+		flags.Parse(args)
+		needArgs(flags, 1, 1)
+
+		image := read(flags.Arg(0))
+		lo, hi := dataRange(image)
+		dump(os.Stdout, image, addrFlag(flags, "start", *start, lo), addrFlag(flags, "end", *end, hi))
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", cmd)
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func die(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// needArgs exits with a usage message unless flags has between min and max positional arguments.
+// A max of -1 means there is no limit.
+func needArgs(flags *flag.FlagSet, min int, max int) {
+	if flags.NArg() < min || (max >= 0 && flags.NArg() > max) {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// isSet returns whether the named flag was given on the command line.
+func isSet(flags *flag.FlagSet, name string) (set bool) {
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+
+	return set
+}
+
+// addrFlag returns value if the named flag was given, and def otherwise.
+func addrFlag(flags *flag.FlagSet, name string, value uint64, def uint64) (addr uint64) {
+	if isSet(flags, name) {
+		return value
+	}
+
+	return def
+}
+
+// dataRange returns the lowest address holding data in image and the address after the highest,
+// or 0 and 0 if it is empty.
+func dataRange(image *binaryimage.Image) (lo uint64, hi uint64) {
+	runs := image.Runs()
+	if len(runs) == 0 {
+		return 0, 0
+	}
+
+	last := runs[len(runs)-1]
+	return runs[0].Addr, last.Addr + last.Size
+}
+
+// read reads the image in the named file, in whatever format it is in.
+func read(name string) (image *binaryimage.Image) {
+	f, err := os.Open(name)
+	die(err)
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	// Peek returns an error when the file is shorter than requested, which isn't a problem here.
+	prefix, _ := r.Peek(512)
+	format := binaryimage.Detect(prefix)
+
+	image = binaryimage.New()
+
+	if format == binaryimage.Raw {
+		w := binaryimage.NewImageWriter(image)
+		w.SetOffset(base)
+		_, err = io.Copy(w, r)
+	} else {
+		err = image.ReadFormat(r, format)
+	}
+
+	if err != nil {
+		die(fmt.Errorf("%s: %s", name, err))
+	}
+
+	return image
+}
+
+// write writes image to the named file, in the format given by -to or the file's extension.
+func write(image *binaryimage.Image, name string) {
+	format := to
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".hex", ".ihex":
+			format = "ihex"
+		case ".srec", ".s19", ".s28", ".s37", ".mot":
+			format = "srec"
+		case ".txt":
+			format = "titxt"
+		case ".elf":
+			format = "elf"
+		default:
+			format = "raw"
+		}
+	}
+
+	config := binaryimage.ELFK750
+	switch machine {
+	case "k750":
+	case "k680":
+		config = binaryimage.ELFK680
+	default:
+		die(fmt.Errorf("Unknown machine: %s", machine))
+	}
+
+	f, err := os.Create(name)
+	die(err)
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+
+	switch format {
+	case "raw":
+		err = image.WriteRaw(w)
+	case "ihex":
+		err = image.WriteIHex(w)
+	case "srec":
+		err = image.WriteSRec(w, filepath.Base(name))
+	case "titxt":
+		err = image.WriteTITXT(w)
+	case "elf":
+		err = image.WriteELF(w, config)
+	default:
+		err = fmt.Errorf("Unknown output format: %s", format)
+	}
+
+	if err == nil {
+		err = w.Flush()
+	}
+
+	if err != nil {
+		die(fmt.Errorf("%s: %s", name, err))
+	}
+}
+
+// checksum returns the checksum of data computed by the named algorithm, and its size in bytes.
+func checksum(algo string, data []byte) (sum uint32, size int, err error) {
+	switch algo {
+	case "crc32":
+		return crc32.ChecksumIEEE(data), 4, nil
+	case "sum8", "sum16", "sum32":
+		for _, b := range data {
+			sum += uint32(b)
+		}
+
+		switch algo {
+		case "sum8":
+			return sum & 0xFF, 1, nil
+		case "sum16":
+			return sum & 0xFFFF, 2, nil
+		}
+
+		return sum, 4, nil
+	}
+
+	return 0, 0, fmt.Errorf("Unknown checksum algorithm: %s", algo)
+}
+
+// info writes a summary of image to w.
+func info(w io.Writer, image *binaryimage.Image) {
+	runs := image.Runs()
+	total := uint64(0)
+
+	fmt.Fprintln(w, "Data:")
+	for _, run := range runs {
+		fmt.Fprintf(w, "    0x%08X-0x%08X  %d bytes\n", run.Addr, run.Addr+run.Size-1, run.Size)
+		total += run.Size
+	}
+
+	fmt.Fprintf(w, "    %d bytes in %d ranges\n", total, len(runs))
+
+	if segs := image.Segments(); len(segs) > 0 {
+		fmt.Fprintln(w, "Segments:")
+		for _, seg := range segs {
+			fmt.Fprintf(w, "    %-12s %-4s %s  0x%08X  %d bytes\n", seg.Name, seg.Kind, seg.Attr, seg.Addr, seg.Size)
+		}
+	}
+
+	if entry, ok := image.Entry(); ok {
+		fmt.Fprintf(w, "Entry point: 0x%08X\n", entry)
+	}
+
+	if symbols := image.Symbols(); len(symbols) > 0 {
+		fmt.Fprintln(w, "Symbols:")
+		for _, sym := range symbols {
+			fmt.Fprintf(w, "    0x%08X  %s\n", sym.Addr, sym.Name)
+		}
+	}
+}
+
+// dump writes the contents of image from start up to end to w in hex, 16 bytes to a line. Lines
+// with no data at all are left out.
+func dump(w io.Writer, image *binaryimage.Image, start uint64, end uint64) {
+	for line := start &^ 15; line < end; line += 16 {
+		fields := make([]string, 16)
+		text := make([]byte, 16)
+		empty := true
+
+		for i := range fields {
+			addr := line + uint64(i)
+			fields[i], text[i] = "  ", ' '
+
+			if addr < start || addr >= end {
+				continue
+			}
+
+			if !image.Has(addr) {
+				fields[i] = "--"
+				continue
+			}
+
+			b := image.Get(addr)
+			fields[i] = fmt.Sprintf("%02X", b)
+			empty = false
+
+			text[i] = '.'
+			if b >= ' ' && b <= '~' {
+				text[i] = b
+			}
+		}
+
+		if !empty {
+			out := fmt.Sprintf("%08X  %s  %s", line, strings.Join(fields, " "), text)
+			fmt.Fprintln(w, strings.TrimRight(out, " "))
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package cellulose
+
+import (
+    "github.com/kierdavis/go/musical"
+    "github.com/kierdavis/go/sound"
+    "time"
+)
+
+// Function SynthHandlers returns a pair of NoteHandlers that play notes on `synth`, for use as a
+// sequencer's row and column handlers. A bounce in row Y plays rowNotes[Y % len(rowNotes)] and a
+// bounce in column X plays colNotes[X % len(colNotes)]; each note is held for `gate`.
+func SynthHandlers(synth *sound.Synth, rowNotes []musical.Note, colNotes []musical.Note, gate time.Duration) (row NoteHandler, col NoteHandler) {
+    row = func(x int, y int) {
+        synth.Trigger(rowNotes[y%len(rowNotes)], 1.0, gate)
+    }
+
+    col = func(x int, y int) {
+        synth.Trigger(colNotes[x%len(colNotes)], 1.0, gate)
+    }
+
+    return row, col
+}
+
+// Function ScaleNotes returns `n` consecutive notes of `scale`, starting at its root in the given
+// octave. It is a convenient way to fill the note lists of SynthHandlers.
+func ScaleNotes(scale musical.Scale, octave int, n int) (notes []musical.Note) {
+    notes = make([]musical.Note, n)
+    for i := range notes {
+        notes[i] = scale.Get(i).Transpose(octave * 12)
+    }
+
+    return notes
+}
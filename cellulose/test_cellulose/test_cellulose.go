@@ -1,32 +1,35 @@
-// Command test_cellulose shows a demonstration of Cellulose. It uses SDL for sound output and
-// termbox for display.
+// Command test_cellulose shows a demonstration of Cellulose. It plays notes on a synthesizer,
+// through pw-play or aplay, and uses termbox for display.
 package main
 
 import (
-    "github.com/banthar/Go-SDL/mixer"
-    "github.com/banthar/Go-SDL/sdl"
+    "github.com/kierdavis/go/audio"
     "github.com/kierdavis/go/cellulose"
-    //"github.com/kierdavis/go/musical"
-    "github.com/kierdavis/go/resourcemanager"
+    "github.com/kierdavis/go/musical"
+    "github.com/kierdavis/go/sound"
     "github.com/nsf/termbox-go"
     "math/rand"
-    "path/filepath"
     "time"
 )
 
-var ResourceManager *resourcemanager.ResourceManager
-var RowSounds []*mixer.Chunk
-var ColSounds []*mixer.Chunk
-
-func playRow(x int, y int) {
-    RowSounds[y%len(RowSounds)].PlayChannel(-1, 0)
+// The notes are those of F minor, half played by bounces off the sides and half by bounces off the
+// top and bottom. Each decays over 0.3 seconds and echoes a few times.
+const NoteLength = time.Second * 3 / 10
+
+var Patch = sound.Patch{
+    Waveform: sound.SineWave,
+    Envelope: sound.Envelope{Segments: []sound.EnvelopeSegment{
+        {Level: 0.2, Time: time.Millisecond * 5},
+        {Level: 0.0, Time: NoteLength},
+    }},
+    Gain:     1.0,
 }
 
-func playCol(x int, y int) {
-    ColSounds[x%len(ColSounds)].PlayChannel(-1, 0)
-}
+var Synth = sound.NewSynth(audio.CD, Patch, 16)
 
 func runSeq() {
+    notes := cellulose.ScaleNotes(musical.NewScale(musical.ParseNote("f"), musical.Minor), 3, 18)
+    playRow, playCol := cellulose.SynthHandlers(Synth, notes[:9], notes[9:], NoteLength)
     seq := cellulose.NewSequencer(9, 9, playRow, playCol)
 
     rand.Seed(time.Now().UnixNano())
@@ -84,30 +87,14 @@ func runSeq() {
 }
 
 func main() {
-    ResourceManager = resourcemanager.NewResourceManager("github.com/kierdavis/go/amberfell/cellulose/test_cellulose")
-
-    if mixer.OpenAudio(mixer.DEFAULT_FREQUENCY, mixer.DEFAULT_FORMAT, mixer.DEFAULT_CHANNELS, 4096) != 0 {
-        panic(sdl.GetError())
-    }
-    defer mixer.CloseAudio()
-
-    soundFiles, err := filepath.Glob(ResourceManager.GetFilename("sound/out/*.wav"))
+    sink, err := sound.NewDefaultSink(sound.DefaultWAVOptions)
     if err != nil {
         panic(err)
     }
+    defer sink.Close()
 
-    RowSounds = make([]*mixer.Chunk, 0)
-    ColSounds = make([]*mixer.Chunk, 0)
-
-    l2 := len(soundFiles) / 2
-
-    for _, filename := range soundFiles[:l2] {
-        RowSounds = append(RowSounds, mixer.LoadWAV(filename))
-    }
-
-    for _, filename := range soundFiles[l2:] {
-        ColSounds = append(ColSounds, mixer.LoadWAV(filename))
-    }
+    echo := sound.NewEchoFilter(Synth.Format(), NoteLength, 0.5, 0.5)
+    go sound.PlayProcessor(sink, sound.FilterProcessor(echo, Synth))
 
     err = termbox.Init()
     if err != nil {
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// address is the location of an addressable value: disp bytes from the package-level variable
+// global, or else from the address in base plus, if indexed, index multiplied by scale.
+type address struct {
+	global  string
+	base    Register
+	index   Register
+	scale   Scale
+	indexed bool
+	disp    int64
+}
+
+func (a address) offset(n int64) (b address) {
+	a.disp += n
+	return a
+}
+
+var scales = map[int64]Scale{
+	2:  Scale2,
+	4:  Scale4,
+	8:  Scale8,
+	16: Scale16,
+}
+
+// needRegs reports an error if the n registers starting at r aren't all available.
+func (fn *Function) needRegs(pos token.Pos, r int, n int) (ok bool) {
+	if r+n > NumValueRegs {
+		fn.errorf(pos, "expression is too complex")
+		return false
+	}
+
+	return true
+}
+
+// addressable returns whether e denotes a variable, field, array element or pointer indirection.
+func (fn *Function) addressable(e ast.Expr) (ok bool) {
+	switch node := e.(type) {
+	case *ast.ParenExpr:
+		return fn.addressable(node.X)
+
+	case *ast.Ident:
+		_, ok = fn.Info.Uses[node].(*types.Var)
+		return ok
+
+	case *ast.SelectorExpr:
+		sel, ok := fn.Info.Selections[node]
+		return ok && sel.Kind() == types.FieldVal
+
+	case *ast.IndexExpr, *ast.StarExpr:
+		return true
+	}
+
+	return false
+}
+
+// address computes the address of the addressable expression e, using registers from r upwards.
+// An indexed address uses r and r+1; any other address uses at most r.
+func (fn *Function) address(e ast.Expr, r int) (a address, ok bool) {
+	switch node := e.(type) {
+	case *ast.ParenExpr:
+		return fn.address(node.X, r)
+
+	case *ast.Ident:
+		obj := fn.Info.Uses[node]
+		if obj == nil {
+			obj = fn.Info.Defs[node]
+		}
+
+		if offset, ok := fn.slots[obj]; ok {
+			return address{base: Q1, disp: int64(offset)}, true
+		}
+
+		if v, ok := obj.(*types.Var); ok {
+			if fn.unsupported(v.Type()) != "" {
+				// Already reported where the variable was declared.
+				return a, false
+			}
+
+			if fn.global(v) {
+				return address{global: v.Name()}, true
+			}
+		}
+
+	case *ast.StarExpr:
+		if !fn.needRegs(node.Pos(), r, 1) {
+			return a, false
+		}
+
+		fn.expr(node.X, r)
+		return address{base: reg(r)}, true
+
+	case *ast.SelectorExpr:
+		sel, isSel := fn.Info.Selections[node]
+		if !isSel || sel.Kind() != types.FieldVal {
+			break
+		}
+
+		a, t, ok := fn.derefAddress(node.X, r)
+		if !ok {
+			return a, false
+		}
+
+		// Embedded fields make the path to the field longer than one step.
+		for _, i := range sel.Index() {
+			if ptr, isPtr := t.Underlying().(*types.Pointer); isPtr {
+				emit("mov", reg(r), fn.memOperand(a, Word, r))
+				a = address{base: reg(r)}
+				t = ptr.Elem()
+			}
+
+			st := t.Underlying().(*types.Struct)
+			a.disp += fn.fieldOffset(st, i)
+			t = st.Field(i).Type()
+		}
+
+		return a, true
+
+	case *ast.IndexExpr:
+		a, t, ok := fn.derefAddress(node.X, r)
+		if !ok {
+			return a, false
+		}
+
+		arr, isArray := t.Underlying().(*types.Array)
+		if !isArray {
+			break
+		}
+
+		elemSize := fn.sizeOf(arr.Elem())
+
+		if index, isConst := fn.constant(node.Index); isConst {
+			return a.offset(index * elemSize), true
+		}
+
+		if !fn.needRegs(node.Pos(), r, 2) {
+			return a, false
+		}
+
+		fn.materialize(a, r)
+		fn.expr(node.Index, r+1)
+		return fn.element(reg(r+1), arr, r), true
+	}
+
+	fn.errorf(e.Pos(), "cannot take the address of this expression")
+	return a, false
+}
+
+// derefAddress returns the address of the value of e, or of the value it points to if it is a
+// pointer, along with the type of that value.
+func (fn *Function) derefAddress(e ast.Expr, r int) (a address, t types.Type, ok bool) {
+	t = fn.typeOf(e)
+
+	if ptr, isPtr := t.Underlying().(*types.Pointer); isPtr {
+		if !fn.needRegs(e.Pos(), r, 1) {
+			return a, nil, false
+		}
+
+		fn.expr(e, r)
+		return address{base: reg(r)}, ptr.Elem(), true
+	}
+
+	a, ok = fn.address(e, r)
+	return a, t, ok
+}
+
+// element returns the address of the element of arr at index, where the address of arr is in
+// register r and index is in register r+1. The index is checked against the length of the array.
+func (fn *Function) element(index Operand, arr *types.Array, r int) (a address) {
+	emit("jlt", index, Literal(0), Label("__panic"))
+	emit("jge", index, Literal(arr.Len()), Label("__panic"))
+
+	if index != reg(r+1) {
+		emit("mov", reg(r+1), index)
+	}
+
+	elemSize := fn.sizeOf(arr.Elem())
+
+	if scale, ok := scales[elemSize]; ok {
+		return address{base: reg(r), index: reg(r + 1), scale: scale, indexed: true}
+	}
+
+	fn.multiply(r+1, elemSize)
+	emit("add", reg(r), reg(r), reg(r+1))
+	return address{base: reg(r)}
+}
+
+// multiply multiplies register r by n. Register r+1 may be used as well.
+func (fn *Function) multiply(r int, n int64) {
+	if n == 0 {
+		emit("mov", reg(r), Literal(0))
+		return
+	}
+
+	top := 0
+	for n>>uint(top+1) != 0 {
+		top++
+	}
+
+	if n == 1<<uint(top) {
+		if top > 0 {
+			emit("shl", reg(r), reg(r), Literal(top))
+		}
+
+		return
+	}
+
+	if !fn.needRegs(token.NoPos, r, 2) {
+		return
+	}
+
+	// Shift and add, starting from the most significant bit of n.
+	emit("mov", reg(r+1), reg(r))
+
+	for bit := top - 1; bit >= 0; bit-- {
+		emit("shl", reg(r+1), reg(r+1), Literal(1))
+
+		if n&(1<<uint(bit)) != 0 {
+			emit("add", reg(r+1), reg(r+1), reg(r))
+		}
+	}
+
+	emit("mov", reg(r), reg(r+1))
+}
+
+// materialize computes address a into register r.
+func (fn *Function) materialize(a address, r int) {
+	switch {
+	case a.global != "":
+		emit("mov", reg(r), Label(a.global))
+
+	case a.indexed:
+		emit("shl", a.index, a.index, Literal(int(a.scale)+1))
+		emit("add", reg(r), a.base, a.index)
+
+	case a.base != reg(r):
+		emit("mov", reg(r), a.base)
+	}
+
+	if a.disp != 0 {
+		emit("add", reg(r), reg(r), Literal(a.disp))
+	}
+}
+
+// memOperand returns an operand that accesses size bytes at address a. Register r is used if the
+// address has to be computed.
+func (fn *Function) memOperand(a address, size Size, r int) (operand Operand) {
+	if a.global != "" && size == Word && a.disp == 0 {
+		return LitMemRef{Label(a.global)}
+	}
+
+	if a.global != "" || a.disp < -0x8000 || a.disp > 0x7FFF {
+		fn.materialize(a, r)
+		return MemRef{Size: size, Base: reg(r)}
+	}
+
+	if a.indexed {
+		return MemRef{Size: size, Base: a.base, Index: a.index, Scale: a.scale, Disp: Literal(a.disp), IsArray: true}
+	}
+
+	if a.disp == 0 {
+		return MemRef{Size: size, Base: a.base}
+	}
+
+	return MemRef{Size: size, Base: a.base, Disp: Literal(a.disp)}
+}
+
+// load loads the scalar of type t at address a into register r.
+func (fn *Function) load(a address, t types.Type, r int) {
+	emit("mov", reg(r), fn.memOperand(a, fn.memSize(t), r))
+	fn.extend(t, r)
+}
+
+// extend sign-extends a narrow signed integer in register r that was loaded from memory.
+func (fn *Function) extend(t types.Type, r int) {
+	if fn.narrow(t) && !fn.unsigned(t) {
+		shift := Literal(32 - 8*fn.sizeOf(t))
+		emit("shl", reg(r), reg(r), shift)
+		emit("ashr", reg(r), reg(r), shift)
+	}
+}
+
+// normalize wraps the result of arithmetic on a narrow integer in register r to its type's range.
+func (fn *Function) normalize(t types.Type, r int) {
+	if !fn.narrow(t) {
+		return
+	}
+
+	if fn.unsigned(t) {
+		emit("and", reg(r), reg(r), Literal(1<<uint(8*fn.sizeOf(t))-1))
+	} else {
+		fn.extend(t, r)
+	}
+}
+
+// copyMem copies size bytes from the address in src to the one in dst. Both registers are
+// advanced if the copy is done in a loop, which uses tmp as a counter.
+func (fn *Function) copyMem(dst, src Register, size int64, tmp Register) {
+	fn.fillMem(dst, src, size, tmp)
+}
+
+// zeroMem clears size bytes at the address in dst.
+func (fn *Function) zeroMem(dst Register, size int64, tmp Register) {
+	fn.fillMem(dst, nil, size, tmp)
+}
+
+// fillMem copies from the address in register src, or stores zeroes if src is nil.
+func (fn *Function) fillMem(dst Register, src Operand, size int64, tmp Register) {
+	at := func(base Register, size Size, offset int64) (operand Operand) {
+		if offset == 0 {
+			return MemRef{Size: size, Base: base}
+		}
+
+		return MemRef{Size: size, Base: base, Disp: Literal(offset)}
+	}
+
+	value := func(size Size, offset int64) (operand Operand) {
+		if src == nil {
+			return Literal(0)
+		}
+
+		return at(src.(Register), size, offset)
+	}
+
+	offset := int64(0)
+	words := size / 4
+
+	if words > 4 {
+		loop := fn.newLabel()
+
+		emit("mov", tmp, Literal(words))
+		emitLabel(loop)
+		emit("mov", at(dst, Word, 0), value(Word, 0))
+		emit("add", dst, dst, Literal(4))
+
+		if src != nil {
+			emit("add", src, src, Literal(4))
+		}
+
+		emit("sub", tmp, tmp, Literal(1))
+		emit("jne", tmp, Literal(0), Label(loop))
+
+	} else {
+		for ; offset < words*4; offset += 4 {
+			emit("mov", at(dst, Word, offset), value(Word, offset))
+		}
+	}
+
+	for i := int64(0); i < size%4; i++ {
+		emit("mov", at(dst, Byte, offset), value(Byte, offset))
+		offset++
+	}
+}
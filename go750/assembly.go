@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"io"
+	"strings"
+)
+
+// Assembly is generated assembly source, along with the position in the Go source that each line
+// was compiled from, so that problems found later on can be reported against the Go source.
+type Assembly struct {
+	Lines   []string
+	Pos     []token.Pos // Position of each line, or token.NoPos for code the compiler added
+	pos     token.Pos   // Position recorded for the lines written from now on
+	partial string      // Text written since the last newline
+}
+
+// SetPos sets the position recorded for the lines written from now on, and returns the previous
+// one so that it can be restored.
+func (a *Assembly) SetPos(pos token.Pos) (prev token.Pos) {
+	prev, a.pos = a.pos, pos
+	return prev
+}
+
+// Write adds text to the assembly, one line at a time.
+func (a *Assembly) Write(p []byte) (n int, err error) {
+	lines := strings.Split(a.partial+string(p), "\n")
+
+	for _, line := range lines[:len(lines)-1] {
+		a.Lines = append(a.Lines, line)
+		a.Pos = append(a.Pos, a.pos)
+	}
+
+	a.partial = lines[len(lines)-1]
+	return len(p), nil
+}
+
+// Append adds the lines of b to the end of a, keeping their positions.
+func (a *Assembly) Append(b *Assembly) {
+	a.Lines = append(a.Lines, b.Lines...)
+	a.Pos = append(a.Pos, b.Pos...)
+}
+
+// Position returns the position that line lineno (numbered from 1) was compiled from.
+func (a *Assembly) Position(lineno int) (pos token.Pos) {
+	if lineno < 1 || lineno > len(a.Pos) {
+		return token.NoPos
+	}
+
+	return a.Pos[lineno-1]
+}
+
+// Bytes returns the assembly source.
+func (a *Assembly) Bytes() (src []byte) {
+	var buf bytes.Buffer
+	a.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+func (a *Assembly) WriteTo(w io.Writer) (n int64, err error) {
+	for _, line := range a.Lines {
+		m, err := io.WriteString(w, line+"\n")
+		n += int64(m)
+
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
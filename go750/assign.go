@@ -0,0 +1,160 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+func isBlank(e ast.Expr) (ok bool) {
+	ident, ok := ast.Unparen(e).(*ast.Ident)
+	return ok && ident.Name == "_"
+}
+
+// assign stores the value of rhs in lhs, using registers from r upwards. fresh is set if lhs is a
+// variable being declared, so that a composite literal can be built in place.
+func (fn *Function) assign(lhs, rhs ast.Expr, r int, fresh bool) {
+	if isBlank(lhs) {
+		if call, ok := ast.Unparen(rhs).(*ast.CallExpr); ok {
+			fn.call(call, r)
+		} else if fn.scalar(fn.typeOf(rhs)) {
+			fn.expr(rhs, r)
+		}
+
+		return
+	}
+
+	t := fn.typeOf(lhs)
+
+	if fn.scalar(t) {
+		value := fn.operand(rhs, r)
+		if a, ok := fn.address(lhs, r+1); ok {
+			fn.store(a, t, value, r+1)
+		}
+
+		return
+	}
+
+	if !fn.needRegs(lhs.Pos(), r, 3) {
+		return
+	}
+
+	if lit, ok := ast.Unparen(rhs).(*ast.CompositeLit); ok {
+		if fresh {
+			if a, ok := fn.address(lhs, r); ok {
+				fn.compositeLit(lit, a, r, true)
+			}
+
+			return
+		}
+
+		// Build the value separately, since it may refer to the variable being assigned to.
+		tmp := fn.temp(fn.sizeOf(t))
+		fn.compositeLit(lit, tmp, r, true)
+		fn.materialize(tmp, r)
+
+	} else if src, ok := fn.address(rhs, r); ok {
+		fn.materialize(src, r)
+
+	} else {
+		return
+	}
+
+	if dst, ok := fn.address(lhs, r+1); ok {
+		fn.materialize(dst, r+1)
+		fn.copyMem(reg(r+1), reg(r), fn.sizeOf(t), reg(r+2))
+	}
+}
+
+// zero sets the variable v, which has just been declared, to its zero value.
+func (fn *Function) zero(v ast.Expr, r int) {
+	a, ok := fn.address(v, r)
+	if !ok {
+		return
+	}
+
+	t := fn.typeOf(v)
+
+	if fn.scalar(t) {
+		fn.store(a, t, Literal(0), r)
+	} else {
+		fn.materialize(a, r)
+		fn.zeroMem(reg(r), fn.sizeOf(t), reg(r+1))
+	}
+}
+
+// store stores value, a scalar of type t, at address a.
+func (fn *Function) store(a address, t types.Type, value Operand, r int) {
+	dest := fn.memOperand(a, fn.memSize(t), r)
+
+	if dest != value {
+		emit("mov", dest, value)
+	}
+}
+
+// compositeLit builds the value of lit at address a, which must not depend on any registers. If
+// clear is set the space is cleared first, so that the elements that aren't given are zero.
+func (fn *Function) compositeLit(lit *ast.CompositeLit, a address, r int, clear bool) {
+	t := fn.typeOf(lit).Underlying()
+
+	if clear {
+		fn.materialize(a, r)
+		fn.zeroMem(reg(r), fn.sizeOf(t), reg(r+1))
+	}
+
+	switch t := t.(type) {
+	case *types.Array:
+		elemSize := fn.sizeOf(t.Elem())
+		index := int64(0)
+
+		for _, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				index, _ = fn.constant(kv.Key)
+				elt = kv.Value
+			}
+
+			fn.storeValue(a.offset(index*elemSize), t.Elem(), elt, r)
+			index++
+		}
+
+	case *types.Struct:
+		for i, elt := range lit.Elts {
+			field := i
+
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				name := kv.Key.(*ast.Ident).Name
+				elt = kv.Value
+
+				for j := 0; j < t.NumFields(); j++ {
+					if t.Field(j).Name() == name {
+						field = j
+					}
+				}
+			}
+
+			fn.storeValue(a.offset(fn.fieldOffset(t, field)), t.Field(field).Type(), elt, r)
+		}
+
+	default:
+		fn.errorf(lit.Pos(), "%s", fn.unsupported(t))
+	}
+}
+
+// storeValue stores the value of e, of type t, at address a, which must not depend on any
+// registers.
+func (fn *Function) storeValue(a address, t types.Type, e ast.Expr, r int) {
+	if lit, ok := ast.Unparen(e).(*ast.CompositeLit); ok {
+		fn.compositeLit(lit, a, r, false)
+		return
+	}
+
+	if fn.scalar(t) {
+		fn.store(a, t, fn.operand(e, r), r+1)
+		return
+	}
+
+	if src, ok := fn.address(e, r); ok {
+		fn.materialize(src, r)
+		fn.materialize(a, r+1)
+		fn.copyMem(reg(r+1), reg(r), fn.sizeOf(t), reg(r+2))
+	}
+}
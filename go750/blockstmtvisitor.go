@@ -1,27 +1,430 @@
 package main
 
 import (
-	"fmt"
 	"go/ast"
+	"go/token"
+	"go/types"
 )
 
+// BlockStmtVisitor compiles the statements of a block.
 type BlockStmtVisitor struct {
+	fn *Function
 }
 
-func NewBlockStmtVisitor() (v *BlockStmtVisitor) {
-	return &BlockStmtVisitor{}
+func NewBlockStmtVisitor(fn *Function) (v *BlockStmtVisitor) {
+	return &BlockStmtVisitor{fn}
 }
 
 func (v *BlockStmtVisitor) Visit(inode ast.Node) (w ast.Visitor) {
-	fmt.Printf("BlockStmtVisitor: %#v\n", inode)
-
 	if inode == nil {
 
 	} else {
-		//switch node := inode.(type) {
+		switch node := inode.(type) {
+		case *ast.BlockStmt:
+			return NewBlockStmtVisitor(v.fn)
 
-		//}
+		case ast.Stmt:
+			v.stmt(node)
+		}
 	}
 
 	return nil
 }
+
+// walkList compiles a list of statements, such as the body of a case clause.
+func (v *BlockStmtVisitor) walkList(list []ast.Stmt) {
+	for _, stmt := range list {
+		ast.Walk(v, stmt)
+	}
+}
+
+func (v *BlockStmtVisitor) stmt(istmt ast.Stmt) {
+	fn := v.fn
+
+	prev := Output.SetPos(istmt.Pos())
+	defer Output.SetPos(prev)
+
+	switch stmt := istmt.(type) {
+	case *ast.EmptyStmt:
+
+	case *ast.DeclStmt:
+		decl := stmt.Decl.(*ast.GenDecl)
+		if decl.Tok == token.TYPE {
+			fn.typeDecl(decl)
+		}
+
+		if decl.Tok != token.VAR {
+			return
+		}
+
+		for _, ispec := range decl.Specs {
+			spec := ispec.(*ast.ValueSpec)
+			if spec.Values != nil && len(spec.Values) != len(spec.Names) {
+				fn.errorf(spec.Pos(), "multiple-value expressions are not supported")
+				continue
+			}
+
+			for i, name := range spec.Names {
+				if spec.Values == nil {
+					fn.zero(name, 0)
+				} else {
+					fn.assign(name, spec.Values[i], 0, true)
+				}
+			}
+		}
+
+	case *ast.AssignStmt:
+		v.assign(stmt)
+
+	case *ast.IncDecStmt:
+		name := "add"
+		if stmt.Tok == token.DEC {
+			name = "sub"
+		}
+
+		if a, ok := fn.address(stmt.X, 0); ok {
+			dest := fn.memOperand(a, fn.memSize(fn.typeOf(stmt.X)), 0)
+			emit(name, dest, dest, Literal(1))
+		}
+
+	case *ast.ExprStmt:
+		if call, ok := ast.Unparen(stmt.X).(*ast.CallExpr); ok {
+			fn.call(call, 0)
+		} else {
+			fn.expr(stmt.X, 0)
+		}
+
+	case *ast.ReturnStmt:
+		if len(stmt.Results) == 1 {
+			fn.expr(stmt.Results[0], 0)
+
+		} else if fn.Sig.Results().Len() == 1 {
+			result := fn.Sig.Results().At(0)
+			fn.load(address{base: Q1, disp: int64(fn.slots[result])}, result.Type(), 0)
+		}
+
+		fn.emitEpilogue()
+
+	case *ast.IfStmt:
+		if stmt.Init != nil {
+			v.stmt(stmt.Init)
+		}
+
+		elseLabel := fn.newLabel()
+		fn.cond(stmt.Cond, 0, elseLabel, false)
+		ast.Walk(v, stmt.Body)
+
+		if stmt.Else == nil {
+			emitLabel(elseLabel)
+
+		} else {
+			end := fn.newLabel()
+			emit("jmp", Label(end))
+			emitLabel(elseLabel)
+			ast.Walk(v, stmt.Else)
+			emitLabel(end)
+		}
+
+	case *ast.ForStmt:
+		if stmt.Init != nil {
+			v.stmt(stmt.Init)
+		}
+
+		top := fn.newLabel()
+		t := &target{brk: fn.newLabel(), cont: fn.newLabel()}
+
+		emitLabel(top)
+		if stmt.Cond != nil {
+			fn.cond(stmt.Cond, 0, t.brk, false)
+		}
+
+		fn.pushTarget(t)
+		ast.Walk(v, stmt.Body)
+		fn.popTarget()
+
+		emitLabel(t.cont)
+		if stmt.Post != nil {
+			v.stmt(stmt.Post)
+		}
+
+		emit("jmp", Label(top))
+		emitLabel(t.brk)
+
+	case *ast.SwitchStmt:
+		v.switchStmt(stmt)
+
+	case *ast.LabeledStmt:
+		fn.label = stmt.Label.Name
+		v.stmt(stmt.Stmt)
+		fn.label = ""
+
+	case *ast.BranchStmt:
+		v.branch(stmt)
+
+	case *ast.BlockStmt:
+		ast.Walk(v, stmt)
+
+	case *ast.GoStmt:
+		fn.errorf(stmt.Pos(), "go statements are not supported")
+
+	case *ast.DeferStmt:
+		fn.errorf(stmt.Pos(), "defer statements are not supported")
+
+	case *ast.RangeStmt:
+		v.rangeStmt(stmt)
+
+	default:
+		fn.errorf(stmt.Pos(), "statement is not supported")
+	}
+}
+
+func (v *BlockStmtVisitor) assign(stmt *ast.AssignStmt) {
+	fn := v.fn
+
+	switch stmt.Tok {
+	case token.ASSIGN, token.DEFINE:
+		if len(stmt.Lhs) != len(stmt.Rhs) {
+			fn.errorf(stmt.Pos(), "multiple-value expressions are not supported")
+			return
+		}
+
+		if len(stmt.Lhs) == 1 {
+			lhs := stmt.Lhs[0]
+			fresh := false
+			if ident, ok := lhs.(*ast.Ident); ok && stmt.Tok == token.DEFINE {
+				fresh = fn.Info.Defs[ident] != nil
+			}
+
+			fn.assign(lhs, stmt.Rhs[0], 0, fresh)
+			return
+		}
+
+		// Evaluate every right-hand side before assigning any of them, so that a, b = b, a works.
+		for i, rhs := range stmt.Rhs {
+			if !fn.scalar(fn.typeOf(rhs)) {
+				fn.errorf(rhs.Pos(), "arrays and structs can't be assigned in a tuple assignment")
+				return
+			}
+
+			fn.expr(rhs, i)
+		}
+
+		n := len(stmt.Lhs)
+
+		for i, lhs := range stmt.Lhs {
+			if isBlank(lhs) {
+				continue
+			}
+
+			if a, ok := fn.address(lhs, n); ok {
+				fn.store(a, fn.typeOf(lhs), reg(i), n)
+			}
+		}
+
+	default:
+		op := stmt.Tok - token.ADD_ASSIGN + token.ADD
+		lhs, rhs := stmt.Lhs[0], stmt.Rhs[0]
+		t := fn.typeOf(lhs)
+
+		if name, ok := arithOps[op]; ok {
+			// Memory is written at its own width, so narrow results wrap without normalising.
+			a, ok := fn.address(lhs, 0)
+			if !ok {
+				return
+			}
+
+			dest := fn.memOperand(a, fn.memSize(t), 0)
+			emit(name, dest, dest, fn.operand(rhs, 2))
+			return
+		}
+
+		fn.binary(op, stmt.TokPos, t, lhs, rhs, 0)
+
+		if a, ok := fn.address(lhs, 1); ok {
+			fn.store(a, t, V0, 1)
+		}
+	}
+}
+
+func (v *BlockStmtVisitor) switchStmt(stmt *ast.SwitchStmt) {
+	fn := v.fn
+
+	if stmt.Init != nil {
+		v.stmt(stmt.Init)
+	}
+
+	var tag address
+	if stmt.Tag != nil {
+		if !fn.scalar(fn.typeOf(stmt.Tag)) {
+			fn.errorf(stmt.Tag.Pos(), "switching on arrays or structs is not supported")
+			return
+		}
+
+		tag = fn.temp(4)
+		fn.store(tag, fn.typeOf(stmt.Tag), fn.operand(stmt.Tag, 0), 1)
+	}
+
+	clauses := stmt.Body.List
+	bodies := make([]string, len(clauses))
+	end := fn.newLabel()
+	defaultLabel := end
+
+	for i, iclause := range clauses {
+		clause := iclause.(*ast.CaseClause)
+		bodies[i] = fn.newLabel()
+
+		if clause.List == nil {
+			defaultLabel = bodies[i]
+		}
+
+		for _, e := range clause.List {
+			if stmt.Tag != nil {
+				fn.load(tag, fn.typeOf(stmt.Tag), 0)
+				emit("jeq", V0, fn.operand(e, 1), Label(bodies[i]))
+			} else {
+				fn.cond(e, 0, bodies[i], true)
+			}
+		}
+	}
+
+	emit("jmp", Label(defaultLabel))
+
+	t := &target{brk: end}
+	fn.pushTarget(t)
+
+	for i, iclause := range clauses {
+		clause := iclause.(*ast.CaseClause)
+		emitLabel(bodies[i])
+
+		if i+1 < len(clauses) {
+			t.next = bodies[i+1]
+		}
+
+		v.walkList(clause.Body)
+
+		if i+1 < len(clauses) {
+			emit("jmp", Label(end))
+		}
+	}
+
+	fn.popTarget()
+	emitLabel(end)
+}
+
+// rangeStmt compiles a loop over the elements of an array (or a pointer to one), or over the
+// integers from zero up to a limit.
+func (v *BlockStmtVisitor) rangeStmt(stmt *ast.RangeStmt) {
+	fn := v.fn
+	t := fn.typeOf(stmt.X).Underlying()
+
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem().Underlying()
+	}
+
+	arr, isArray := t.(*types.Array)
+	basic, isBasic := t.(*types.Basic)
+
+	if !isArray && !(isBasic && basic.Info()&types.IsInteger != 0) {
+		fn.errorf(stmt.X.Pos(), "range loops are only supported over arrays and integers")
+		return
+	}
+
+	// The loop counts with a hidden variable, so assigning to the key in the body doesn't affect it.
+	counter := fn.temp(4)
+	counterOp := fn.memOperand(counter, Word, 0)
+	var limit Operand
+
+	if isArray {
+		limit = Literal(arr.Len())
+	} else {
+		limitAddr := fn.temp(4)
+		limit = fn.memOperand(limitAddr, Word, 0)
+		emit("mov", limit, fn.operand(stmt.X, 0))
+	}
+
+	emit("mov", counterOp, Literal(0))
+
+	top := fn.newLabel()
+	loop := &target{brk: fn.newLabel(), cont: fn.newLabel()}
+
+	emitLabel(top)
+	emit("jge", counterOp, limit, Label(loop.brk))
+
+	if stmt.Key != nil && !isBlank(stmt.Key) {
+		if a, ok := fn.address(stmt.Key, 0); ok {
+			fn.store(a, fn.typeOf(stmt.Key), counterOp, 0)
+		}
+	}
+
+	if stmt.Value != nil && !isBlank(stmt.Value) {
+		v.rangeValue(stmt, arr, counterOp)
+	}
+
+	fn.pushTarget(loop)
+	ast.Walk(v, stmt.Body)
+	fn.popTarget()
+
+	emitLabel(loop.cont)
+	emit("add", counterOp, counterOp, Literal(1))
+	emit("jmp", Label(top))
+	emitLabel(loop.brk)
+}
+
+// rangeValue copies the element of the array being ranged over at index into the value variable.
+func (v *BlockStmtVisitor) rangeValue(stmt *ast.RangeStmt, arr *types.Array, index Operand) {
+	fn := v.fn
+
+	base, _, ok := fn.derefAddress(stmt.X, 0)
+	if !ok {
+		return
+	}
+
+	fn.materialize(base, 0)
+	elem := fn.element(index, arr, 0)
+
+	if fn.scalar(arr.Elem()) {
+		fn.load(elem, arr.Elem(), 0)
+
+		if a, ok := fn.address(stmt.Value, 1); ok {
+			fn.store(a, arr.Elem(), V0, 1)
+		}
+
+		return
+	}
+
+	fn.materialize(elem, 0)
+
+	if a, ok := fn.address(stmt.Value, 1); ok {
+		fn.materialize(a, 1)
+		fn.copyMem(V1, V0, fn.sizeOf(arr.Elem()), V2)
+	}
+}
+
+func (v *BlockStmtVisitor) branch(stmt *ast.BranchStmt) {
+	fn := v.fn
+
+	label := ""
+	if stmt.Label != nil {
+		label = stmt.Label.Name
+	}
+
+	switch stmt.Tok {
+	case token.BREAK:
+		if t := fn.findTarget(label, false); t != nil {
+			emit("jmp", Label(t.brk))
+		}
+
+	case token.CONTINUE:
+		if t := fn.findTarget(label, true); t != nil {
+			emit("jmp", Label(t.cont))
+		}
+
+	case token.FALLTHROUGH:
+		if t := fn.findTarget("", false); t != nil && t.next != "" {
+			emit("jmp", Label(t.next))
+		}
+
+	default:
+		fn.errorf(stmt.Pos(), "%s statements are not supported", stmt.Tok)
+	}
+}
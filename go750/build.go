@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"github.com/kierdavis/go/k750/k750asmlib"
+	"go/token"
+	"path/filepath"
+)
+
+// assemble assembles the generated assembly for the package in dir into a flat image.
+func assemble(fset *token.FileSet, asm *Assembly, dir string) (image *k750asmlib.Image, errs []error) {
+	image, asmErrs := k750asmlib.Assemble(bytes.NewReader(asm.Bytes()), filepath.Base(dir)+".asm")
+
+	for _, err := range asmErrs {
+		errs = append(errs, sourceError(fset, asm.Position(err.Coord.Lineno), "assembler: "+err.Message, err))
+	}
+
+	return image, errs
+}
+
+// sourceError returns an error reporting msg at pos, or fallback if pos is invalid because the
+// code concerned was added by the compiler rather than compiled from the Go source.
+func sourceError(fset *token.FileSet, pos token.Pos, msg string, fallback error) (err error) {
+	if !pos.IsValid() {
+		return fallback
+	}
+
+	return &CompileError{fset.Position(pos), msg}
+}
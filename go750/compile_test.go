@@ -0,0 +1,340 @@
+package main
+
+import (
+	"errors"
+	"github.com/kierdavis/go/k750/k750asmlib"
+	"github.com/kierdavis/go/k750/k750emlib"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// compileSource compiles src as the only file of a main package, returning the assembly or the
+// type checking and compile errors. Error positions are relative to the package directory.
+func compileSource(t *testing.T, src string) (fset *token.FileSet, asm *Assembly, errs []error) {
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(src), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset, asm, errs = compileDir(dir)
+
+	for i, err := range errs {
+		errs[i] = errors.New(strings.TrimPrefix(err.Error(), dir+string(filepath.Separator)))
+	}
+
+	return fset, asm, errs
+}
+
+// runSource compiles, assembles and runs src until main returns or the program panics.
+func runSource(t *testing.T, src string) (em *k750emlib.Emulator, image *k750asmlib.Image, panicked bool) {
+	fset, asm, errs := compileSource(t, src)
+	if len(errs) > 0 {
+		t.Fatalf("compile returned errors: %v", errs)
+	}
+
+	image, errs = assemble(fset, asm, "main")
+	if len(errs) > 0 {
+		t.Fatalf("generated assembly does not assemble: %v", errs)
+	}
+
+	em = k750emlib.NewEmulator()
+	em.LoadImage(image.Bytes(), 0)
+
+	for steps := 0; steps < 1000000; steps++ {
+		switch em.PC {
+		case image.Labels["__exit"]:
+			return em, image, false
+
+		case image.Labels["__panic"]:
+			return em, image, true
+		}
+
+		if err := em.RunOne(); err != nil {
+			t.Fatalf("emulator: %s (at 0x%08X)", err, em.PC)
+		}
+	}
+
+	t.Fatalf("program did not finish")
+	return nil, nil, false
+}
+
+func TestCompileAssembles(t *testing.T) {
+	src := `package main
+
+type Celsius int
+
+type point struct {
+	x, y int16
+	next *point
+}
+
+const limit = 100
+
+var table [4]point
+var count uint8
+
+func sum(n int) int {
+	total := 0
+	for i := 1; i <= n; i++ {
+		if i%3 == 0 {
+			continue
+		}
+		total += i
+	}
+	return total
+}
+
+func classify(c Celsius) uint {
+	switch {
+	case c < 0:
+		return 0
+	case c < limit:
+		return 1
+	}
+	return 2
+}
+
+func main() {
+	x := sum(10) / 3
+	y := classify(Celsius(x)) << uint(x)
+	_ = y
+
+	p := &table[count]
+	p.x = int16(y)
+	p.next = &table[3]
+	count++
+}
+`
+
+	fset, asm, errs := compileSource(t, src)
+	if len(errs) > 0 {
+		t.Fatalf("compile returned errors: %v", errs)
+	}
+
+	if _, errs := assemble(fset, asm, "main"); len(errs) > 0 {
+		t.Fatalf("generated assembly does not assemble: %v\n%s", errs, asm.Bytes())
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src     string
+		message string
+	}{
+		{"var x float32\nfunc main() {}", "main.go:3:5: floating-point numbers are not supported"},
+		{"type Shape interface{ Area() int }\nfunc main() {}", "main.go:3:12: interfaces are not supported"},
+		{"func main() {\n\ttype point struct{ name string }\n}", "main.go:4:13: strings are not supported"},
+		{"func main() {\n\tvar m map[int]int\n\t_ = m\n}", "main.go:4:6: maps are not supported"},
+		{"type T int\nfunc (t T) M() {}\nfunc main() {}", "main.go:4:6: methods are not supported"},
+		{"func f() (int, int) { return 1, 2 }\nfunc main() {}", "main.go:3:10: functions with more than one result are not supported"},
+		{"func f() {}\nfunc main() {\n\tgo f()\n}", "main.go:5:2: go statements are not supported"},
+		{"func f() {}\nfunc main() {\n\tdefer f()\n}", "main.go:5:2: defer statements are not supported"},
+		{"func main() {\n\tfor range \"ab\" {\n\t}\n}", "main.go:4:12: range loops are only supported over arrays and integers"},
+		{"func main() {\n\tx := 1\n\tprintln(x)\n}", "main.go:5:2: println is not supported"},
+		{"//k750:interrupt 300\nfunc h() {}\nfunc main() {}", "main.go:3:1: invalid interrupt number: 300"},
+		{"//k750:interrupt\nfunc h(n int) {}\nfunc main() {}", "main.go:4:6: interrupt handlers can't have parameters or results"},
+		{"import \"github.com/kierdavis/go/k750\"\nfunc main() {\n\tb := k750.BitReg(3)\n\tk750.SetBit(b)\n}", "main.go:6:14: the bit register must be a constant from 0 to 15"},
+		{"import \"github.com/kierdavis/go/k750\"\nfunc h() {}\nfunc main() {\n\tk750.SetHandler(1, h)\n}", "main.go:6:21: the handler must be a function annotated with //k750:interrupt"},
+	}
+
+	for _, test := range tests {
+		_, _, errs := compileSource(t, "package main\n\n"+test.src+"\n")
+
+		if len(errs) == 0 {
+			t.Errorf("%q: compile returned no errors, expected %q", test.src, test.message)
+			continue
+		}
+
+		if msg := errs[0].Error(); msg != test.message {
+			t.Errorf("%q: compile returned %q, expected %q", test.src, msg, test.message)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		results  map[string]uint32 // Expected value of each package-level variable after main returns
+		panicked bool
+	}{
+		{
+			name: "loops and calls",
+			src: `
+var sum, fact int
+
+func factorial(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return n * factorial(n-1)
+}
+
+func main() {
+	for i := 1; i <= 10; i++ {
+		if i == 4 {
+			continue
+		}
+		sum += i
+	}
+	fact = factorial(6)
+}`,
+			results: map[string]uint32{"sum": 51, "fact": 720},
+		},
+		{
+			name: "switch and fallthrough",
+			src: `
+var a, b int
+
+func classify(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n == 0:
+		fallthrough
+	case n == 1:
+		return 0
+	}
+	return 1
+}
+
+func main() {
+	a = classify(-5)*100 + classify(0)*10 + classify(1)
+	b = classify(7)
+}`,
+			results: map[string]uint32{"a": 0xFFFFFF9C, "b": 1},
+		},
+		{
+			name: "integer wraparound",
+			src: `
+var i32 int32 = 0x7FFFFFFF
+var u32 uint32
+var i8 int8 = 127
+var u8 uint8 = 255
+var widened, shifted int
+
+func main() {
+	i32++
+	u32--
+	i8++
+	u8 += 2
+	widened = int(i8)
+	n := uint(3)
+	shifted = -64 >> n
+}`,
+			results: map[string]uint32{"i32": 0x80000000, "u32": 0xFFFFFFFF, "widened": 0xFFFFFF80, "shifted": 0xFFFFFFF8},
+		},
+		{
+			name: "signed division",
+			src: `
+var q1, r1, q2, r2, q3, r3 int
+var uq uint32
+
+func main() {
+	x, y := -7, 2
+	q1, r1 = x/y, x%y
+	x, y = 7, -2
+	q2, r2 = x/y, x%y
+	x, y = -7, -2
+	q3, r3 = x/y, x%y
+	u, v := uint32(0xFFFFFFFF), uint32(16)
+	uq = u / v
+}`,
+			results: map[string]uint32{
+				"q1": 0xFFFFFFFD, "r1": 0xFFFFFFFF,
+				"q2": 0xFFFFFFFD, "r2": 1,
+				"q3": 3, "r3": 0xFFFFFFFF,
+				"uq": 0x0FFFFFFF,
+			},
+		},
+		{
+			name: "arrays, structs and pointers",
+			src: `
+type node struct {
+	value int16
+	next  *node
+}
+
+var nodes [3]node
+var total int
+
+func main() {
+	for i := range 3 {
+		nodes[i].value = int16(i + 1) * 10
+		if i > 0 {
+			nodes[i-1].next = &nodes[i]
+		}
+	}
+
+	for p := &nodes[0]; p != nil; p = p.next {
+		total += int(p.value)
+	}
+}`,
+			results: map[string]uint32{"total": 60},
+		},
+		{
+			name: "index out of range",
+			src: `
+var a [4]int
+var reached int
+
+func main() {
+	i := 0
+	for i < 10 {
+		a[i] = i
+		i++
+	}
+	reached = 1
+}`,
+			results:  map[string]uint32{"reached": 0},
+			panicked: true,
+		},
+		{
+			name: "negative index",
+			src: `
+var a [4]int
+
+func main() {
+	i := 2
+	i -= 3
+	a[i] = 1
+}`,
+			panicked: true,
+		},
+		{
+			name: "panic",
+			src: `
+func main() {
+	panic(1)
+}`,
+			panicked: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			em, image, panicked := runSource(t, "package main\n"+test.src+"\n")
+
+			if panicked != test.panicked {
+				t.Errorf("panicked = %v, expected %v", panicked, test.panicked)
+			}
+
+			for name, expected := range test.results {
+				addr, ok := image.Labels[name]
+				if !ok {
+					t.Errorf("no label for variable %s", name)
+					continue
+				}
+
+				if got := em.MemoryLoad32(addr); got != expected {
+					t.Errorf("%s = 0x%08X, expected 0x%08X", name, got, expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+)
+
+// CompileError is a problem found in the source being compiled.
+type CompileError struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e *CompileError) Error() (msg string) {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+// Compiler holds the state shared by all the functions of the package being compiled.
+type Compiler struct {
+	Fset     *token.FileSet
+	Info     *types.Info
+	Sizes    types.Sizes
+	Errors   []error
+	Runtime  map[string]bool     // Runtime routines called by the generated code
+	Globals  []*ast.Ident        // Package-level variables, laid out in the data section
+	Handlers map[*types.Func]int // Interrupt handlers and the interrupt each is registered for, or -1
+	labels   int
+}
+
+func NewCompiler(fset *token.FileSet, info *types.Info, sizes types.Sizes) (c *Compiler) {
+	return &Compiler{
+		Fset:     fset,
+		Info:     info,
+		Sizes:    sizes,
+		Runtime:  make(map[string]bool),
+		Handlers: make(map[*types.Func]int),
+	}
+}
+
+func (e *CompileError) before(other *CompileError) (ok bool) {
+	if e.Pos.Filename != other.Pos.Filename {
+		return e.Pos.Filename < other.Pos.Filename
+	}
+
+	return e.Pos.Offset < other.Pos.Offset
+}
+
+func (c *Compiler) errorf(pos token.Pos, format string, args ...interface{}) {
+	c.Errors = append(c.Errors, &CompileError{c.Fset.Position(pos), fmt.Sprintf(format, args...)})
+}
+
+// newLabel returns a fresh label local to the named function. Go identifiers can't contain a
+// dot, so these never clash with function names.
+func (c *Compiler) newLabel(funcName string) (label string) {
+	c.labels++
+	return fmt.Sprintf("%s.L%d", funcName, c.labels)
+}
+
+// useRuntime records that the generated code calls a runtime routine, so that it is included in
+// the output.
+func (c *Compiler) useRuntime(name string) {
+	c.Runtime[name] = true
+}
+
+// unsupported returns why values of type t can't be compiled, or "" if they can.
+func (c *Compiler) unsupported(t types.Type) (reason string) {
+	switch t := t.Underlying().(type) {
+	case *types.Basic:
+		info := t.Info()
+
+		switch {
+		case t.Kind() == types.Int64 || t.Kind() == types.Uint64:
+			return "64-bit integers are not supported"
+		case info&(types.IsBoolean|types.IsInteger) != 0 || t.Kind() == types.UntypedNil:
+			return ""
+		case info&(types.IsFloat|types.IsComplex) != 0:
+			return "floating-point numbers are not supported"
+		case info&types.IsString != 0:
+			return "strings are not supported"
+		}
+
+	case *types.Pointer:
+		return ""
+
+	case *types.Array:
+		return c.unsupported(t.Elem())
+
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if reason := c.unsupported(t.Field(i).Type()); reason != "" {
+				return reason
+			}
+		}
+
+		return ""
+
+	case *types.Map:
+		return "maps are not supported"
+	case *types.Chan:
+		return "channels are not supported"
+	case *types.Interface:
+		return "interfaces are not supported"
+	case *types.Slice:
+		return "slices are not supported"
+	case *types.Signature:
+		return "function values are not supported"
+	}
+
+	return fmt.Sprintf("type %s is not supported", t)
+}
+
+// scalar returns whether values of type t fit in a register.
+func (c *Compiler) scalar(t types.Type) (ok bool) {
+	switch t.Underlying().(type) {
+	case *types.Basic, *types.Pointer:
+		return true
+	}
+
+	return false
+}
+
+// typeDecl reports the types declared by decl that values can't be compiled for, whether or not
+// they are used.
+func (c *Compiler) typeDecl(decl *ast.GenDecl) {
+	for _, ispec := range decl.Specs {
+		spec := ispec.(*ast.TypeSpec)
+		obj := c.Info.Defs[spec.Name]
+		if obj == nil {
+			continue
+		}
+
+		if reason := c.unsupported(obj.Type()); reason != "" {
+			c.errorf(spec.Type.Pos(), "%s", reason)
+		}
+	}
+}
+
+// sizeOf returns the number of bytes taken by a value of type t.
+func (c *Compiler) sizeOf(t types.Type) (size int64) {
+	return c.Sizes.Sizeof(t)
+}
+
+// memSize returns the size of the memory access used to load or store a scalar of type t.
+func (c *Compiler) memSize(t types.Type) (size Size) {
+	switch c.sizeOf(t) {
+	case 1:
+		return Byte
+	case 2:
+		return Half
+	}
+
+	return Word
+}
+
+// narrow returns whether t is an integer type smaller than a register.
+func (c *Compiler) narrow(t types.Type) (ok bool) {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsInteger != 0 && c.sizeOf(t) < 4
+}
+
+// fieldOffset returns the offset of field i of struct type t.
+func (c *Compiler) fieldOffset(t *types.Struct, i int) (offset int64) {
+	fields := make([]*types.Var, t.NumFields())
+	for j := range fields {
+		fields[j] = t.Field(j)
+	}
+
+	return c.Sizes.Offsetsof(fields)[i]
+}
+
+// global returns whether v is a package-level variable.
+func (c *Compiler) global(v *types.Var) (ok bool) {
+	return v.Pkg() != nil && v.Parent() == v.Pkg().Scope()
+}
+
+// unsigned returns whether t is an unsigned integer type.
+func (c *Compiler) unsigned(t types.Type) (ok bool) {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsUnsigned != 0
+}
+
+// constant returns the value of e if it is a constant expression or nil. Booleans are represented
+// as 0 and 1, and nil as 0.
+func (c *Compiler) constant(e ast.Expr) (value int64, ok bool) {
+	tv, ok := c.Info.Types[e]
+	if !ok {
+		return 0, false
+	}
+
+	if tv.IsNil() {
+		return 0, true
+	}
+
+	if tv.Value == nil {
+		return 0, false
+	}
+
+	switch tv.Value.Kind() {
+	case constant.Bool:
+		if constant.BoolVal(tv.Value) {
+			return 1, true
+		}
+
+		return 0, true
+
+	case constant.Int:
+		if value, exact := constant.Int64Val(tv.Value); exact {
+			return value, true
+		}
+	}
+
+	return 0, false
+}
+
+// function returns the package-level function that e names, if any.
+func (c *Compiler) function(e ast.Expr) (fn *types.Func) {
+	ident, ok := ast.Unparen(e).(*ast.Ident)
+	if !ok {
+		return nil
+	}
+
+	fn, ok = c.Info.Uses[ident].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Parent() != fn.Pkg().Scope() {
+		return nil
+	}
+
+	return fn
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// Expressions are evaluated using v0-v7 as a stack: an expression compiled into register r may use
+// the registers above r as scratch space but leaves the ones below it alone.
+const NumValueRegs = 8
+
+// signBit is XORed into both sides of an unsigned comparison so that the signed comparison
+// instructions give the right answer.
+const signBit = Literal(1 << 31)
+
+func reg(r int) (operand Register) {
+	return V0 + Register(r)
+}
+
+var arithOps = map[token.Token]string{
+	token.ADD: "add",
+	token.SUB: "sub",
+	token.AND: "and",
+	token.OR:  "or",
+	token.XOR: "xor",
+}
+
+var jumpOps = map[token.Token]string{
+	token.EQL: "jeq",
+	token.NEQ: "jne",
+	token.LSS: "jlt",
+	token.GEQ: "jge",
+	token.GTR: "jgt",
+	token.LEQ: "jle",
+}
+
+var negatedOps = map[token.Token]token.Token{
+	token.EQL: token.NEQ,
+	token.NEQ: token.EQL,
+	token.LSS: token.GEQ,
+	token.GEQ: token.LSS,
+	token.GTR: token.LEQ,
+	token.LEQ: token.GTR,
+}
+
+func (fn *Function) typeOf(e ast.Expr) (t types.Type) {
+	return fn.Info.TypeOf(e)
+}
+
+// operand returns an operand holding the value of e. Constants and variables are used directly;
+// anything else is computed into register r.
+func (fn *Function) operand(e ast.Expr, r int) (operand Operand) {
+	if value, ok := fn.constant(e); ok {
+		return Literal(value)
+	}
+
+	// Memory operands are zero-extended, so a narrow signed integer has to be loaded to be used.
+	t := fn.typeOf(e)
+	if fn.addressable(e) && fn.scalar(t) && !(fn.narrow(t) && !fn.unsigned(t)) {
+		a, ok := fn.address(e, r)
+		if !ok {
+			return reg(r)
+		}
+
+		operand = fn.memOperand(a, fn.memSize(t), r)
+
+		// An indexed operand uses register r+1 too, which the caller may need.
+		if a.indexed {
+			emit("mov", reg(r), operand)
+			return reg(r)
+		}
+
+		return operand
+	}
+
+	fn.expr(e, r)
+	return reg(r)
+}
+
+// expr computes the value of e into register r.
+func (fn *Function) expr(e ast.Expr, r int) {
+	if r >= NumValueRegs {
+		fn.errorf(e.Pos(), "expression is too complex")
+		return
+	}
+
+	if value, ok := fn.constant(e); ok {
+		emit("mov", reg(r), Literal(value))
+		return
+	}
+
+	t := fn.typeOf(e)
+
+	if reason := fn.unsupported(t); reason != "" {
+		fn.errorf(e.Pos(), "%s", reason)
+		return
+	}
+
+	if !fn.scalar(t) {
+		fn.errorf(e.Pos(), "arrays and structs can only be assigned or have their address taken")
+		return
+	}
+
+	if fn.addressable(e) {
+		if a, ok := fn.address(e, r); ok {
+			fn.load(a, t, r)
+		}
+
+		return
+	}
+
+	switch node := e.(type) {
+	case *ast.ParenExpr:
+		fn.expr(node.X, r)
+
+	case *ast.Ident:
+		fn.errorf(node.Pos(), "cannot use %s as a value", node.Name)
+
+	case *ast.UnaryExpr:
+		switch node.Op {
+		case token.AND:
+			if _, ok := ast.Unparen(node.X).(*ast.CompositeLit); ok {
+				fn.errorf(node.Pos(), "composite literals can't be allocated; declare a variable and take its address")
+				return
+			}
+
+			if a, ok := fn.address(node.X, r); ok {
+				fn.materialize(a, r)
+			}
+
+		case token.ADD:
+			fn.expr(node.X, r)
+		case token.SUB:
+			emit("neg", reg(r), fn.operand(node.X, r))
+			fn.normalize(t, r)
+		case token.XOR:
+			emit("not", reg(r), fn.operand(node.X, r))
+			fn.normalize(t, r)
+		case token.NOT:
+			fn.expr(node.X, r)
+			emit("xor", reg(r), reg(r), Literal(1))
+		default:
+			fn.errorf(node.OpPos, "operator %s is not supported", node.Op)
+		}
+
+	case *ast.BinaryExpr:
+		switch node.Op {
+		case token.LAND, token.LOR, token.EQL, token.NEQ, token.LSS, token.GEQ, token.GTR, token.LEQ:
+			isFalse := fn.newLabel()
+			end := fn.newLabel()
+
+			fn.cond(node, r, isFalse, false)
+			emit("mov", reg(r), Literal(1))
+			emit("jmp", Label(end))
+			emitLabel(isFalse)
+			emit("mov", reg(r), Literal(0))
+			emitLabel(end)
+
+		default:
+			fn.binary(node.Op, node.OpPos, t, node.X, node.Y, r)
+		}
+
+	case *ast.CallExpr:
+		fn.call(node, r)
+
+	default:
+		fn.errorf(e.Pos(), "expression is not supported")
+	}
+}
+
+// binary computes x op y into register r, where t is the type of the result.
+func (fn *Function) binary(op token.Token, pos token.Pos, t types.Type, x, y ast.Expr, r int) {
+	defer fn.normalize(t, r)
+
+	if name, ok := arithOps[op]; ok {
+		fn.expr(x, r)
+		emit(name, reg(r), reg(r), fn.operand(y, r+1))
+		return
+	}
+
+	switch op {
+	case token.AND_NOT:
+		if r+1 >= NumValueRegs {
+			fn.errorf(pos, "expression is too complex")
+			return
+		}
+
+		fn.expr(x, r)
+		emit("not", reg(r+1), fn.operand(y, r+1))
+		emit("and", reg(r), reg(r), reg(r+1))
+
+	case token.SHL, token.SHR:
+		name := "shl"
+		if op == token.SHR {
+			if fn.unsigned(t) {
+				name = "lshr"
+			} else {
+				name = "ashr"
+			}
+		}
+
+		count, ok := fn.constant(y)
+		if !ok {
+			fn.useRuntime("__" + name)
+			fn.callLabel("__"+name, []ast.Expr{x, y}, r)
+			return
+		}
+
+		fn.expr(x, r)
+
+		if count < 32 {
+			emit(name, reg(r), reg(r), Literal(count))
+		} else if name == "ashr" {
+			emit(name, reg(r), reg(r), Literal(31))
+		} else {
+			emit("mov", reg(r), Literal(0))
+		}
+
+	case token.MUL, token.QUO, token.REM:
+		var name string
+
+		switch {
+		case op == token.MUL:
+			name = "__mul"
+		case op == token.QUO && fn.unsigned(t):
+			name = "__divu"
+		case op == token.QUO:
+			name = "__divs"
+		case fn.unsigned(t):
+			name = "__modu"
+		default:
+			name = "__mods"
+		}
+
+		fn.useRuntime(name)
+		fn.callLabel(name, []ast.Expr{x, y}, r)
+
+	default:
+		fn.errorf(pos, "operator %s is not supported", op)
+	}
+}
+
+// cond emits a jump to label, taken if e evaluates to jumpIf. Registers from r upwards may be
+// used to evaluate it.
+func (fn *Function) cond(e ast.Expr, r int, label string, jumpIf bool) {
+	if value, ok := fn.constant(e); ok {
+		if (value != 0) == jumpIf {
+			emit("jmp", Label(label))
+		}
+
+		return
+	}
+
+	switch node := e.(type) {
+	case *ast.ParenExpr:
+		fn.cond(node.X, r, label, jumpIf)
+		return
+
+	case *ast.UnaryExpr:
+		if node.Op == token.NOT {
+			fn.cond(node.X, r, label, !jumpIf)
+			return
+		}
+
+	case *ast.BinaryExpr:
+		switch node.Op {
+		case token.LAND, token.LOR:
+			// a && b jumps if true only when both are; a || b jumps if false only when both are.
+			if (node.Op == token.LAND) == jumpIf {
+				skip := fn.newLabel()
+				fn.cond(node.X, r, skip, !jumpIf)
+				fn.cond(node.Y, r, label, jumpIf)
+				emitLabel(skip)
+
+			} else {
+				fn.cond(node.X, r, label, jumpIf)
+				fn.cond(node.Y, r, label, jumpIf)
+			}
+
+			return
+
+		case token.EQL, token.NEQ, token.LSS, token.GEQ, token.GTR, token.LEQ:
+			op := node.Op
+			if !jumpIf {
+				op = negatedOps[op]
+			}
+
+			fn.compare(op, fn.typeOf(node.X), node.X, node.Y, r, label)
+			return
+		}
+
+	case *ast.CallExpr:
+		if name, ok := fn.intrinsic(node.Fun); ok && name == "TestBit" {
+			if b, ok := fn.bitReg(node.Args[0]); ok {
+				if jumpIf {
+					emit("jbs", b, Label(label))
+				} else {
+					emit("jbc", b, Label(label))
+				}
+			}
+
+			return
+		}
+	}
+
+	if jumpIf {
+		emit("jne", fn.operand(e, r), Literal(0), Label(label))
+	} else {
+		emit("jeq", fn.operand(e, r), Literal(0), Label(label))
+	}
+}
+
+// compare emits a jump to label, taken if x op y holds for operands of type t.
+func (fn *Function) compare(op token.Token, t types.Type, x, y ast.Expr, r int, label string) {
+	if !fn.scalar(t) {
+		fn.errorf(x.Pos(), "comparing arrays or structs is not supported")
+		return
+	}
+
+	if op == token.EQL || op == token.NEQ || !fn.unsigned(t) {
+		a := fn.operand(x, r)
+		b := fn.operand(y, r+1)
+		emit(jumpOps[op], a, b, Label(label))
+		return
+	}
+
+	if r+1 >= NumValueRegs {
+		fn.errorf(x.Pos(), "expression is too complex")
+		return
+	}
+
+	emit("xor", reg(r), fn.operand(x, r), signBit)
+	emit("xor", reg(r+1), fn.operand(y, r+1), signBit)
+	emit(jumpOps[op], reg(r), reg(r+1), Label(label))
+}
+
+// call compiles a function call or type conversion, leaving any result in register r.
+func (fn *Function) call(node *ast.CallExpr, r int) {
+	if tv, ok := fn.Info.Types[node.Fun]; ok && tv.IsType() {
+		fn.expr(node.Args[0], r)
+		fn.normalize(tv.Type, r)
+		return
+	}
+
+	if name, ok := fn.intrinsic(node.Fun); ok {
+		fn.callIntrinsic(name, node, r)
+		return
+	}
+
+	if ident, ok := ast.Unparen(node.Fun).(*ast.Ident); ok {
+		if builtin, ok := fn.Info.Uses[ident].(*types.Builtin); ok {
+			if builtin.Name() == "panic" {
+				emit("jmp", Label("__panic"))
+			} else {
+				fn.errorf(node.Pos(), "%s is not supported", builtin.Name())
+			}
+
+			return
+		}
+	}
+
+	callee := fn.function(node.Fun)
+	if callee == nil {
+		fn.errorf(node.Pos(), "only calls to functions declared in this package are supported")
+		return
+	}
+
+	if _, ok := fn.Handlers[callee]; ok {
+		fn.errorf(node.Pos(), "interrupt handlers can't be called directly")
+		return
+	}
+
+	fn.callLabel(callee.Name(), node.Args, r)
+}
+
+// callLabel calls the function at label with the given arguments following the calling
+// convention, leaving the result in register r. The registers below r are saved around the call.
+func (fn *Function) callLabel(label string, args []ast.Expr, r int) {
+	for i := 0; i < r; i++ {
+		emit("push", reg(i))
+	}
+
+	for i := len(args) - 1; i >= 0; i-- {
+		emit("push", fn.operand(args[i], r))
+	}
+
+	emit("call", Label(label))
+
+	if len(args) > 0 {
+		emit("add", SP, SP, Literal(4*len(args)))
+	}
+
+	if r != 0 {
+		emit("mov", reg(r), V0)
+	}
+
+	for i := r - 1; i >= 0; i-- {
+		emit("pop", reg(i))
+	}
+}
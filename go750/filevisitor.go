@@ -3,29 +3,73 @@ package main
 import (
 	"fmt"
 	"go/ast"
+	"go/token"
+	"go/types"
 )
 
+// FileVisitor compiles the declarations of a file.
 type FileVisitor struct {
+	c     *Compiler
+	inits int
 }
 
-func NewFileVisitor() (v *FileVisitor) {
-	return &FileVisitor{}
+func NewFileVisitor(c *Compiler) (v *FileVisitor) {
+	return &FileVisitor{c: c}
 }
 
 func (v *FileVisitor) Visit(inode ast.Node) (w ast.Visitor) {
-	fmt.Printf("FileVisitor: %#v\n", inode)
-
 	if inode == nil {
 
 	} else {
 		switch node := inode.(type) {
+		case *ast.File:
+			return v
+
+		case *ast.GenDecl:
+			switch node.Tok {
+			case token.VAR:
+				v.globals(node)
+
+			case token.TYPE:
+				v.c.typeDecl(node)
+			}
+
 		case *ast.FuncDecl:
+			if node.Body == nil {
+				v.c.errorf(node.Pos(), "functions without a body are not supported")
+				return nil
+			}
+
 			name := node.Name.Name
-			emitLabel(name)
+			if name == "init" && node.Recv == nil {
+				// A package may have several init functions, so number them.
+				v.inits++
+				name = fmt.Sprintf("init.%d", v.inits)
+			}
 
-			return NewFuncDeclVisitor()
+			return NewFuncDeclVisitor(NewFunction(v.c, node, name), node)
 		}
 	}
 
 	return nil
 }
+
+// globals records the package-level variables declared by decl, so that space is reserved for them
+// in the data section. Their initial values are set by init.vars.
+func (v *FileVisitor) globals(decl *ast.GenDecl) {
+	for _, spec := range decl.Specs {
+		for _, name := range spec.(*ast.ValueSpec).Names {
+			global, ok := v.c.Info.Defs[name].(*types.Var)
+			if !ok {
+				continue
+			}
+
+			if reason := v.c.unsupported(global.Type()); reason != "" {
+				v.c.errorf(name.Pos(), "%s", reason)
+				continue
+			}
+
+			v.c.Globals = append(v.c.Globals, name)
+		}
+	}
+}
@@ -1,27 +1,37 @@
 package main
 
 import (
-	"fmt"
 	"go/ast"
 )
 
+// FuncDeclVisitor compiles a function declaration: its body is compiled by a BlockStmtVisitor, and
+// the function is emitted once the whole declaration has been visited.
 type FuncDeclVisitor struct {
+	fn   *Function
+	decl *ast.FuncDecl
 }
 
-func NewFuncDeclVisitor() (v *FuncDeclVisitor) {
-	return &FuncDeclVisitor{}
+func NewFuncDeclVisitor(fn *Function, decl *ast.FuncDecl) (v *FuncDeclVisitor) {
+	fn.begin()
+	return &FuncDeclVisitor{fn, decl}
 }
 
 func (v *FuncDeclVisitor) Visit(inode ast.Node) (w ast.Visitor) {
-	fmt.Printf("FuncDeclVisitor: %#v\n", inode)
-
 	if inode == nil {
+		// Falling off the end of the function returns from it.
+		body := v.decl.Body.List
+		falls := true
+		if len(body) > 0 {
+			_, returns := body[len(body)-1].(*ast.ReturnStmt)
+			falls = !returns
+		}
+
+		v.fn.end(falls)
 
 	} else {
-		switch node := inode.(type) {
+		switch inode.(type) {
 		case *ast.BlockStmt:
-			_ = node
-			return NewBlockStmtVisitor()
+			return NewBlockStmtVisitor(v.fn)
 		}
 	}
 
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// Function holds the frame layout and code generation state of the function being compiled.
+type Function struct {
+	*Compiler
+	Name      string
+	Sig       *types.Signature
+	Pos       token.Pos              // Position of the declaration, if there is one
+	Interrupt bool                   // Whether the function is an interrupt handler
+	FrameSize int32                  // Bytes reserved below the frame pointer for locals
+	slots     map[types.Object]int32 // Offsets of parameters and locals from the frame pointer
+	targets   []*target              // Enclosing statements that can be left with break
+	label     string                 // Label of the statement about to be compiled, if any
+	out       *Assembly              // Output to restore once the body has been compiled
+	body      *Assembly
+}
+
+// target is a loop or switch that break, continue or fallthrough can jump out of.
+type target struct {
+	label string // Go label, or "" if the statement is unlabelled
+	brk   string // Jumped to by break
+	cont  string // Jumped to by continue, or "" for a switch
+	next  string // Jumped to by fallthrough
+}
+
+func newFunction(c *Compiler, name string, sig *types.Signature) (fn *Function) {
+	return &Function{
+		Compiler: c,
+		Name:     name,
+		Sig:      sig,
+		slots:    make(map[types.Object]int32),
+	}
+}
+
+// NewFunction lays out the parameters and local variables of decl, which is emitted under the given
+// label.
+func NewFunction(c *Compiler, decl *ast.FuncDecl, name string) (fn *Function) {
+	fn = newFunction(c, name, c.Info.Defs[decl.Name].Type().(*types.Signature))
+	fn.Pos = decl.Name.Pos()
+
+	if decl.Recv != nil {
+		c.errorf(decl.Recv.Pos(), "methods are not supported")
+	}
+
+	if _, ok := c.Handlers[c.Info.Defs[decl.Name].(*types.Func)]; ok {
+		fn.Interrupt = true
+
+		if fn.Sig.Params().Len() > 0 || fn.Sig.Results().Len() > 0 {
+			c.errorf(decl.Name.Pos(), "interrupt handlers can't have parameters or results")
+		}
+	}
+
+	// Arguments are pushed as whole words, so a narrower one is in the low-order (last) bytes of
+	// its slot.
+	params := fn.Sig.Params()
+	for i := 0; i < params.Len(); i++ {
+		param := params.At(i)
+
+		if fn.checkParam(param) {
+			fn.slots[param] = 8 + 4*int32(i) + 4 - int32(fn.sizeOf(param.Type()))
+		}
+	}
+
+	results := fn.Sig.Results()
+	if results.Len() > 1 {
+		c.errorf(decl.Type.Results.Pos(), "functions with more than one result are not supported")
+
+	} else if results.Len() == 1 && fn.checkParam(results.At(0)) && results.At(0).Name() != "" {
+		fn.local(results.At(0))
+	}
+
+	ast.Inspect(decl.Body, func(inode ast.Node) bool {
+		switch node := inode.(type) {
+		case *ast.FuncLit:
+			c.errorf(node.Pos(), "function literals are not supported")
+			return false
+
+		case *ast.Ident:
+			if v, ok := c.Info.Defs[node].(*types.Var); ok && !v.IsField() {
+				if reason := c.unsupported(v.Type()); reason != "" {
+					c.errorf(v.Pos(), "%s", reason)
+				} else {
+					fn.local(v)
+				}
+			}
+		}
+
+		return true
+	})
+
+	return fn
+}
+
+// checkParam reports an error if v can't be passed to or returned from a function.
+func (fn *Function) checkParam(v *types.Var) (ok bool) {
+	if reason := fn.unsupported(v.Type()); reason != "" {
+		fn.errorf(v.Pos(), "%s", reason)
+		return false
+	}
+
+	if !fn.scalar(v.Type()) {
+		fn.errorf(v.Pos(), "arrays and structs can't be passed to or returned from functions; use a pointer")
+		return false
+	}
+
+	return true
+}
+
+// alloc reserves size bytes in the frame, rounded up to a whole number of words, and returns their
+// offset from the frame pointer.
+func (fn *Function) alloc(size int64) (offset int32) {
+	fn.FrameSize += int32((size + 3) &^ 3)
+	return -fn.FrameSize
+}
+
+func (fn *Function) local(v *types.Var) {
+	fn.slots[v] = fn.alloc(fn.sizeOf(v.Type()))
+}
+
+// temp reserves a hidden local variable of the given size.
+func (fn *Function) temp(size int64) (a address) {
+	return address{base: Q1, disp: int64(fn.alloc(size))}
+}
+
+func (fn *Function) newLabel() (label string) {
+	return fn.Compiler.newLabel(fn.Name)
+}
+
+// begin starts compiling the body of the function. The body is buffered so that the prologue can
+// be emitted in front of it once the size of the frame is known.
+func (fn *Function) begin() {
+	fn.out = Output
+	fn.body = new(Assembly)
+	fn.body.SetPos(fn.Pos)
+	Output = fn.body
+}
+
+// end emits the function, returning from it if falls is set (i.e. control can fall off the end of
+// the body).
+func (fn *Function) end(falls bool) {
+	if falls {
+		fn.emitEpilogue()
+	}
+
+	Output = fn.out
+	prev := Output.SetPos(fn.Pos)
+	fn.emitPrologue()
+	Output.SetPos(prev)
+	Output.Append(fn.body)
+}
+
+// emitPrologue sets up the frame. An interrupt handler can run at any point, so it also saves the
+// registers that an ordinary function may overwrite.
+func (fn *Function) emitPrologue() {
+	emitLabel(fn.Name)
+
+	if fn.Interrupt {
+		for r := 0; r < NumValueRegs; r++ {
+			emit("push", reg(r))
+		}
+	}
+
+	emit("push", Q1)
+	emit("mov", Q1, SP)
+
+	if fn.FrameSize > 0 {
+		emit("sub", SP, SP, Literal(fn.FrameSize))
+	}
+}
+
+func (fn *Function) emitEpilogue() {
+	emit("mov", SP, Q1)
+	emit("pop", Q1)
+
+	if fn.Interrupt {
+		for r := NumValueRegs - 1; r >= 0; r-- {
+			emit("pop", reg(r))
+		}
+
+		emit("reti")
+		return
+	}
+
+	emit("ret")
+}
+
+// pushTarget takes the pending statement label, if any, for a loop or switch being compiled.
+func (fn *Function) pushTarget(t *target) {
+	t.label = fn.label
+	fn.label = ""
+	fn.targets = append(fn.targets, t)
+}
+
+func (fn *Function) popTarget() {
+	fn.targets = fn.targets[:len(fn.targets)-1]
+}
+
+// findTarget returns the innermost enclosing target with the given label (or any label if it is
+// empty) that is a loop if needLoop is set.
+func (fn *Function) findTarget(label string, needLoop bool) (t *target) {
+	for i := len(fn.targets) - 1; i >= 0; i-- {
+		t = fn.targets[i]
+
+		if (label == "" || t.label == label) && (!needLoop || t.cont != "") {
+			return t
+		}
+	}
+
+	return nil
+}
@@ -15,6 +15,10 @@ func NewInstruction(name string, operands ...Operand) (inst Instruction) {
 }
 
 func (inst Instruction) String() (str string) {
+	if len(inst.Operands) == 0 {
+		return inst.Name
+	}
+
 	opStrs := make([]string, len(inst.Operands))
 
 	for i, operand := range inst.Operands {
@@ -41,11 +45,7 @@ func (operand Register) String() (str string) {
 type Literal int64
 
 func (operand Literal) String() (str string) {
-	if operand < 0 {
-		return fmt.Sprintf("-0x%X", -operand)
-	}
-
-	return fmt.Sprintf("0x%X", operand)
+	return fmt.Sprintf("%d", int64(operand))
 }
 
 type Label string
@@ -65,7 +65,11 @@ type MemRef struct {
 
 func (operand MemRef) String() (str string) {
 	if operand.IsArray {
-		return fmt.Sprintf("%s[%s, %s, %s, %s]", operand.Size, operand.Base, operand.Index, operand.Scale, operand.Disp)
+		return fmt.Sprintf("%s[%s + %s*%s + %s]", operand.Size, operand.Base, operand.Index, operand.Scale, operand.Disp)
+	}
+
+	if disp, ok := operand.Disp.(Literal); ok && disp < 0 {
+		return fmt.Sprintf("%s[%s - %s]", operand.Size, operand.Base, -disp)
 	}
 
 	if operand.Disp != nil {
@@ -95,8 +99,8 @@ func (operand otherOperand) String() (str string) {
 	return string(operand)
 }
 
-var PC = otherOperand("PC")
-var SR = otherOperand("SR")
+var PC = otherOperand("%pc")
+var SR = otherOperand("%sr")
 
 type Size uint8
 
@@ -146,7 +150,7 @@ const (
 	Q0
 	Q1
 	SP
-	KT
+	AT
 )
 
 var RegisterNames = []string{
@@ -165,7 +169,7 @@ var RegisterNames = []string{
 	"q0",
 	"q1",
 	"sp",
-	"kt",
+	"at",
 }
 
 const (
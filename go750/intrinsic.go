@@ -0,0 +1,188 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IntrinsicsPath is the import path of the package whose functions are compiled to single
+// instructions instead of calls.
+const IntrinsicsPath = "github.com/kierdavis/go/k750"
+
+// The status register bit that allows interrupts, as k750.InterruptEnable.
+const interruptEnable = BitReg(0)
+
+// intrinsic returns the name of the k750 function that e names, if it does.
+func (c *Compiler) intrinsic(e ast.Expr) (name string, ok bool) {
+	var ident *ast.Ident
+
+	switch node := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		ident = node
+	case *ast.SelectorExpr:
+		ident = node.Sel
+	default:
+		return "", false
+	}
+
+	fn, ok := c.Info.Uses[ident].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != IntrinsicsPath {
+		return "", false
+	}
+
+	return fn.Name(), true
+}
+
+// findHandlers records the functions in files that are annotated as interrupt handlers.
+func (c *Compiler) findHandlers(files []*ast.File) {
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || fd.Doc == nil {
+				continue
+			}
+
+			for _, comment := range fd.Doc.List {
+				args, ok := strings.CutPrefix(comment.Text, "//k750:interrupt")
+				if !ok || (args != "" && args[0] != ' ' && args[0] != '\t') {
+					continue
+				}
+
+				n := -1
+
+				if args = strings.TrimSpace(args); args != "" {
+					var err error
+					n, err = strconv.Atoi(args)
+
+					if err != nil || n < 0 || n > 255 {
+						c.errorf(comment.Pos(), "invalid interrupt number: %s", args)
+						continue
+					}
+				}
+
+				if fn, ok := c.Info.Defs[fd.Name].(*types.Func); ok {
+					c.Handlers[fn] = n
+				}
+			}
+		}
+	}
+}
+
+// emitHandlerSetup registers the interrupt handlers that were given an interrupt number.
+func emitHandlerSetup(c *Compiler) {
+	var handlers []*types.Func
+	for fn, n := range c.Handlers {
+		if n >= 0 {
+			handlers = append(handlers, fn)
+		}
+	}
+
+	sort.Slice(handlers, func(i, j int) bool {
+		return c.Handlers[handlers[i]] < c.Handlers[handlers[j]]
+	})
+
+	for _, fn := range handlers {
+		emit("rih", Literal(c.Handlers[fn]), Label(fn.Name()))
+	}
+}
+
+// bitReg returns the constant bit register given by e.
+func (fn *Function) bitReg(e ast.Expr) (b BitReg, ok bool) {
+	value, ok := fn.constant(e)
+	if !ok || value < 0 || value > 15 {
+		fn.errorf(e.Pos(), "the bit register must be a constant from 0 to 15")
+		return 0, false
+	}
+
+	return BitReg(value), true
+}
+
+// callIntrinsic compiles a call to the named k750 function, leaving any result in register r.
+func (fn *Function) callIntrinsic(name string, node *ast.CallExpr, r int) {
+	args := node.Args
+
+	switch name {
+	case "ReadPeripheral":
+		if fn.needRegs(node.Pos(), r, 2) {
+			emit("ppr", reg(r), fn.operand(args[0], r), fn.operand(args[1], r+1))
+		}
+
+	case "WritePeripheral":
+		if fn.needRegs(node.Pos(), r, 3) {
+			emit("ppw", fn.operand(args[0], r), fn.operand(args[1], r+1), fn.operand(args[2], r+2))
+		}
+
+	case "InterruptPeripheral":
+		if fn.needRegs(node.Pos(), r, 2) {
+			emit("ppi", fn.operand(args[0], r), fn.operand(args[1], r+1))
+		}
+
+	case "NumPeripherals":
+		emit("ppn", reg(r))
+
+	case "SetBit", "ClearBit":
+		if b, ok := fn.bitReg(args[0]); ok {
+			if name == "SetBit" {
+				emit("sb", b)
+			} else {
+				emit("cb", b)
+			}
+		}
+
+	case "TestBit":
+		if b, ok := fn.bitReg(args[0]); ok {
+			set := fn.newLabel()
+
+			emit("mov", reg(r), Literal(1))
+			emit("jbs", b, Label(set))
+			emit("mov", reg(r), Literal(0))
+			emitLabel(set)
+		}
+
+	case "EnableInterrupts":
+		emit("sb", interruptEnable)
+
+	case "DisableInterrupts":
+		emit("cb", interruptEnable)
+
+	case "SetHandler":
+		handler := fn.function(args[1])
+		if _, ok := fn.Handlers[handler]; handler == nil || !ok {
+			fn.errorf(args[1].Pos(), "the handler must be a function annotated with //k750:interrupt")
+			return
+		}
+
+		emit("rih", fn.operand(args[0], r), Label(handler.Name()))
+
+	case "Interrupt":
+		emit("int", fn.operand(args[0], r))
+
+	case "Peek8", "Peek16", "Peek32":
+		emit("mov", reg(r), fn.pokeOperand(name[4:], args[0], r))
+
+	case "Poke8", "Poke16", "Poke32":
+		if fn.needRegs(node.Pos(), r, 2) {
+			dest := fn.pokeOperand(name[4:], args[0], r)
+			emit("mov", dest, fn.operand(args[1], r+1))
+		}
+
+	default:
+		fn.errorf(node.Pos(), "k750.%s is not an intrinsic", name)
+	}
+}
+
+// pokeOperand returns a memory operand of the given width in bits at the address given by addr.
+// Register r is used unless the address is constant and the width is 32.
+func (fn *Function) pokeOperand(width string, addr ast.Expr, r int) (operand Operand) {
+	size := map[string]Size{"8": Byte, "16": Half, "32": Word}[width]
+
+	if value, ok := fn.constant(addr); ok && size == Word {
+		return LitMemRef{Literal(value)}
+	}
+
+	fn.expr(addr, r)
+	return MemRef{Size: size, Base: reg(r)}
+}
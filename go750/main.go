@@ -1,72 +1,329 @@
+// Command go750 compiles a subset of Go for the K750.
+//
+//	go750 [asm] [-o file.asm] dir
+//	go750 build [-o file.bin] dir
+//	go750 run dir
+//
+// asm writes K750 assembly for the main package in dir to standard output, or to a file; it can be
+// assembled with k750asm. build assembles it into a flat image, named after the directory by
+// default. run executes the image in the emulator with a serial port as peripheral 0, connected to
+// standard input and output, until main returns; if the program panics, go750 exits with status 2.
+// Problems found by the assembler or the emulator are reported at the position in the Go source
+// that the offending code was compiled from.
+//
+// The subset covers integers of up to 32 bits, bool, pointers, arrays and structs; package-level
+// and local variables and constants; assignments, including composite literals; arithmetic,
+// bitwise, shift, comparison and logical operators; if, for, range (over arrays and integers) and
+// switch statements with break, continue and fallthrough; calls to functions in the same package;
+// and returns. Function parameters and results must be scalars, so arrays and structs are passed by
+// pointer. Maps, slices, strings, interfaces, floats, goroutines, defer and closures are not
+// supported, and are reported as errors at their position in the source, as is anything else
+// outside the subset.
+//
+// # Calling convention
+//
+// Arguments are pushed onto the stack from last to first, so the first argument ends up at the
+// lowest address, and the caller removes them once the call returns. The result is returned in
+// v0. v0-v7 may be overwritten by the callee; q1 (the frame pointer) and sp are preserved.
+//
+// A function starts by pushing q1, copying sp into it and then reserving space below it for its
+// local variables, so that during its execution the frame looks like this:
+//
+//	32[%q1 + 8 + 4*i]   argument i
+//	32[%q1 + 4]         return address
+//	32[%q1]             caller's frame pointer
+//	32[%q1 - 4 - 4*j]   local variable j
+//
+// It returns by restoring sp from q1, popping q1 and then the return address.
+//
+// The program starts by pointing sp at StackTop, calling any init functions and then main; when
+// main returns, the CPU spins at __exit. Multiplication, division, remainder and shifts by a
+// variable count have no K750 instruction, so they call runtime routines that are appended to the
+// program when used.
+//
+// Package-level variables are placed in the data section, which is assumed to start out zeroed;
+// those with initialisers are set by init.vars before any init function runs. Locals live in the
+// frame, so a pointer to one must not be used after its function returns. An out of range array
+// index or a call to panic jumps to __panic, where the CPU spins.
+//
+// # Hardware access
+//
+// Programs can import github.com/kierdavis/go/k750 to reach the hardware. Calls to its functions
+// are compiled to single instructions: ppr and ppw for peripheral registers, sb, cb, jbs and jbc for
+// status register bits, rih and int for interrupts, and mov for raw memory access. A function
+// annotated with //k750:interrupt is compiled as an interrupt handler, which saves v0-v7 before
+// setting up its frame and returns with reti; handlers given an interrupt number are registered
+// before init.vars is called.
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/kierdavis/ansi"
-	"github.com/kierdavis/argparse"
 	"go/ast"
+	"go/importer"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 )
 
-var Output = os.Stdout
+// StackTop is the initial value of the stack pointer. The stack grows down from here.
+const StackTop = 0x10000
+
+// Output is the assembly being generated.
+var Output *Assembly
 
 func emit(name string, operands ...Operand) {
-	fmt.Fprintln(Output, NewInstruction(name, operands...).String())
+	fmt.Fprintln(Output, "    "+NewInstruction(name, operands...).String())
 }
 
 func emitLabel(name string) {
 	fmt.Fprintln(Output, name+":")
 }
 
-func emitHeader() {
-	emit("mov", PC, Label("main"))
+func emitHeader(c *Compiler, varInit bool, inits int) {
+	emit("mov", SP, Literal(StackTop))
+	emitHandlerSetup(c)
+
+	if varInit {
+		emit("call", Label("init.vars"))
+	}
+
+	for i := 1; i <= inits; i++ {
+		emit("call", Label(fmt.Sprintf("init.%d", i)))
+	}
+
+	emit("call", Label("main"))
+	emitLabel("__exit")
+	emit("jmp", Label("__exit"))
+	emitLabel("__panic")
+	emit("jmp", Label("__panic"))
 }
 
-func emitFooter() {
+func emitFooter(c *Compiler) {
+	emitRuntime(c.Runtime)
+
+	if len(c.Globals) > 0 {
+		emit(".section", Label("data"))
 
+		for _, global := range c.Globals {
+			emitLabel(global.Name)
+			emit(".space", Literal(c.sizeOf(c.Info.TypeOf(global))))
+		}
+	}
 }
 
-type Args struct {
-	Dir string
+// emitVarInit emits init.vars, which sets the package-level variables that have initialisers.
+func emitVarInit(c *Compiler) {
+	idents := make(map[types.Object]*ast.Ident)
+	for _, global := range c.Globals {
+		idents[c.Info.Defs[global]] = global
+	}
+
+	fn := newFunction(c, "init.vars", types.NewSignatureType(nil, nil, nil, nil, nil, false))
+	fn.begin()
+
+	for _, init := range c.Info.InitOrder {
+		if len(init.Lhs) != 1 {
+			c.errorf(init.Rhs.Pos(), "multiple-value expressions are not supported")
+			continue
+		}
+
+		if ident, ok := idents[init.Lhs[0]]; ok {
+			Output.SetPos(ident.Pos())
+			fn.assign(ident, init.Rhs, 0, true)
+		}
+	}
+
+	fn.end(true)
 }
 
+const usage = `usage: go750 [asm] [-o file.asm] dir
+       go750 build [-o file.bin] dir
+       go750 run dir
+`
+
 func main() {
-	p := argparse.New("A minimal Go compiler for K750")
-	p.Argument("Dir", 1, argparse.Store, "dir", "The package directory to compile. It should contain a 'main' package.")
+	args := os.Args[1:]
+	cmd := "asm"
 
-	args := &Args{}
-	err := p.Parse(args)
-	if err != nil {
-		if cmdLineErr, ok := err.(argparse.CommandLineError); ok {
-			ansi.Fprintln(os.Stderr, ansi.RedBold, string(cmdLineErr))
-			p.Usage()
-			os.Exit(2)
-
-		} else {
-			ansi.Fprintf(os.Stderr, ansi.RedBold, "Error: %s\n", err.Error())
-			os.Exit(1)
+	if len(args) > 0 {
+		switch args[0] {
+		case "asm", "build", "run":
+			cmd, args = args[0], args[1:]
+		}
+	}
+
+	flags := flag.NewFlagSet("go750 "+cmd, flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+
+	var outName string
+	switch cmd {
+	case "asm":
+		flags.StringVar(&outName, "o", "", "write the assembly to this file instead of standard output")
+	case "build":
+		flags.StringVar(&outName, "o", "", "write the image to this file (default: package directory name + .bin)")
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		ansi.Fprintln(os.Stderr, ansi.RedBold, "Expected a single package directory")
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	dir := flags.Arg(0)
+
+	fset, asm, errs := compileDir(dir)
+	check(errs)
+
+	switch cmd {
+	case "asm":
+		if outName == "" {
+			_, err := asm.WriteTo(os.Stdout)
+			check(errorList(err))
+			return
+		}
+
+		check(errorList(ioutil.WriteFile(outName, asm.Bytes(), 0666)))
+
+	case "build":
+		image, errs := assemble(fset, asm, dir)
+		check(errs)
+
+		if outName == "" {
+			abs, err := filepath.Abs(dir)
+			check(errorList(err))
+			outName = filepath.Base(abs) + ".bin"
+		}
+
+		check(errorList(ioutil.WriteFile(outName, image.Bytes(), 0666)))
+
+	case "run":
+		image, errs := assemble(fset, asm, dir)
+		check(errs)
+
+		status, err := run(fset, asm, image)
+		if err != nil {
+			ansi.Fprintf(os.Stderr, ansi.RedBold, "%s\n", err.Error())
 		}
+
+		os.Exit(status)
+	}
+}
+
+// check prints errs and exits if there are any.
+func check(errs []error) {
+	if len(errs) == 0 {
+		return
+	}
+
+	for _, err := range errs {
+		ansi.Fprintf(os.Stderr, ansi.RedBold, "%s\n", err.Error())
 	}
 
-	fset := token.NewFileSet()
-	pkgs, err := parser.ParseDir(fset, args.Dir, nil, parser.DeclarationErrors)
+	os.Exit(1)
+}
+
+// errorList returns err as a list of errors, or nil if it is nil.
+func errorList(err error) (errs []error) {
 	if err != nil {
-		ansi.Fprintf(os.Stderr, ansi.RedBold, "Error: %s\n", err.Error())
-		os.Exit(1)
+		return []error{err}
+	}
+
+	return nil
+}
+
+// compileDir parses, type-checks and compiles the main package in dir.
+func compileDir(dir string) (fset *token.FileSet, asm *Assembly, errs []error) {
+	fset = token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, nil, parser.DeclarationErrors|parser.ParseComments)
+	if err != nil {
+		return nil, nil, []error{err}
 	}
 
 	pkg, ok := pkgs["main"]
 	if !ok {
-		ansi.Fprintf(os.Stderr, ansi.RedBold, "Error: main package was not found.")
-		os.Exit(1)
+		return nil, nil, []error{fmt.Errorf("%s: main package was not found", dir)}
+	}
+
+	fnames := make([]string, 0, len(pkg.Files))
+	for fname := range pkg.Files {
+		fnames = append(fnames, fname)
+	}
+	sort.Strings(fnames)
+
+	files := make([]*ast.File, len(fnames))
+	for i, fname := range fnames {
+		files[i] = pkg.Files[fname]
+	}
+
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
 	}
 
-	file := ast.MergePackageFiles(pkg, ast.FilterFuncDuplicates|ast.FilterImportDuplicates)
+	config := &types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Sizes:    types.SizesFor("gc", "386"), // 4-byte words and alignment, with padded struct sizes
+		Error:    func(err error) { errs = append(errs, err) },
+	}
+
+	config.Check("main", fset, files, info)
+
+	if len(errs) > 0 {
+		return nil, nil, errs
+	}
+
+	asm, errs = compile(fset, info, config.Sizes, files)
+	return fset, asm, errs
+}
+
+// compile returns the assembly for files, or the errors that prevented it from being generated.
+func compile(fset *token.FileSet, info *types.Info, sizes types.Sizes, files []*ast.File) (asm *Assembly, errs []error) {
+	c := NewCompiler(fset, info, sizes)
+	c.findHandlers(files)
+
+	inits := 0
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok && fd.Recv == nil && fd.Name.Name == "init" {
+				inits++
+			}
+		}
+	}
 
-	emitHeader()
-	defer emitFooter()
+	asm = new(Assembly)
+	Output = asm
+
+	varInit := len(info.InitOrder) > 0
+	emitHeader(c, varInit, inits)
+
+	v := NewFileVisitor(c)
+	for _, file := range files {
+		ast.Walk(v, file)
+	}
+
+	if varInit {
+		emitVarInit(c)
+	}
+
+	emitFooter(c)
+
+	if len(c.Errors) > 0 {
+		sort.SliceStable(c.Errors, func(i, j int) bool {
+			return c.Errors[i].(*CompileError).before(c.Errors[j].(*CompileError))
+		})
+
+		return nil, c.Errors
+	}
 
-	ast.Walk(NewFileVisitor(), file)
+	return asm, nil
 }
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/kierdavis/go/k750/k750asmlib"
+	"github.com/kierdavis/go/k750/k750emlib"
+	"github.com/kierdavis/go/k750/peripheral/k750gs"
+	"go/token"
+	"os"
+)
+
+// run executes image in the emulator, with a serial port connected to standard input and output
+// as peripheral 0, until main returns or the program panics. It returns the status that go750
+// should exit with, and an error describing why the program didn't finish normally, if it didn't.
+func run(fset *token.FileSet, asm *Assembly, image *k750asmlib.Image) (status int, err error) {
+	exitAddr := image.Labels["__exit"]
+	panicAddr := image.Labels["__panic"]
+
+	// Map the address of each instruction back to the line of assembly it came from.
+	lines := make(map[uint32]int)
+	for _, item := range image.Items {
+		if _, ok := item.(*k750asmlib.Instruction); ok {
+			lines[item.Offset()] = item.GetCoord().Lineno
+		}
+	}
+
+	posAt := func(addr uint32) (pos token.Pos) {
+		return asm.Position(lines[addr])
+	}
+
+	in := make(chan byte)
+	out := make(chan byte)
+	done := make(chan bool)
+
+	go func() {
+		r := bufio.NewReader(os.Stdin)
+
+		for {
+			b, err := r.ReadByte()
+			if err != nil {
+				close(in)
+				return
+			}
+
+			in <- b
+		}
+	}()
+
+	go func() {
+		w := bufio.NewWriter(os.Stdout)
+
+		for b := range out {
+			w.WriteByte(b)
+
+			if b == '\n' || len(out) == 0 {
+				w.Flush()
+			}
+		}
+
+		w.Flush()
+		done <- true
+	}()
+
+	serial := k750gs.NewGenericSerial(in, out)
+	serial.Start()
+
+	defer func() {
+		serial.Stop()
+		close(out)
+		<-done
+	}()
+
+	em := k750emlib.NewEmulator()
+	em.LoadImage(image.Bytes(), 0)
+	em.Peripherals = []k750emlib.Peripheral{serial}
+
+	var last uint32
+
+	for {
+		switch em.PC {
+		case exitAddr:
+			return 0, nil
+
+		case panicAddr:
+			return 2, sourceError(fset, posAt(last), "panic", errors.New("panic"))
+		}
+
+		last = em.PC
+
+		if err := em.RunOne(); err != nil {
+			msg := fmt.Sprintf("emulator: %s (at 0x%08X)", err, last)
+			return 1, sourceError(fset, posAt(last), msg, errors.New(msg))
+		}
+	}
+}
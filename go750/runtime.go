@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The K750 has no multiply or divide instructions, and its shift instructions only take a constant
+// count, so these operations call routines that are appended to the program when it uses them.
+// The public routines follow the normal calling convention; __udiv and __sdiv take the dividend
+// in v2 and the divisor in v3 and return the quotient in v0 and the remainder in v1.
+
+type routine struct {
+	name string
+	deps []string
+	code string
+}
+
+var runtimeRoutines = []routine{
+	{"__mul", nil, `
+__mul:
+    mov %v1, 32[%sp + 4]
+    mov %v2, 32[%sp + 8]
+    mov %v0, 0
+__mul.loop:
+    jeq %v2, 0, __mul.done
+    and %v3, %v2, 1
+    jeq %v3, 0, __mul.next
+    add %v0, %v0, %v1
+__mul.next:
+    shl %v1, %v1, 1
+    lshr %v2, %v2, 1
+    jmp __mul.loop
+__mul.done:
+    ret
+`},
+
+	{"__divu", []string{"__udiv"}, `
+__divu:
+    mov %v2, 32[%sp + 4]
+    mov %v3, 32[%sp + 8]
+    call __udiv
+    ret
+`},
+
+	{"__modu", []string{"__udiv"}, `
+__modu:
+    mov %v2, 32[%sp + 4]
+    mov %v3, 32[%sp + 8]
+    call __udiv
+    mov %v0, %v1
+    ret
+`},
+
+	{"__divs", []string{"__sdiv"}, `
+__divs:
+    mov %v2, 32[%sp + 4]
+    mov %v3, 32[%sp + 8]
+    call __sdiv
+    ret
+`},
+
+	{"__mods", []string{"__sdiv"}, `
+__mods:
+    mov %v2, 32[%sp + 4]
+    mov %v3, 32[%sp + 8]
+    call __sdiv
+    mov %v0, %v1
+    ret
+`},
+
+	// Signed division divides the magnitudes, then negates the quotient if the signs of the
+	// operands differ and the remainder if the dividend was negative (v7 bits 0 and 1).
+	{"__sdiv", []string{"__udiv"}, `
+__sdiv:
+    mov %v7, 0
+    jge %v2, 0, __sdiv.1
+    neg %v2, %v2
+    xor %v7, %v7, 3
+__sdiv.1:
+    jge %v3, 0, __sdiv.2
+    neg %v3, %v3
+    xor %v7, %v7, 1
+__sdiv.2:
+    call __udiv
+    and %v6, %v7, 1
+    jeq %v6, 0, __sdiv.3
+    neg %v0, %v0
+__sdiv.3:
+    and %v6, %v7, 2
+    jeq %v6, 0, __sdiv.4
+    neg %v1, %v1
+__sdiv.4:
+    ret
+`},
+
+	// Restoring division, one bit of the quotient per iteration. The remainder is compared with
+	// the divisor as unsigned by flipping both sign bits.
+	{"__udiv", nil, `
+__udiv:
+    mov %v0, 0
+    mov %v1, 0
+    mov %v4, 32
+__udiv.loop:
+    jeq %v4, 0, __udiv.done
+    sub %v4, %v4, 1
+    shl %v1, %v1, 1
+    lshr %v5, %v2, 31
+    or %v1, %v1, %v5
+    shl %v2, %v2, 1
+    shl %v0, %v0, 1
+    xor %v5, %v1, 2147483648
+    xor %v6, %v3, 2147483648
+    jlt %v5, %v6, __udiv.loop
+    sub %v1, %v1, %v3
+    or %v0, %v0, 1
+    jmp __udiv.loop
+__udiv.done:
+    ret
+`},
+
+	{"__shl", nil, shiftRoutine("__shl", "shl")},
+	{"__lshr", nil, shiftRoutine("__lshr", "lshr")},
+	{"__ashr", nil, shiftRoutine("__ashr", "ashr")},
+}
+
+// shiftRoutine returns a routine that shifts by one bit at a time. At most 32 shifts are done,
+// which gives the right result for larger counts too.
+func shiftRoutine(name string, inst string) (code string) {
+	return strings.Replace(fmt.Sprintf(`
+NAME:
+    mov %%v0, 32[%%sp + 4]
+    mov %%v1, 32[%%sp + 8]
+    mov %%v2, 32
+NAME.loop:
+    jeq %%v1, 0, NAME.done
+    jeq %%v2, 0, NAME.done
+    %s %%v0, %%v0, 1
+    sub %%v1, %%v1, 1
+    sub %%v2, %%v2, 1
+    jmp NAME.loop
+NAME.done:
+    ret
+`, inst), "NAME", name, -1)
+}
+
+// emitRuntime emits the routines in used, along with the ones they call.
+func emitRuntime(used map[string]bool) {
+	changed := true
+	for changed {
+		changed = false
+
+		for _, r := range runtimeRoutines {
+			if !used[r.name] {
+				continue
+			}
+
+			for _, dep := range r.deps {
+				if !used[dep] {
+					used[dep] = true
+					changed = true
+				}
+			}
+		}
+	}
+
+	for _, r := range runtimeRoutines {
+		if used[r.name] {
+			fmt.Fprint(Output, r.code)
+		}
+	}
+}
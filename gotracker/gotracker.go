@@ -1,7 +1,10 @@
 package gotracker
 
 import (
+	"github.com/kierdavis/go/audio"
 	"github.com/kierdavis/go/musical"
+	"github.com/kierdavis/go/sound"
+	"time"
 
 //	"math"
 )
@@ -14,14 +17,25 @@ const (
 	Rest
 )
 
+// A Song plays its patterns in the order given by Order, which holds indices into Patterns. If
+// Order is empty, each pattern is played once, in turn.
 type Song struct {
 	SongData	*SongData
 	Patterns	[]*Pattern
+	Order		[]int
 }
 
 func (song *Song) Render(stream musical.Stream) {
-	for _, pattern := range song.Patterns {
-		pattern.Render(stream)
+	if len(song.Order) == 0 {
+		for _, pattern := range song.Patterns {
+			pattern.Render(stream)
+		}
+
+		return
+	}
+
+	for _, i := range song.Order {
+		song.Patterns[i].Render(stream)
 	}
 }
 
@@ -69,11 +83,16 @@ func (channel *Channel) Render(stream musical.Stream) {
 	var note musical.Note
 
 	for _, beat := range channel.Beats {
-		if beat.Type == None {
+		if beat.Type == None && notelength > 0 {
 			notelength++
+		} else if beat.Type == None {
+			// Continuing a rest, or the start of the channel
+			for i := 0; i < int(channel.SongData.SamplesPerBeat); i++ {
+				stream <- 0.0
+			}
 		} else {
 			if notelength > 0 {
-				channel.Instrument.Render(note, channel.SongData.SampleRate, channel.SongData.SamplesPerBeat*notelength, stream)
+				channel.Instrument.Render(note, channel.SongData.Format, channel.SongData.SamplesPerBeat*notelength, stream)
 
 				notelength = 0
 			}
@@ -91,7 +110,7 @@ func (channel *Channel) Render(stream musical.Stream) {
 	}
 
 	if notelength > 0 {
-		channel.Instrument.Render(note, channel.SongData.SampleRate, channel.SongData.SamplesPerBeat*notelength, stream)
+		channel.Instrument.Render(note, channel.SongData.Format, channel.SongData.SamplesPerBeat*notelength, stream)
 	}
 
 	close(stream)
@@ -102,11 +121,21 @@ type Beat struct {
 	Note	musical.Note
 }
 
+// An Instrument plays notes either from recorded samples or, if Patch is not nil, on a
+// synthesizer. Samples are pitched to the note from the closest one; if PreserveLength is true,
+// this doesn't change their length.
 type Instrument struct {
-	Samples []NoteSamplePair
+	Samples		[]NoteSamplePair
+	Patch		*sound.Patch
+	PreserveLength	bool
 }
 
-func (inst *Instrument) Render(note musical.Note, sampleRate uint, numSamples uint, stream musical.Stream) {
+func (inst *Instrument) Render(note musical.Note, format audio.Format, numSamples uint, stream musical.Stream) {
+	if inst.Patch != nil {
+		inst.synthesize(note, format, numSamples, stream)
+		return
+	}
+
 	// Find the closest sample
 
 	var closestNote musical.Note
@@ -126,14 +155,59 @@ func (inst *Instrument) Render(note musical.Note, sampleRate uint, numSamples ui
 		}
 	}
 
-	if closestNote == note {
-		closestSample.Play(sampleRate, numSamples, stream)
+	raw := make(musical.Stream)
+	converted := raw
 
+	if closestNote == note || inst.PreserveLength {
+		closestSample.Play(format, numSamples, raw)
 	} else {
-		raw := make(musical.Stream)
-		closestSample.Play(sampleRate, numSamples, raw)
+		// Raising the pitch shortens the sample, so more of it is needed to fill numSamples.
+		ratio := note.Frequency() / closestNote.Frequency()
+		closestSample.Play(format, uint(float64(numSamples)*ratio)+1, raw)
+	}
+
+	if closestNote != note {
+		converted = make(musical.Stream)
+
+		if inst.PreserveLength {
+			go ShiftPitch(raw, converted, closestNote, note, format)
+		} else {
+			go ConvertPitch(raw, converted, closestNote, note, format)
+		}
+	}
+
+	// Send exactly numSamples, padding with silence, so that the channel stays in time.
+	var i uint
+
+	for v := range converted {
+		if i < numSamples {
+			stream <- v
+			i++
+		}
+	}
 
-		ConvertPitch(raw, stream, closestNote, note, sampleRate)
+	for ; i < numSamples; i++ {
+		stream <- 0.0
+	}
+}
+
+// synthesize plays note on a voice of the instrument's patch. The note is released early enough for
+// the release of the envelope to finish within numSamples.
+func (inst *Instrument) synthesize(note musical.Note, format audio.Format, numSamples uint, stream musical.Stream) {
+	voice := sound.NewVoice(format, *inst.Patch, note, 1.0)
+
+	gate := format.Duration(int(numSamples)) - inst.Patch.Envelope.ReleaseTime()
+	if gate < 0 {
+		gate = 0
+	}
+
+	voice.ReleaseAfter(gate)
+
+	buf := make([]sound.Sample, numSamples)
+	voice.Process(buf)
+
+	for _, sample := range buf {
+		stream <- sample.Mono()
 	}
 }
 
@@ -146,7 +220,7 @@ type Sample struct {
 	Data []float64
 }
 
-func (sample *Sample) Play(sampleRate uint, numSamples uint, stream musical.Stream) {
+func (sample *Sample) Play(format audio.Format, numSamples uint, stream musical.Stream) {
 	data := sample.Data
 
 	if numSamples < uint(len(data)) {
@@ -163,7 +237,7 @@ func (sample *Sample) Play(sampleRate uint, numSamples uint, stream musical.Stre
 }
 
 type SongData struct {
-	SampleRate	uint
+	Format		audio.Format
 	Tempo		uint
 	BeatLength	float32
 	SamplesPerBeat	uint
@@ -171,53 +245,61 @@ type SongData struct {
 
 func (sd *SongData) Calc() {
 	sd.BeatLength = 1 / (float32(sd.Tempo) / 60)
-	sd.SamplesPerBeat = uint(float32(sd.SampleRate) * sd.BeatLength)
+	sd.SamplesPerBeat = uint(float32(sd.Format.SampleRate) * sd.BeatLength)
 }
 
-func ConvertPitch(in, out musical.Stream, inNote, outNote musical.Note, sampleRate uint) {
-	newRate := uint((outNote.Frequency() * float64(sampleRate)) / inNote.Frequency())
+// ConvertPitch changes the pitch of the samples from in from inNote to outNote by resampling
+// them, which also changes their length, as playing a recording faster or slower does. It closes
+// out once in is closed.
+func ConvertPitch(in, out musical.Stream, inNote, outNote musical.Note, format audio.Format) {
+	newRate := (outNote.Frequency() * format.SampleRate) / inNote.Frequency()
 
-	// Playing the audio at sampleRate makes it sound like inNote
+	// Playing the audio at format.SampleRate makes it sound like inNote
 	// Playing the audio at newRate makes it sound like outNote
-	// So we need to convert it back to sampleRate
+	// So we need to convert it back to format.SampleRate
 
-	ConvertRate(in, out, newRate, sampleRate)
+	ConvertRate(in, out, newRate, format.SampleRate)
 }
 
-func ConvertRate(in, out musical.Stream, inRate, outRate uint) {
-	gcd := GCD(inRate, outRate)
-	inRate /= gcd
-	outRate /= gcd
-
-	partial := make(musical.Stream)
+// pitchShiftWindow is the window used by ShiftPitch.
+const pitchShiftWindow = time.Millisecond * 40
 
-	go func() {
-		a := <-in
+// ShiftPitch changes the pitch of the samples from in from inNote to outNote without changing
+// their length. It closes out once in is closed.
+func ShiftPitch(in, out musical.Stream, inNote, outNote musical.Note, format audio.Format) {
+	ratio := outNote.Frequency() / inNote.Frequency()
+	f := sound.NewPitchShifter(format, ratio, pitchShiftWindow)
+	samplesToStream(sound.GoApplyFilter(f, streamToSamples(in)), out)
+}
 
-		for {
-			b := <-in
-			step := (b - a) / float64(outRate)
+// ConvertRate converts the samples from in, which are at inRate, to outRate, using sound's
+// windowed-sinc resampler. It closes out once in is closed.
+func ConvertRate(in, out musical.Stream, inRate, outRate float64) {
+	samplesToStream(sound.GoResample(inRate, outRate, streamToSamples(in)), out)
+}
 
-			partial <- a
+// streamToSamples returns a channel of the samples from in, on both channels.
+func streamToSamples(in musical.Stream) (out chan sound.Sample) {
+	out = make(chan sound.Sample, sound.ChannelBuffer)
 
-			for i := uint(0); i < outRate-1; i++ {
-				a += step
-				partial <- a
-			}
+	go func() {
+		defer close(out)
 
-			a = b
+		for v := range in {
+			out <- sound.Sample{Left: v, Right: v}
 		}
 	}()
 
-	go func() {
-		for {
-			out <- <-partial
+	return out
+}
 
-			for i := uint(0); i < inRate-1; i++ {
-				<-partial
-			}
-		}
-	}()
+// samplesToStream sends the samples from in, mixed down to mono, to out, and closes it.
+func samplesToStream(in chan sound.Sample, out musical.Stream) {
+	defer close(out)
+
+	for sample := range in {
+		out <- sample.Mono()
+	}
 }
 
 func GCD(a, b uint) (gcd uint) {
@@ -0,0 +1,117 @@
+// Command gotracker renders a song to a WAV file, or converts it between the text and JSON song
+// formats described in the gotracker package.
+//
+//	gotracker [-bits n] [-float] song out.wav
+//	gotracker song out.json
+//	gotracker song out.song
+//
+// The song is read as JSON if its name ends in .json, or as text otherwise. If the output file's
+// name ends in .wav, the song is rendered to it, in mono at the song's sample rate, with -bits
+// bits per sample (16 by default) or as 32-bit floats if -float is given. Otherwise, the song is
+// written out as JSON or text, again chosen by the file's extension.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/kierdavis/go/gotracker"
+	"github.com/kierdavis/go/musical"
+	"github.com/kierdavis/go/sound"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const usage = `usage: gotracker [-bits n] [-float] song out.wav
+       gotracker song out.json
+       gotracker song out.song
+`
+
+func main() {
+	bits := flag.Int("bits", 16, "bits per sample in the WAV file: 8, 16, 24 or 32")
+	float := flag.Bool("float", false, "write 32-bit float samples")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	in, out := flag.Arg(0), flag.Arg(1)
+
+	sf, err := gotracker.ReadSongFile(in)
+	if err != nil {
+		fail(err)
+	}
+
+	if !strings.EqualFold(filepath.Ext(out), ".wav") {
+		err = gotracker.WriteSongFile(out, sf)
+		if err != nil {
+			fail(err)
+		}
+
+		return
+	}
+
+	// Work at the song's rate throughout, so that samples are only converted once, when they're
+	// loaded.
+	format := sf.Format()
+
+	song, err := sf.Song(filepath.Dir(in))
+	if err != nil {
+		fail(err)
+	}
+
+	opts := sound.WAVOptions{Format: format, BitDepth: *bits, Float: *float}
+	if opts.Float {
+		opts.BitDepth = 32
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		fail(err)
+	}
+
+	w := bufio.NewWriter(f)
+
+	err = sound.WriteWAVOptions(w, format, opts, render(song))
+	if err == nil {
+		err = w.Flush()
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		fail(err)
+	}
+}
+
+// render renders the song in the background, returning a channel of its samples.
+func render(song *gotracker.Song) (out chan sound.Sample) {
+	stream := make(musical.Stream, sound.ChannelBuffer)
+	out = make(chan sound.Sample, sound.ChannelBuffer)
+
+	go func() {
+		song.Render(stream)
+		close(stream)
+	}()
+
+	go func() {
+		defer close(out)
+
+		for x := range stream {
+			out <- sound.Sample{Left: x, Right: x}
+		}
+	}()
+
+	return out
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "gotracker: %s\n", err)
+	os.Exit(1)
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"fmt"
+	"github.com/kierdavis/go/audio"
 	"github.com/kierdavis/go/gotracker"
 	"github.com/kierdavis/go/musical"
+	"github.com/kierdavis/go/sound"
+	"time"
 )
 
 func main() {
@@ -10,8 +14,18 @@ func main() {
 	outNote := musical.ParseNote("e4")
 
 	input := make(musical.Stream)
-	musical.Sine(inNote.Frequency(), 44100, 0.0, input)
+	musical.Sine(inNote.Frequency(), audio.CD, 0.0, input)
 
 	output := make(musical.Stream)
-	gotracker.ConvertPitch(input, output, inNote, outNote, 44100)
+	go gotracker.ConvertPitch(input, output, inNote, outNote, audio.CD)
+
+	// Check the pitch of the first tenth of a second of the output.
+	samples := make([]sound.Sample, audio.CD.NumSamples(time.Second/10))
+	for i := range samples {
+		x := <-output
+		samples[i] = sound.Sample{Left: x, Right: x}
+	}
+
+	note, cents, ok := sound.DetectNote(audio.CD, samples)
+	fmt.Printf("Converted %s to %s: detected %s%+.1f cents (%v)\n", inNote, outNote, note, cents, ok)
 }
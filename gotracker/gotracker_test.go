@@ -0,0 +1,81 @@
+package gotracker
+
+import (
+	"github.com/kierdavis/go/audio"
+	"github.com/kierdavis/go/musical"
+	"github.com/kierdavis/go/sound"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestConvertPitch(t *testing.T) {
+	conversions := []struct {
+		in, out string
+	}{
+		{"c4", "e4"},
+		{"a4", "a3"},
+		{"c4", "g5"},
+		{"e2", "c3"},
+	}
+
+	format := audio.CD
+
+	for _, c := range conversions {
+		inNote, outNote := musical.ParseNote(c.in), musical.ParseNote(c.out)
+
+		input := make(musical.Stream, format.NumSamples(time.Second/2))
+		for i := 0; i < cap(input); i++ {
+			input <- math.Sin(2.0 * math.Pi * inNote.Frequency() * float64(i) / format.SampleRate)
+		}
+
+		close(input)
+
+		output := make(musical.Stream)
+		go ConvertPitch(input, output, inNote, outNote, format)
+
+		var samples []sound.Sample
+		for x := range output {
+			samples = append(samples, sound.Sample{Left: x, Right: x})
+		}
+
+		// Leave out the edges, where the resampler's filter runs off the end of the input.
+		edge := format.NumSamples(time.Second / 20)
+		note, cents, ok := sound.DetectNote(format, samples[edge:len(samples)-edge])
+
+		if !ok || note != outNote || math.Abs(cents) > 5.0 {
+			t.Errorf("Converting %s to %s gave %s%+.1f cents (detected: %v)", inNote, outNote, note, cents, ok)
+		}
+	}
+}
+
+func TestChannelRestIsSilent(t *testing.T) {
+	songData := &SongData{Format: audio.CD.Mono(), Tempo: 120}
+	songData.Calc()
+
+	patch := &sound.Patch{Waveform: sound.SineWave, Envelope: sound.ADSR(0, 0, 1.0, 0), Gain: 1.0}
+
+	channels := [][]Beat{
+		{{Type: Rest}, {Type: None}},
+		{{Type: None}, {Type: None}},
+	}
+
+	for _, beats := range channels {
+		channel := &Channel{SongData: songData, Instrument: &Instrument{Patch: patch}, Beats: beats}
+		stream := make(musical.Stream)
+		go channel.Render(stream)
+
+		n := 0
+		for x := range stream {
+			if x != 0 {
+				t.Fatalf("Channel %v rendered a sound at sample %d", beats, n)
+			}
+
+			n++
+		}
+
+		if n != 2*int(songData.SamplesPerBeat) {
+			t.Errorf("Channel %v rendered %d samples, expected %d", beats, n, 2*songData.SamplesPerBeat)
+		}
+	}
+}
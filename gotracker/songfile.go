@@ -0,0 +1,543 @@
+package gotracker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/kierdavis/go/audio"
+	"github.com/kierdavis/go/musical"
+	"github.com/kierdavis/go/sound"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SongFile describes a song in a form that can be saved and loaded, either as text (see ReadText)
+// or as JSON. Song turns it into a Song that can be rendered.
+type SongFile struct {
+	Tempo       uint             `json:"tempo"`                 // Beats per minute
+	SampleRate  float64          `json:"sample_rate,omitempty"` // 44100 if not given
+	Instruments []InstrumentFile `json:"instruments"`
+	Patterns    []PatternFile    `json:"patterns"`
+	Order       []string         `json:"order,omitempty"` // Pattern names; every pattern in turn if not given
+}
+
+// InstrumentFile describes an instrument, which plays either recorded samples or a synthesizer.
+type InstrumentFile struct {
+	Name           string       `json:"name"`
+	Samples        []SampleFile `json:"samples,omitempty"`
+	Synth          *SynthFile   `json:"synth,omitempty"`
+	PreserveLength bool         `json:"preserve_length,omitempty"`
+}
+
+// SampleFile is a recording of an instrument playing Note, stored in a WAV file. The file name is
+// relative to the directory holding the song.
+type SampleFile struct {
+	Note string `json:"note"`
+	File string `json:"file"`
+}
+
+// SynthFile describes a synthesizer patch with an ADSR envelope. The waveform is one of sine, saw,
+// triangle or square, and the times are durations such as "10ms". Sustain and Gain default to 1
+// when they are left out of a file.
+type SynthFile struct {
+	Waveform string  `json:"waveform"`
+	Attack   string  `json:"attack,omitempty"`
+	Decay    string  `json:"decay,omitempty"`
+	Sustain  float64 `json:"sustain"`
+	Release  string  `json:"release,omitempty"`
+	Gain     float64 `json:"gain"`
+}
+
+func newSynthFile(waveform string) (synth *SynthFile) {
+	return &SynthFile{Waveform: waveform, Sustain: 1.0, Gain: 1.0}
+}
+
+func (s *SynthFile) UnmarshalJSON(data []byte) (err error) {
+	// plain has no UnmarshalJSON method, so decoding into it doesn't recurse.
+	type plain SynthFile
+	v := plain(*newSynthFile(""))
+
+	err = json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+
+	*s = SynthFile(v)
+	return nil
+}
+
+// PatternFile describes a pattern. Each channel plays an instrument, named by Instrument, and its
+// beats are written as in the text format.
+type PatternFile struct {
+	Name     string        `json:"name"`
+	Channels []ChannelFile `json:"channels"`
+}
+
+type ChannelFile struct {
+	Instrument string `json:"instrument"`
+	Beats      string `json:"beats"`
+}
+
+// Waveforms are the waveforms that a SynthFile may name.
+var Waveforms = map[string]sound.Waveform{
+	"sine":     sound.SineWave,
+	"saw":      sound.SawWave,
+	"triangle": sound.TriangleWave,
+	"square":   sound.SquareWave,
+}
+
+// ParseBeats parses a channel's beats, separated by spaces. A note name, such as c4 or f#3, starts
+// a note; "." continues the note (or rest) before it for another beat, and "-" is a rest. "|" can
+// be used to mark bars and is ignored.
+func ParseBeats(s string) (beats []Beat, err error) {
+	for _, field := range strings.Fields(s) {
+		switch field {
+		case ".":
+			beats = append(beats, Beat{Type: None})
+		case "-":
+			beats = append(beats, Beat{Type: Rest})
+		case "|":
+		default:
+			if !isNoteName(field) {
+				return nil, fmt.Errorf("invalid beat %q", field)
+			}
+
+			beats = append(beats, Beat{Type: Hit, Note: musical.ParseNote(field)})
+		}
+	}
+
+	return beats, nil
+}
+
+// isNoteName returns whether s is a note name that musical.ParseNote understands.
+func isNoteName(s string) (ok bool) {
+	s = strings.ToLower(s)
+	if len(s) == 0 || s[0] < 'a' || s[0] > 'g' {
+		return false
+	}
+
+	s = s[1:]
+	if len(s) > 0 && s[len(s)-1] >= '0' && s[len(s)-1] <= '9' {
+		s = s[:len(s)-1]
+	}
+
+	return strings.Trim(s, "#b") == ""
+}
+
+// FormatBeats formats beats as ParseBeats expects them.
+func FormatBeats(beats []Beat) (s string) {
+	fields := make([]string, len(beats))
+
+	for i, beat := range beats {
+		switch beat.Type {
+		case None:
+			fields[i] = "."
+		case Rest:
+			fields[i] = "-"
+		case Hit:
+			fields[i] = beat.Note.String()
+		}
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// ReadJSON reads a song in JSON form.
+func ReadJSON(r io.Reader) (sf *SongFile, err error) {
+	sf = new(SongFile)
+
+	err = json.NewDecoder(r).Decode(sf)
+	if err != nil {
+		return nil, err
+	}
+
+	return sf, nil
+}
+
+// WriteJSON writes a song in JSON form.
+func WriteJSON(w io.Writer, sf *SongFile) (err error) {
+	data, err := json.MarshalIndent(sf, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ReadText reads a song in the text format. Blank lines and anything after a "#" are ignored, and
+// each other line starts with a keyword:
+//
+//	tempo 120
+//	rate 48000
+//	instrument piano
+//	    sample c4 piano-c4.wav
+//	    sample c5 piano-c5.wav
+//	    preserve-length
+//	instrument bass
+//	    synth saw attack=5ms decay=200ms sustain=0.5 release=100ms gain=0.4
+//	pattern intro
+//	    piano: c4 . . e4 | - g4 . .
+//	    bass: c2 . . . | c2 . . .
+//	order intro intro
+//
+// sample, synth and preserve-length lines describe the instrument above them, and lines of the
+// form "instrument: beats" add a channel to the pattern above them; indentation is optional.
+// Beats are written as ParseBeats expects. The rate line and the order line may be left out, as may
+// any of a synth's settings.
+func ReadText(r io.Reader) (sf *SongFile, err error) {
+	sf = new(SongFile)
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+
+	var inst *InstrumentFile
+	var pattern *PatternFile
+
+	for scanner.Scan() {
+		lineNum++
+
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		err = nil
+
+		switch {
+		case strings.HasSuffix(fields[0], ":"):
+			if pattern == nil {
+				err = fmt.Errorf("channel outside a pattern")
+				break
+			}
+
+			channel := ChannelFile{
+				Instrument: strings.TrimSuffix(fields[0], ":"),
+				Beats:      strings.Join(fields[1:], " "),
+			}
+
+			_, err = ParseBeats(channel.Beats)
+			pattern.Channels = append(pattern.Channels, channel)
+
+		case fields[0] == "tempo" && len(fields) == 2:
+			var tempo uint64
+			tempo, err = strconv.ParseUint(fields[1], 10, 0)
+			sf.Tempo = uint(tempo)
+
+		case fields[0] == "rate" && len(fields) == 2:
+			sf.SampleRate, err = strconv.ParseFloat(fields[1], 64)
+
+		case fields[0] == "instrument" && len(fields) == 2:
+			sf.Instruments = append(sf.Instruments, InstrumentFile{Name: fields[1]})
+			inst, pattern = &sf.Instruments[len(sf.Instruments)-1], nil
+
+		case fields[0] == "pattern" && len(fields) == 2:
+			sf.Patterns = append(sf.Patterns, PatternFile{Name: fields[1]})
+			inst, pattern = nil, &sf.Patterns[len(sf.Patterns)-1]
+
+		case fields[0] == "order":
+			sf.Order = append(sf.Order, fields[1:]...)
+
+		case inst != nil && fields[0] == "sample" && len(fields) == 3:
+			if !isNoteName(fields[1]) {
+				err = fmt.Errorf("invalid note %q", fields[1])
+			}
+
+			inst.Samples = append(inst.Samples, SampleFile{Note: fields[1], File: fields[2]})
+
+		case inst != nil && fields[0] == "synth" && len(fields) >= 2:
+			inst.Synth, err = parseSynth(fields[1], fields[2:])
+
+		case inst != nil && fields[0] == "preserve-length" && len(fields) == 1:
+			inst.PreserveLength = true
+
+		default:
+			err = fmt.Errorf("unexpected %q", fields[0])
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNum, err)
+		}
+	}
+
+	return sf, scanner.Err()
+}
+
+// parseSynth parses the waveform and key=value settings of a synth line.
+func parseSynth(waveform string, settings []string) (synth *SynthFile, err error) {
+	synth = newSynthFile(waveform)
+
+	if _, ok := Waveforms[waveform]; !ok {
+		return nil, fmt.Errorf("unknown waveform %q", waveform)
+	}
+
+	for _, setting := range settings {
+		key, value, _ := strings.Cut(setting, "=")
+
+		switch key {
+		case "attack":
+			synth.Attack = value
+		case "decay":
+			synth.Decay = value
+		case "release":
+			synth.Release = value
+		case "sustain":
+			synth.Sustain, err = strconv.ParseFloat(value, 64)
+		case "gain":
+			synth.Gain, err = strconv.ParseFloat(value, 64)
+		default:
+			return nil, fmt.Errorf("unknown synth setting %q", key)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = synth.Patch()
+	return synth, err
+}
+
+// WriteText writes a song in the text format.
+func WriteText(w io.Writer, sf *SongFile) (err error) {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "tempo %d\n", sf.Tempo)
+	if sf.SampleRate != 0 {
+		fmt.Fprintf(bw, "rate %g\n", sf.SampleRate)
+	}
+
+	for _, inst := range sf.Instruments {
+		fmt.Fprintf(bw, "\ninstrument %s\n", inst.Name)
+
+		for _, sample := range inst.Samples {
+			fmt.Fprintf(bw, "    sample %s %s\n", sample.Note, sample.File)
+		}
+
+		if s := inst.Synth; s != nil {
+			fmt.Fprintf(bw, "    synth %s", s.Waveform)
+
+			for _, setting := range [][2]string{{"attack", s.Attack}, {"decay", s.Decay}, {"release", s.Release}} {
+				if setting[1] != "" {
+					fmt.Fprintf(bw, " %s=%s", setting[0], setting[1])
+				}
+			}
+
+			fmt.Fprintf(bw, " sustain=%g gain=%g\n", s.Sustain, s.Gain)
+		}
+
+		if inst.PreserveLength {
+			fmt.Fprintf(bw, "    preserve-length\n")
+		}
+	}
+
+	for _, pattern := range sf.Patterns {
+		fmt.Fprintf(bw, "\npattern %s\n", pattern.Name)
+
+		for _, channel := range pattern.Channels {
+			fmt.Fprintf(bw, "    %s: %s\n", channel.Instrument, channel.Beats)
+		}
+	}
+
+	if len(sf.Order) > 0 {
+		fmt.Fprintf(bw, "\norder %s\n", strings.Join(sf.Order, " "))
+	}
+
+	return bw.Flush()
+}
+
+// ReadSongFile reads a song from a file, as JSON if its name ends in .json or as text otherwise.
+func ReadSongFile(filename string) (sf *SongFile, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		return ReadJSON(f)
+	}
+
+	return ReadText(f)
+}
+
+// WriteSongFile writes a song to a file, as JSON if its name ends in .json or as text otherwise.
+func WriteSongFile(filename string, sf *SongFile) (err error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		err = WriteJSON(f, sf)
+	} else {
+		err = WriteText(f, sf)
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// Patch returns the synthesizer patch that the SynthFile describes.
+func (s *SynthFile) Patch() (patch *sound.Patch, err error) {
+	waveform, ok := Waveforms[s.Waveform]
+	if !ok {
+		return nil, fmt.Errorf("unknown waveform %q", s.Waveform)
+	}
+
+	var times [3]time.Duration
+
+	for i, str := range []string{s.Attack, s.Decay, s.Release} {
+		if str == "" {
+			continue
+		}
+
+		times[i], err = time.ParseDuration(str)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &sound.Patch{
+		Waveform: waveform,
+		Envelope: sound.ADSR(times[0], times[1], s.Sustain, times[2]),
+		Gain:     s.Gain,
+	}, nil
+}
+
+// Format returns the format that the song is rendered in.
+func (sf *SongFile) Format() (format audio.Format) {
+	format = audio.CD.Mono()
+	if sf.SampleRate != 0 {
+		format.SampleRate = sf.SampleRate
+	}
+
+	return format
+}
+
+// Song builds the song that the SongFile describes, loading its samples from WAV files in dir.
+func (sf *SongFile) Song(dir string) (song *Song, err error) {
+	data := &SongData{Format: sf.Format(), Tempo: sf.Tempo}
+	if data.Tempo == 0 {
+		return nil, fmt.Errorf("no tempo given")
+	}
+
+	data.Calc()
+
+	instruments := make(map[string]*Instrument)
+
+	for _, instFile := range sf.Instruments {
+		if instruments[instFile.Name] != nil {
+			return nil, fmt.Errorf("instrument %s is defined twice", instFile.Name)
+		}
+
+		inst, err := instFile.instrument(data.Format, dir)
+		if err != nil {
+			return nil, fmt.Errorf("instrument %s: %s", instFile.Name, err)
+		}
+
+		instruments[instFile.Name] = inst
+	}
+
+	song = &Song{SongData: data}
+	patterns := make(map[string]int)
+
+	for _, patternFile := range sf.Patterns {
+		if _, ok := patterns[patternFile.Name]; ok {
+			return nil, fmt.Errorf("pattern %s is defined twice", patternFile.Name)
+		}
+
+		pattern := &Pattern{SongData: data}
+
+		for _, channelFile := range patternFile.Channels {
+			inst := instruments[channelFile.Instrument]
+			if inst == nil {
+				return nil, fmt.Errorf("pattern %s: unknown instrument %s", patternFile.Name, channelFile.Instrument)
+			}
+
+			beats, err := ParseBeats(channelFile.Beats)
+			if err != nil {
+				return nil, fmt.Errorf("pattern %s: %s", patternFile.Name, err)
+			}
+
+			pattern.Channels = append(pattern.Channels, &Channel{SongData: data, Instrument: inst, Beats: beats})
+		}
+
+		patterns[patternFile.Name] = len(song.Patterns)
+		song.Patterns = append(song.Patterns, pattern)
+	}
+
+	for _, name := range sf.Order {
+		i, ok := patterns[name]
+		if !ok {
+			return nil, fmt.Errorf("order: unknown pattern %s", name)
+		}
+
+		song.Order = append(song.Order, i)
+	}
+
+	return song, nil
+}
+
+// instrument builds the instrument that the InstrumentFile describes.
+func (instFile *InstrumentFile) instrument(format audio.Format, dir string) (inst *Instrument, err error) {
+	inst = &Instrument{PreserveLength: instFile.PreserveLength}
+
+	if instFile.Synth != nil {
+		inst.Patch, err = instFile.Synth.Patch()
+		return inst, err
+	}
+
+	if len(instFile.Samples) == 0 {
+		return nil, fmt.Errorf("no samples or synth given")
+	}
+
+	for _, sampleFile := range instFile.Samples {
+		if !isNoteName(sampleFile.Note) {
+			return nil, fmt.Errorf("invalid note %q", sampleFile.Note)
+		}
+
+		data, err := loadSample(filepath.Join(dir, sampleFile.File), format)
+		if err != nil {
+			return nil, err
+		}
+
+		inst.Samples = append(inst.Samples, NoteSamplePair{musical.ParseNote(sampleFile.Note), Sample{data}})
+	}
+
+	return inst, nil
+}
+
+// loadSample reads a WAV file and returns its samples, mixed down to mono and converted to the rate
+// of format.
+func loadSample(filename string, format audio.Format) (data []float64, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	samples, errs := sound.GoReadWAVFormat(bufio.NewReader(f), format)
+
+	for sample := range samples {
+		data = append(data, sample.Mono())
+	}
+
+	err = <-errs
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	return data, nil
+}
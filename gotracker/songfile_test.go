@@ -0,0 +1,28 @@
+package gotracker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSynthDefaults(t *testing.T) {
+	text := "tempo 120\ninstrument lead\n    synth sine\n"
+	json := `{"tempo": 120, "instruments": [{"name": "lead", "synth": {"waveform": "sine"}}]}`
+
+	fromText, err := ReadText(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("ReadText: %s", err)
+	}
+
+	fromJSON, err := ReadJSON(strings.NewReader(json))
+	if err != nil {
+		t.Fatalf("ReadJSON: %s", err)
+	}
+
+	for _, sf := range []*SongFile{fromText, fromJSON} {
+		synth := sf.Instruments[0].Synth
+		if synth.Gain != 1.0 || synth.Sustain != 1.0 {
+			t.Errorf("Synth with no settings has gain %g and sustain %g, expected 1 and 1", synth.Gain, synth.Sustain)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package ihex
+
+import (
+    "fmt"
+)
+
+// Type ErrorKind identifies a problem found while reading an Intel Hex file.
+type ErrorKind int
+
+const (
+    BadStartCode ErrorKind = iota       // The line doesn't start with a colon.
+    BadHexDigit                         // The record contains a character that isn't a hex digit.
+    OddLength                           // The record has an odd number of hex digits.
+    LengthMismatch                      // The length byte disagrees with the length of the record.
+    ChecksumMismatch                    // The checksum byte is wrong.
+    UnknownRecordType                   // The record type is not 00 to 05.
+    BadRecordData                       // An address record has the wrong amount of data.
+    MissingEOF                          // The file ends without an end of file (01) record.
+    OverlappingData                     // A data record overwrites data from an earlier one.
+)
+
+var errorKindNames = []string{
+    BadStartCode: "bad start code",
+    BadHexDigit: "bad hex digit",
+    OddLength: "odd length",
+    LengthMismatch: "length mismatch",
+    ChecksumMismatch: "checksum mismatch",
+    UnknownRecordType: "unknown record type",
+    BadRecordData: "bad record data",
+    MissingEOF: "missing end of file record",
+    OverlappingData: "overlapping data",
+}
+
+// Function ErrorKind.String returns a short description of the kind of problem.
+func (kind ErrorKind) String() (str string) {
+    if kind >= 0 && int(kind) < len(errorKindNames) {
+        return errorKindNames[kind]
+    }
+
+    return fmt.Sprintf("ErrorKind(%d)", int(kind))
+}
+
+// Type Error describes a problem found while reading an Intel Hex file, and the line it was found
+// on.
+type Error struct {
+    Line int            // The line number, counting from 1.
+    Kind ErrorKind
+    Detail string       // A description of the specific problem.
+}
+
+// Function Error.Error returns the error as a string.
+func (err *Error) Error() (str string) {
+    return fmt.Sprintf("line %d: %s: %s", err.Line, err.Kind, err.Detail)
+}
+
+// Function newError creates an Error of kind `kind` on line `line`, with a detail message formatted
+// from `format` and `args`.
+func newError(line int, kind ErrorKind, format string, args ...interface{}) (err *Error) {
+    return &Error{line, kind, fmt.Sprintf(format, args...)}
+}
@@ -37,19 +37,51 @@
 //     
 //     writer.Flush()
 //     f.Close()
+//
+// Addresses of up to 32 bits are supported. Data above 64 KiB is written after an extended linear
+// address (04) record, or an extended segment address (02) record if the IHex is segmented, and
+// the start address is written as a start linear address (05) or start segment address (03)
+// record.
+//
+// ReadIHex validates the file strictly, returning the first problem as an *Error that gives its
+// kind and line number. ReadIHexLenient reads as much as it can and returns the problems as
+// warnings instead.
+//
+// IHex holds the whole file in memory. To process large files a record at a time, use Reader and
+// Writer instead.
 package ihex
 
 import (
     "bufio"
-    "errors"
+    "encoding/hex"
     "fmt"
     "io"
-    "strings"
+    "sort"
+)
+
+// Record types.
+const (
+    Data                   = 0x00
+    EndOfFile              = 0x01
+    ExtendedSegmentAddress = 0x02
+    StartSegmentAddress    = 0x03
+    ExtendedLinearAddress  = 0x04
+    StartLinearAddress     = 0x05
 )
 
 // Type IHex represents an Intel Hex file.
 type IHex struct {
     areas map[uint][]byte   // A map of area addresses to byte slices.
+    
+    // The address that execution starts at, if HasStartAddress is true. A start segment address
+    // (03) record gives it as CS:IP, which is stored here as the linear address CS * 16 + IP.
+    StartAddress uint
+    HasStartAddress bool
+    
+    // Whether the file uses 8086-style segmented addresses (02 and 03 records) instead of linear
+    // ones (04 and 05 records). Segmented files can only address the first MiB. It is set when a
+    // file containing 02 or 03 records is read.
+    Segmented bool
 }
 
 // Function makeLine constructs an Intel Hex record, including the final newline, from the type `t`,
@@ -68,15 +100,33 @@ func makeLine(t uint, addr uint, data []byte) (line string) {
     return fmt.Sprintf(":%x\n", lineBytes)
 }
 
-// Function parseLine parses an Intel Hex record into its type, address and content.
-func parseLine(rawline string) (t uint, addr uint, data []byte, err error) {
-    if rawline[0] != ':' {
-        return 0, 0, nil, errors.New(fmt.Sprintf("Invalid line start character: %q", rawline[0]))
+// Function parseLine parses an Intel Hex record into its type, address and content. If the record
+// is malformed, it returns an Error without a line number. A checksum mismatch is reported along
+// with the contents of the record, so that they can still be used.
+func parseLine(rawline string) (t uint, addr uint, data []byte, err *Error) {
+    if len(rawline) == 0 || rawline[0] != ':' {
+        return 0, 0, nil, newError(0, BadStartCode, "expected a colon (:), found %q", rawline[:min(len(rawline), 1)])
+    }
+    
+    digits := rawline[1:]
+    if len(digits) % 2 != 0 {
+        return 0, 0, nil, newError(0, OddLength, "found %d hex digits", len(digits))
+    }
+    
+    line, decodeErr := hex.DecodeString(digits)
+    if decodeErr != nil {
+        return 0, 0, nil, newError(0, BadHexDigit, "%s", decodeErr.Error())
     }
     
-    line := make([]byte, (len(rawline) - 1) / 2)
-    _, err = fmt.Sscanf(rawline, ":%x", &line)
-    if err != nil {return 0, 0, nil, err}
+    if len(line) < 5 || len(line) != 5 + int(line[0]) {
+        found := len(line) - 5
+        if len(line) < 5 {found = 0}
+        
+        length := 0
+        if len(line) > 0 {length = int(line[0])}
+        
+        return 0, 0, nil, newError(0, LengthMismatch, "length byte is %d, found %d bytes of data", length, found)
+    }
     
     length := uint(line[0])
     addr = (uint(line[1]) << 8) | uint(line[2])
@@ -87,12 +137,18 @@ func parseLine(rawline string) (t uint, addr uint, data []byte, err error) {
     cs2 := CalcChecksum(line[:4 + length])
     
     if cs1 != cs2 {
-        return 0, 0, nil, errors.New("Checksums do not match")
+        return t, addr, data, newError(0, ChecksumMismatch, "record has %02X, expected %02X", cs1, cs2)
     }
     
     return t, addr, data, nil
 }
 
+// Function min returns the lower value out of `a` and `b`.
+func min(a int, b int) (r int) {
+    if a < b {return a}
+    return b
+}
+
 // Function CalcChecksum calculates an Intel Hex checksum from the data `data`.
 func CalcChecksum(data []byte) (checksum uint8) {
     total := uint(0)
@@ -111,28 +167,63 @@ func NewIHex() (ix *IHex) {
     return ix
 }
 
-// Function ReadIHex reads an Intel Hex file from `reader` and returns it.
+// Function ReadIHex reads an Intel Hex file from `reader` and returns it. Reading stops at the end
+// of file (01) record. The file is validated strictly: the first problem found is returned as an
+// *Error, as are a missing end of file record and data records that overlap.
 func ReadIHex(reader *bufio.Reader) (ix *IHex, err error) {
+    ix, _, err = readIHex(reader, false)
+    return ix, err
+}
+
+// Function ReadIHexLenient reads an Intel Hex file from `reader` and returns it, along with a list
+// of the problems found in it. Malformed records are skipped, records with a bad checksum or
+// overlapping data are used anyway, and records of an unknown type are ignored. Only an error
+// from `reader` itself is returned as `err`.
+func ReadIHexLenient(reader *bufio.Reader) (ix *IHex, warnings []*Error, err error) {
+    return readIHex(reader, true)
+}
+
+// Function readIHex implements ReadIHex and ReadIHexLenient. If `lenient` is false, the first
+// problem is returned as `err`; otherwise problems are collected in `warnings`.
+func readIHex(reader *bufio.Reader, lenient bool) (ix *IHex, warnings []*Error, err error) {
     ix = NewIHex()
+    rd := NewReader(reader)
+    rd.Lenient = lenient
     
     for {
-        line, _, err := reader.ReadLine()
+        rec, err := rd.Next()
         if err == io.EOF {break}
-        if err != nil {return nil, err}
+        if err != nil {return nil, rd.Warnings, err}
         
-        sline := strings.Trim(string(line), " \t")
-        if len(sline) == 0 {continue}
-        
-        t, addr, data, err := parseLine(sline)
-        if err != nil {return nil, err}
+        switch rec.Type {
+        case Data:
+            if ix.overlaps(rec.Addr, uint(len(rec.Data))) {
+                err := rd.Warn(OverlappingData, "%d bytes at 0x%X overwrite earlier data", len(rec.Data), rec.Addr)
+                if err != nil {return nil, rd.Warnings, err}
+            }
+            
+            ix.InsertData(rec.Addr, rec.Data)
         
-        switch t {
-        case 0x00:
-            ix.InsertData(addr, data)
+        case StartSegmentAddress, StartLinearAddress:
+            ix.StartAddress = rec.Addr
+            ix.HasStartAddress = true
+        }
+    }
+    
+    ix.Segmented = rd.Segmented
+    return ix, rd.Warnings, nil
+}
+
+// Function IHex.overlaps returns whether any of the `n` bytes starting at address `start` already
+// hold data.
+func (ix *IHex) overlaps(start uint, n uint) (ok bool) {
+    for area, data := range ix.areas {
+        if start < area + uint(len(data)) && area < start + n {
+            return true
         }
     }
     
-    return ix, nil
+    return false
 }
 
 // Function IHex.getArea finds the address of the area that would contain the address `addr`. If a
@@ -204,7 +295,13 @@ func (ix *IHex) InsertData(istart uint, idata []byte) {
     
     if ok {
         data := ix.areas[area]
-        newdata := make([]byte, len(data) + len(idata))
+        
+        size := iend - area
+        if size < uint(len(data)) {
+            size = uint(len(data))
+        }
+        
+        newdata := make([]byte, size)
         
         copy(newdata, data[:istart-area])
         copy(newdata[istart-area:], idata)
@@ -220,29 +317,28 @@ func (ix *IHex) InsertData(istart uint, idata []byte) {
     }
 }
 
-// Function IHex.Write writes the representation of the file to the writer `wr`.
+// Function IHex.Write writes the representation of the file to the writer `wr`. Areas are written
+// in order of address, using Writer.WriteData, followed by the start address if there is one.
 func (ix *IHex) Write(wr *bufio.Writer) (err error) {
-    for start, data := range ix.areas {
-        i := 0
-        
-        for i < len(data) {
-            var chunk []byte
-            
-            if i + 16 <= len(data) {
-                chunk = data[i:i+16]
-            } else {
-                chunk = data[i:]
-            }
-            
-            _, err := wr.Write([]byte(makeLine(0x00, start, chunk)))
-            if err != nil {return err}
-            
-            start += 16
-        }
+    starts := make([]uint, 0, len(ix.areas))
+    for start := range ix.areas {
+        starts = append(starts, start)
     }
     
-    _, err = wr.Write([]byte(makeLine(0x01, 0, []byte{})))
-    if err != nil {return err}
+    sort.Slice(starts, func(i, j int) bool {return starts[i] < starts[j]})
+    
+    w := NewWriter(wr)
+    w.Segmented = ix.Segmented
+    
+    for _, start := range starts {
+        err = w.WriteData(start, ix.areas[start])
+        if err != nil {return err}
+    }
+    
+    if ix.HasStartAddress {
+        err = w.WriteStart(ix.StartAddress)
+        if err != nil {return err}
+    }
     
-    return nil
+    return w.Close()
 }
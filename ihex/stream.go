@@ -0,0 +1,256 @@
+package ihex
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "strings"
+)
+
+// Type Record is a single Intel Hex record.
+type Record struct {
+    Type uint           // The record type, e.g. Data.
+    Offset uint         // The 16-bit address field of the record.
+    Data []byte
+
+    // For a data record, the full address of the data, taking into account the last 02 or 04
+    // record. For a start address record, the start address, as IHex.StartAddress.
+    Addr uint
+
+    Line int            // The line the record was read from, or 0 if it wasn't read.
+}
+
+// Type Reader reads the records of an Intel Hex file one at a time, so that files of any size can
+// be processed without holding their contents in memory.
+type Reader struct {
+    r *bufio.Reader
+    line int
+    base uint
+    done bool
+
+    // If Lenient is false, problems with the file are returned by Next as *Errors. If it is true,
+    // they are appended to Warnings instead; malformed records are skipped, records with a bad
+    // checksum are returned anyway, and records of an unknown type are skipped.
+    Lenient bool
+    Warnings []*Error
+
+    // Whether a 02 or 03 record has been read.
+    Segmented bool
+}
+
+// Function NewReader creates and returns a new Reader, reading from `r`.
+func NewReader(r io.Reader) (rd *Reader) {
+    br, ok := r.(*bufio.Reader)
+    if !ok {
+        br = bufio.NewReader(r)
+    }
+
+    return &Reader{r: br}
+}
+
+// Function Reader.Line returns the number of the line last read.
+func (rd *Reader) Line() (line int) {
+    return rd.line
+}
+
+// Function Reader.problem reports a problem on the current line. It returns the problem if it
+// should be returned from Next, or nil if it was recorded as a warning.
+func (rd *Reader) problem(err *Error) (e error) {
+    err.Line = rd.line
+
+    if rd.Lenient {
+        rd.Warnings = append(rd.Warnings, err)
+        return nil
+    }
+
+    return err
+}
+
+// Function Reader.Warn records a problem found by the caller on the current line, in the same way
+// as the reader's own problems: it returns the problem as an *Error in strict mode, or appends it
+// to Warnings and returns nil in lenient mode.
+func (rd *Reader) Warn(kind ErrorKind, format string, args ...interface{}) (err error) {
+    return rd.problem(newError(0, kind, format, args...))
+}
+
+// Function Reader.Next returns the next record in the file, including the end of file record.
+// Address records (02 and 04) are returned as well as being applied to the Addr of later data
+// records. After the end of file record, it returns io.EOF; if the file ends without one, that is
+// a MissingEOF problem.
+func (rd *Reader) Next() (rec Record, err error) {
+    for !rd.done {
+        line, readErr := rd.r.ReadString('\n')
+        if readErr != nil && readErr != io.EOF {return Record{}, readErr}
+
+        if readErr == io.EOF && len(line) == 0 {
+            rd.done = true
+            rd.line++
+
+            if err := rd.problem(newError(0, MissingEOF, "the file ended without one")); err != nil {
+                return Record{}, err
+            }
+
+            break
+        }
+
+        rd.line++
+
+        sline := strings.Trim(line, " \t\r\n")
+        if len(sline) == 0 {continue}
+
+        t, offset, data, perr := parseLine(sline)
+        if perr != nil {
+            if err := rd.problem(perr); err != nil {return Record{}, err}
+            if perr.Kind != ChecksumMismatch {continue}
+        }
+
+        rec = Record{Type: t, Offset: offset, Data: data, Line: rd.line}
+
+        switch t {
+        case Data:
+            rec.Addr = rd.base + offset
+
+        case EndOfFile:
+            rd.done = true
+
+        case ExtendedSegmentAddress, ExtendedLinearAddress, StartSegmentAddress, StartLinearAddress:
+            n := 2
+            if t == StartSegmentAddress || t == StartLinearAddress {n = 4}
+
+            if len(data) != n {
+                err := rd.problem(newError(0, BadRecordData, "expected %d bytes of data in a %02X record, found %d", n, t, len(data)))
+                if err != nil {return Record{}, err}
+                continue
+            }
+
+            hi := uint(data[0]) << 8 | uint(data[1])
+
+            switch t {
+            case ExtendedSegmentAddress:
+                rd.base = hi << 4
+                rd.Segmented = true
+
+            case ExtendedLinearAddress:
+                rd.base = hi << 16
+
+            case StartSegmentAddress:
+                rec.Addr = hi << 4 + (uint(data[2]) << 8 | uint(data[3]))
+                rd.Segmented = true
+
+            case StartLinearAddress:
+                rec.Addr = hi << 16 | uint(data[2]) << 8 | uint(data[3])
+            }
+
+        default:
+            if err := rd.problem(newError(0, UnknownRecordType, "%02X", t)); err != nil {
+                return Record{}, err
+            }
+
+            continue
+        }
+
+        return rec, nil
+    }
+
+    return Record{}, io.EOF
+}
+
+// Type Writer writes an Intel Hex file one record at a time.
+type Writer struct {
+    w io.Writer
+    base uint
+
+    // Whether to use 8086-style segmented addresses (02 and 03 records) instead of linear ones (04
+    // and 05 records) in WriteData and WriteStart.
+    Segmented bool
+}
+
+// Function NewWriter creates and returns a new Writer, writing to `w`.
+func NewWriter(w io.Writer) (wr *Writer) {
+    return &Writer{w: w}
+}
+
+// Function Writer.WriteRecord writes a single record as it is. The Addr and Line fields are
+// ignored.
+func (wr *Writer) WriteRecord(rec Record) (err error) {
+    if len(rec.Data) > 0xFF {
+        return errors.New(fmt.Sprintf("Record data is too long: %d bytes", len(rec.Data)))
+    }
+
+    _, err = io.WriteString(wr.w, makeLine(rec.Type, rec.Offset & 0xFFFF, rec.Data))
+    return err
+}
+
+// Function Writer.limit returns the highest address that can be written.
+func (wr *Writer) limit() (limit uint) {
+    if wr.Segmented {
+        return 0xFFFFF
+    }
+
+    return 0xFFFFFFFF
+}
+
+// Function Writer.WriteData writes `data`, starting at address `addr`, as data records of up to 16
+// bytes that never cross a 64 KiB boundary (or, if the file is segmented, a 64 KiB boundary from
+// the start of the segment). A 04 or 02 record is written first whenever the upper bits of the
+// address change.
+func (wr *Writer) WriteData(addr uint, data []byte) (err error) {
+    if len(data) > 0 && addr + uint(len(data)) - 1 > wr.limit() {
+        return errors.New(fmt.Sprintf("Address 0x%X is too high for the file", addr + uint(len(data)) - 1))
+    }
+
+    for len(data) > 0 {
+        if addr & 0xFFFF0000 != wr.base {
+            wr.base = addr & 0xFFFF0000
+
+            rec := Record{Type: ExtendedLinearAddress, Data: []byte{uint8(wr.base >> 24), uint8(wr.base >> 16)}}
+            if wr.Segmented {
+                rec = Record{Type: ExtendedSegmentAddress, Data: []byte{uint8(wr.base >> 12), 0}}
+            }
+
+            err = wr.WriteRecord(rec)
+            if err != nil {return err}
+        }
+
+        n := len(data)
+        if n > 16 {
+            n = 16
+        }
+
+        if rest := int(wr.base + 0x10000 - addr); n > rest {
+            n = rest
+        }
+
+        err = wr.WriteRecord(Record{Type: Data, Offset: addr & 0xFFFF, Data: data[:n]})
+        if err != nil {return err}
+
+        addr += uint(n)
+        data = data[n:]
+    }
+
+    return nil
+}
+
+// Function Writer.WriteStart writes a start address record for the address `addr`: 05, or 03 if
+// the file is segmented.
+func (wr *Writer) WriteStart(addr uint) (err error) {
+    if addr > wr.limit() {
+        return errors.New(fmt.Sprintf("Start address 0x%X is too high for the file", addr))
+    }
+
+    hi, lo := addr >> 16, addr & 0xFFFF
+    t := uint(StartLinearAddress)
+
+    if wr.Segmented {
+        hi = (addr >> 4) & 0xF000
+        t = StartSegmentAddress
+    }
+
+    return wr.WriteRecord(Record{Type: t, Data: []byte{uint8(hi >> 8), uint8(hi), uint8(lo >> 8), uint8(lo)}})
+}
+
+// Function Writer.Close writes the end of file record. It doesn't close the underlying writer.
+func (wr *Writer) Close() (err error) {
+    return wr.WriteRecord(Record{Type: EndOfFile, Data: []byte{}})
+}
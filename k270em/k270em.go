@@ -4,13 +4,12 @@
 package main
 
 import (
-    "bufio"
     "flag"
     "fmt"
     "github.com/0xe2-0x9a-0x9b/Go-SDL/sdl"
     "github.com/0xe2-0x9a-0x9b/Go-SDL/ttf"
+    "github.com/kierdavis/go/binaryimage"
     "github.com/kierdavis/go/k270emlib"
-    "github.com/kierdavis/go/ihex"
     "github.com/kierdavis/go/resourcemanager"
     "os"
     "time"
@@ -28,7 +27,7 @@ func main() {
     flag.Parse()
     
     if flag.NArg() < 1 {
-        fmt.Fprintf(os.Stderr, "Not enough arguments\nusage: %s file.hex\n", os.Args[0])
+        fmt.Fprintf(os.Stderr, "Not enough arguments\nusage: %s image\n", os.Args[0])
         os.Exit(2)
     }
     
@@ -69,9 +68,8 @@ func main() {
     f, err := os.Open(flag.Arg(0)); die(err)
     defer f.Close()
     
-    reader := bufio.NewReader(f)
-    ix, err := ihex.ReadIHex(reader); die(err)
-    program := ix.ExtractDataToEnd(0)
+    image, err := binaryimage.ReadAuto(f); die(err)
+    program := image.Bytes()
     
     em := k270emlib.NewEmulator()
     em.SetTraceFile(os.Stdout)
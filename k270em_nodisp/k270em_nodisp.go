@@ -7,8 +7,8 @@ import (
     "bufio"
     "flag"
     "fmt"
+    "github.com/kierdavis/go/binaryimage"
     "github.com/kierdavis/go/k270emlib"
-    "github.com/kierdavis/go/ihex"
     "io"
     "os"
 )
@@ -54,16 +54,15 @@ func main() {
     flag.Parse()
     
     if flag.NArg() < 1 {
-        fmt.Fprintf(os.Stderr, "Not enough arguments\nusage: %s file.hex\n", os.Args[0])
+        fmt.Fprintf(os.Stderr, "Not enough arguments\nusage: %s image\n", os.Args[0])
         os.Exit(2)
     }
     
     f, err := os.Open(flag.Arg(0)); die(err)
     defer f.Close()
     
-    reader := bufio.NewReader(f)
-    ix, err := ihex.ReadIHex(reader); die(err)
-    program := ix.ExtractDataToEnd(0)
+    image, err := binaryimage.ReadAuto(f); die(err)
+    program := image.Bytes()
     
     em := k270emlib.NewEmulator()
     em.SetGetKey(getKey)
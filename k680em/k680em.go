@@ -1,10 +1,9 @@
 package main
 
 import (
-    "bufio"
     "flag"
     "fmt"
-    "github.com/kierdavis/go/ihex"
+    "github.com/kierdavis/go/binaryimage"
     "github.com/kierdavis/go/k680emlib"
     "os"
 )
@@ -22,7 +21,7 @@ func main() {
     flag.Parse()
 
     if flag.NArg() < 1 {
-        fmt.Fprintf(os.Stderr, "Not enough arguments\nusage: %s file.hex\n", os.Args[0])
+        fmt.Fprintf(os.Stderr, "Not enough arguments\nusage: %s image\n", os.Args[0])
         os.Exit(2)
     }
 
@@ -31,12 +30,11 @@ func main() {
         panic(err)
     }
 
-    reader := bufio.NewReader(f)
-    ix, err := ihex.ReadIHex(reader)
+    image, err := binaryimage.ReadAuto(f)
     if err != nil {
         panic(err)
     }
-    program := ix.ExtractDataToEnd(0)
+    program := image.Bytes()
 
     em := k680emlib.NewEmulator()
     em.TraceFile = os.Stdout
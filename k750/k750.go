@@ -0,0 +1,129 @@
+// Package k750 gives programs compiled by go750 access to the K750's hardware: peripherals, the
+// bits of the status register, interrupts and raw memory. Its functions are intrinsics; go750
+// replaces each call with the instruction named in its documentation rather than calling it, so
+// they do nothing useful in a program built by the standard Go toolchain and panic if called.
+//
+// An interrupt handler is an ordinary function with no parameters or results whose doc comment
+// contains a directive:
+//
+//     //k750:interrupt 3
+//     func onSerial() {
+//         ...
+//     }
+//
+// go750 saves the registers the handler uses on entry and returns from it with reti. If the
+// directive gives an interrupt number, the handler is registered for it before any init function
+// runs; otherwise it can be registered with SetHandler. Interrupts are delivered only while
+// InterruptEnable is set, which it isn't when the program starts, and are disabled while a handler
+// runs. Handlers can't be called directly.
+package k750
+
+// BitReg is one of the 16 bits of the status register, %b0 to %b15. A BitReg passed to a function
+// in this package must be a constant.
+type BitReg uint8
+
+const (
+    // InterruptEnable allows interrupts to be delivered while it is set. It is cleared when a
+    // handler is entered and set again when the handler returns.
+    InterruptEnable BitReg = 0
+
+    // StackCounterZero is set by pusha and popa when they have pushed or popped every register.
+    StackCounterZero BitReg = 15
+)
+
+func intrinsic() {
+    panic("k750: intrinsics can only be used in programs compiled by go750")
+}
+
+// ReadPeripheral returns the value of register reg of peripheral p (ppr).
+func ReadPeripheral(p uint32, reg uint32) (value uint32) {
+    intrinsic()
+    return 0
+}
+
+// WritePeripheral sets register reg of peripheral p to value (ppw).
+func WritePeripheral(p uint32, reg uint32, value uint32) {
+    intrinsic()
+}
+
+// InterruptPeripheral sends interrupt n to peripheral p (ppi).
+func InterruptPeripheral(p uint32, n uint32) {
+    intrinsic()
+}
+
+// NumPeripherals returns the number of peripherals attached to the CPU (ppn).
+func NumPeripherals() (n uint32) {
+    intrinsic()
+    return 0
+}
+
+// SetBit sets bit b of the status register (sb).
+func SetBit(b BitReg) {
+    intrinsic()
+}
+
+// ClearBit clears bit b of the status register (cb).
+func ClearBit(b BitReg) {
+    intrinsic()
+}
+
+// TestBit returns whether bit b of the status register is set. Used as the condition of an if or
+// for statement it becomes a single jbs or jbc.
+func TestBit(b BitReg) (set bool) {
+    intrinsic()
+    return false
+}
+
+// EnableInterrupts sets InterruptEnable (sb).
+func EnableInterrupts() {
+    intrinsic()
+}
+
+// DisableInterrupts clears InterruptEnable (cb).
+func DisableInterrupts() {
+    intrinsic()
+}
+
+// SetHandler registers handler, which must be a function annotated as an interrupt handler, for
+// interrupt n (rih).
+func SetHandler(n uint32, handler func()) {
+    intrinsic()
+}
+
+// Interrupt raises interrupt n (int).
+func Interrupt(n uint32) {
+    intrinsic()
+}
+
+// Peek8 returns the byte at addr (mov).
+func Peek8(addr uintptr) (value uint8) {
+    intrinsic()
+    return 0
+}
+
+// Peek16 returns the 16-bit value at addr (mov).
+func Peek16(addr uintptr) (value uint16) {
+    intrinsic()
+    return 0
+}
+
+// Peek32 returns the 32-bit value at addr (mov).
+func Peek32(addr uintptr) (value uint32) {
+    intrinsic()
+    return 0
+}
+
+// Poke8 stores value at addr (mov).
+func Poke8(addr uintptr, value uint8) {
+    intrinsic()
+}
+
+// Poke16 stores value at addr (mov).
+func Poke16(addr uintptr, value uint16) {
+    intrinsic()
+}
+
+// Poke32 stores value at addr (mov).
+func Poke32(addr uintptr, value uint32) {
+    intrinsic()
+}
@@ -0,0 +1,103 @@
+// Command k750asm assembles K750 source files using k750asmlib.
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "flag"
+    "fmt"
+    "github.com/kierdavis/go/k750/k750asmlib"
+    "io"
+    "io/ioutil"
+    "log"
+    "os"
+    "strings"
+)
+
+func writeFile(fname string, write func(io.Writer) error) {
+    f, err := os.Create(fname)
+    if err != nil {
+        log.Fatal(err)
+    }
+    defer f.Close()
+
+    w := bufio.NewWriter(f)
+
+    err = write(w)
+    if err == nil {
+        err = w.Flush()
+    }
+
+    if err != nil {
+        log.Fatal(err)
+    }
+}
+
+func main() {
+    outName := flag.String("o", "", "output file (default: input file + .bin, or + .o with -c)")
+    relocatable := flag.Bool("c", false, "write a relocatable object for k750ld instead of a flat image")
+    listName := flag.String("l", "", "write a listing of source lines, offsets and encoded bytes to this file")
+    symName := flag.String("s", "", "write a symbol file mapping labels to addresses to this file")
+    flag.Parse()
+
+    if flag.NArg() < 1 {
+        fmt.Fprintf(os.Stderr, "Not enough arguments\nusage: %s [-c] [-o file.bin] [-l file.lst] [-s file.sym] file.asm\n", os.Args[0])
+        os.Exit(2)
+    }
+
+    fname := flag.Arg(0)
+
+    source, err := ioutil.ReadFile(fname)
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    var image *k750asmlib.Image
+    var errs []*k750asmlib.AsmError
+
+    if *relocatable {
+        image, errs = k750asmlib.AssembleRelocatable(bytes.NewReader(source), fname)
+    } else {
+        image, errs = k750asmlib.Assemble(bytes.NewReader(source), fname)
+    }
+
+    if len(errs) > 0 {
+        for _, err := range errs {
+            fmt.Fprintln(os.Stderr, err)
+        }
+
+        os.Exit(1)
+    }
+
+    if *outName == "" {
+        if *relocatable {
+            *outName = fname + ".o"
+        } else {
+            *outName = fname + ".bin"
+        }
+    }
+
+    writeFile(*outName, func(w io.Writer) error {
+        if *relocatable {
+            return image.Object().Write(w)
+        }
+
+        _, err := w.Write(image.Bytes())
+        return err
+    })
+
+    if *listName != "" {
+        lines := strings.Split(strings.Replace(string(source), "\r\n", "\n", -1), "\n")
+        if len(lines) > 0 && lines[len(lines)-1] == "" {
+            lines = lines[:len(lines)-1]
+        }
+
+        writeFile(*listName, func(w io.Writer) error {
+            return image.WriteListing(w, lines)
+        })
+    }
+
+    if *symName != "" {
+        writeFile(*symName, image.WriteSymbols)
+    }
+}
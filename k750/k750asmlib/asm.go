@@ -0,0 +1,330 @@
+// Package k750asmlib implements an assembler for the K750 CPU.
+//
+// Assembly happens in three stages: the parsed items are verified and their lengths computed, then
+// labels are mapped to offsets within their sections, and finally the items are encoded. Errors
+// from every stage are collected rather than stopping at the first one, so that a single run
+// reports everything wrong with a file.
+//
+// Example:
+//
+//     image, errs := k750asmlib.Assemble(f, "prog.asm")
+//     if len(errs) > 0 {
+//         for _, err := range errs {
+//             fmt.Println(err)
+//         }
+//         os.Exit(1)
+//     }
+//
+//     out.Write(image.Bytes())
+package k750asmlib
+
+import (
+    "bufio"
+    "fmt"
+    "github.com/kierdavis/go/binaryimage"
+    "github.com/kierdavis/go/k750/k750obj"
+    "io"
+    "sort"
+)
+
+type AsmError struct {
+    Coord   Coord
+    Message string
+}
+
+func (e *AsmError) Error() (msg string) {
+    return fmt.Sprintf("%s %s", e.Coord.String(), e.Message)
+}
+
+// ErrorList collects the errors found while assembling a file.
+type ErrorList []*AsmError
+
+// Add appends an error at coord to the list.
+func (l *ErrorList) Add(coord Coord, format string, args ...interface{}) {
+    *l = append(*l, &AsmError{coord, fmt.Sprintf(format, args...)})
+}
+
+func (l ErrorList) Len() int {
+    return len(l)
+}
+
+func (l ErrorList) Less(i, j int) bool {
+    return l[i].Coord.Before(l[j].Coord)
+}
+
+func (l ErrorList) Swap(i, j int) {
+    l[i], l[j] = l[j], l[i]
+}
+
+// A Section is a run of items that is assembled contiguously. Sections are laid out one after
+// another in a flat image, or kept separate in a relocatable one.
+type Section struct {
+    Name   string
+    Base   uint32
+    Size   uint32
+    Data   []byte
+    items  []Item
+    labels []string
+}
+
+// Image is the result of assembling a file.
+type Image struct {
+    Relocatable bool
+    Items       []Item
+    Sections    []*Section
+    Labels      map[string]uint32
+    Exports     []string
+    Imports     []string
+}
+
+func (image *Image) getSection(name string) (sec *Section) {
+    for _, sec := range image.Sections {
+        if sec.Name == name {
+            return sec
+        }
+    }
+
+    sec = &Section{Name: name}
+    image.Sections = append(image.Sections, sec)
+    return sec
+}
+
+// Bytes returns the flat image, with the sections placed one after another.
+func (image *Image) Bytes() (data []byte) {
+    for _, sec := range image.Sections {
+        data = append(data, sec.Data...)
+    }
+
+    return data
+}
+
+func stage1(items []Item, errs *ErrorList) {
+    // Run the first stage - verify, reduce aliases and compute lengths
+
+    for _, item := range items {
+        item.VerifyAndReduce(errs)
+    }
+}
+
+func stage2(image *Image, errs *ErrorList) {
+    // Run the second stage - label mapping
+    // Requires that lengths have been computed (in stage 1)
+    // Ensure that the items are assigned offsets in the corrent order.
+    // Offsets are relative to the start of each section; in a flat image the sections are then
+    // placed one after another and the offsets adjusted accordingly.
+
+    current := image.getSection(DefaultSection)
+    externs := make([]*Directive, 0)
+    globals := make([]*Directive, 0)
+
+    for _, item := range image.Items {
+        if directive, ok := item.(*Directive); ok {
+            switch directive.name {
+            case ".section":
+                if len(directive.args) == 1 {
+                    current = image.getSection(directive.args[0])
+                }
+            case ".global":
+                image.Exports = append(image.Exports, directive.args...)
+                globals = append(globals, directive)
+            case ".extern":
+                externs = append(externs, directive)
+            }
+        }
+
+        label, ok := item.Label()
+        if ok {
+            if _, defined := image.Labels[label]; defined {
+                errs.Add(item.GetCoord(), "Label '%s' already defined", label)
+            }
+
+            image.Labels[label] = current.Size
+            current.labels = append(current.labels, label)
+        }
+
+        item.SetOffset(current.Size)
+        current.Size += item.Length()
+        current.items = append(current.items, item)
+    }
+
+    if !image.Relocatable {
+        base := uint32(0)
+
+        for _, sec := range image.Sections {
+            sec.Base = base
+            base += sec.Size
+
+            for _, item := range sec.items {
+                item.SetOffset(item.Offset() + sec.Base)
+            }
+
+            for _, label := range sec.labels {
+                image.Labels[label] += sec.Base
+            }
+        }
+    }
+
+    for _, directive := range globals {
+        for _, name := range directive.args {
+            if _, defined := image.Labels[name]; !defined {
+                errs.Add(directive.coord, "Label '%s' is declared global but not defined", name)
+            }
+        }
+    }
+
+    imported := make(map[string]bool)
+
+    for _, directive := range externs {
+        for _, name := range directive.args {
+            if imported[name] {
+                continue
+            }
+
+            if _, defined := image.Labels[name]; defined {
+                errs.Add(directive.coord, "Label '%s' is declared external but defined locally", name)
+
+            } else if image.Relocatable {
+                // The linker supplies the value; reduce to zero for now so encoding can proceed.
+                image.Labels[name] = 0
+                image.Imports = append(image.Imports, name)
+                imported[name] = true
+            }
+        }
+    }
+}
+
+func stage3(image *Image, errs *ErrorList) {
+    // Run the third stage - encoding
+    // Requires that label offsets have been computed
+
+    for _, sec := range image.Sections {
+        sec.Data = make([]byte, sec.Size)
+
+        for _, item := range sec.items {
+            offset := item.Offset() - sec.Base
+            buffer := sec.Data[offset : offset+item.Length()]
+
+            item.Encode(image.Labels, buffer, errs)
+        }
+    }
+}
+
+// checkLabels reports references to undefined labels without encoding anything.
+func checkLabels(image *Image, errs *ErrorList) {
+    for _, item := range image.Items {
+        inst, ok := item.(*Instruction)
+        if !ok {
+            continue
+        }
+
+        for _, o := range inst.operands {
+            o.ReduceLabel(image.Labels, errs)
+        }
+    }
+}
+
+func assemble(reader io.Reader, filename string, relocatable bool) (image *Image, errs []*AsmError) {
+    var errList ErrorList
+
+    lexer := newLexer(bufio.NewReader(reader), filename, &errList)
+    yyParse(lexer)
+
+    image = &Image{
+        Relocatable: relocatable,
+        Items:       lexer.items,
+        Labels:      make(map[string]uint32),
+    }
+
+    // Carry on after an error so that problems elsewhere in the file are reported too. Items that
+    // failed verification can't be encoded, but references to undefined labels can still be found.
+    stage1(image.Items, &errList)
+    stage2(image, &errList)
+
+    if len(errList) == 0 {
+        stage3(image, &errList)
+    } else {
+        checkLabels(image, &errList)
+    }
+
+    if len(errList) > 0 {
+        sort.Stable(errList)
+        return nil, errList
+    }
+
+    return image, nil
+}
+
+// Assemble assembles the source read from reader into a flat image. filename is used only in
+// error messages. If there were any errors, image is nil and errs holds all of them in order of
+// position.
+func Assemble(reader io.Reader, filename string) (image *Image, errs []*AsmError) {
+    return assemble(reader, filename, false)
+}
+
+// AssembleRelocatable is like Assemble, but every section starts at offset zero and labels
+// declared with .extern may be left undefined. The result can be turned into an object file for
+// k750ld using Object.
+func AssembleRelocatable(reader io.Reader, filename string) (image *Image, errs []*AsmError) {
+    return assemble(reader, filename, true)
+}
+
+// BinaryImage returns a flat image as a binary image, with a segment for each section and a symbol
+// for each label. Execution starts at the beginning of the text section.
+func (image *Image) BinaryImage() (bin *binaryimage.Image) {
+    bin = binaryimage.New()
+
+    for _, sec := range image.Sections {
+        bin.PutBytes(uint64(sec.Base), sec.Data)
+        bin.AddSegment(binaryimage.NewSegment(sec.Name, binaryimage.KindForName(sec.Name), uint64(sec.Base), uint64(sec.Size)))
+
+        if sec.Name == DefaultSection {
+            bin.SetEntry(uint64(sec.Base))
+        }
+    }
+
+    for name, addr := range image.Labels {
+        bin.AddSymbol(name, uint64(addr))
+    }
+
+    return bin
+}
+
+// Object builds a relocatable object from an image produced by AssembleRelocatable. Every label
+// reference becomes a relocation, since the final address of each section is only known once it
+// has been linked.
+func (image *Image) Object() (obj *k750obj.Object) {
+    obj = new(k750obj.Object)
+    exported := make(map[string]bool)
+
+    for _, name := range image.Exports {
+        exported[name] = true
+    }
+
+    for _, sec := range image.Sections {
+        if sec.Size == 0 && len(sec.labels) == 0 {
+            continue
+        }
+
+        osec := &k750obj.Section{Name: sec.Name, Data: sec.Data}
+
+        for _, item := range sec.items {
+            if inst, ok := item.(*Instruction); ok {
+                osec.Relocs = append(osec.Relocs, inst.Relocations()...)
+            }
+        }
+
+        for _, label := range sec.labels {
+            obj.Symbols = append(obj.Symbols, k750obj.Symbol{
+                Name:     label,
+                Section:  sec.Name,
+                Offset:   image.Labels[label],
+                Exported: exported[label],
+            })
+        }
+
+        obj.Sections = append(obj.Sections, osec)
+    }
+
+    obj.Imports = image.Imports
+    return obj
+}
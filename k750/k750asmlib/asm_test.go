@@ -0,0 +1,109 @@
+package k750asmlib
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+)
+
+func TestAssemble(t *testing.T) {
+    src := "main:\n" +
+        "    mov %v1, 32[%a2 + 4]\n" +
+        "loop:\n" +
+        "    mov %v1, loop+2\n" +
+        "    mov 32[256], 3\n" +
+        "    jmp loop\n"
+
+    expected := []byte{
+        0x01, 0x81, 0xEA, 0x00, 0x04, // mov %v1, 32[%a2 + 4]
+        0x01, 0x81, 0xFF, 0x00, 0x00, 0x00, 0x07, // mov %v1, loop+2
+        0x01, 0xFE, 0x03, 0x00, 0x00, 0x01, 0x00, // mov 32[256], 3
+        0x01, 0xFC, 0xFF, 0x00, 0x00, 0x00, 0x05, // jmp loop
+    }
+
+    image, errs := Assemble(strings.NewReader(src), "test.asm")
+    if len(errs) > 0 {
+        t.Fatalf("Assemble returned errors: %v", errs)
+    }
+
+    if data := image.Bytes(); !bytes.Equal(data, expected) {
+        t.Errorf("Assemble produced % X, expected % X", data, expected)
+    }
+
+    if addr := image.Labels["loop"]; addr != 5 {
+        t.Errorf("Label 'loop' is at %d, expected 5", addr)
+    }
+}
+
+func TestAssembleErrors(t *testing.T) {
+    src := "main:\n" +
+        "    mov 32[74565], 3\n" +
+        "    bogus %v0\n" +
+        "    jmp nowhere\n" +
+        "main:\n" +
+        "    mov 32[%v0 + 40000], 1\n" +
+        "    .global missing\n" +
+        "    mov 8[256], 1\n"
+
+    expected := []struct {
+        coord   Coord
+        message string
+    }{
+        {Coord{"bad.asm", 2, 12}, "Integer displacement out of range"},
+        {Coord{"bad.asm", 3, 5}, "Invalid instruction name: bogus"},
+        {Coord{"bad.asm", 4, 9}, "Label 'nowhere' not defined"},
+        {Coord{"bad.asm", 5, 1}, "Label 'main' already defined"},
+        {Coord{"bad.asm", 6, 12}, "Integer displacement out of range"},
+        {Coord{"bad.asm", 7, 5}, "Label 'missing' is declared global but not defined"},
+        {Coord{"bad.asm", 8, 11}, "Memory operands without a register must be 32 bits wide"},
+    }
+
+    _, errs := Assemble(strings.NewReader(src), "bad.asm")
+
+    if len(errs) != len(expected) {
+        t.Fatalf("Assemble returned %d errors, expected %d: %v", len(errs), len(expected), errs)
+    }
+
+    for i, err := range errs {
+        if err.Coord != expected[i].coord || !strings.HasPrefix(err.Message, expected[i].message) {
+            t.Errorf("Error %d is %v, expected %s at %s", i, err, expected[i].message, expected[i].coord)
+        }
+    }
+}
+
+func TestAddressTerms(t *testing.T) {
+    same := [][]string{
+        {"32[%a2 + 4]", "32[%a2+4]", "32[4 + %a2]", "32[%a2 +4]"},
+        {"32[%a2 - 4]", "32[%a2-4]", "32[%a2 + -4]"},
+        {"32[%a2 + %a3*4 + data+2]", "32[%a2 + %a3*4 + data +2]", "32[%a2+%a3*4+data+2]"},
+    }
+
+    for _, operands := range same {
+        var expected []byte
+
+        for _, operand := range operands {
+            src := "    mov %v1, " + operand + "\ndata:\n"
+
+            image, errs := Assemble(strings.NewReader(src), "test.asm")
+            if len(errs) > 0 {
+                t.Errorf("%s: Assemble returned errors: %v", operand, errs)
+                continue
+            }
+
+            if expected == nil {
+                expected = image.Bytes()
+            } else if data := image.Bytes(); !bytes.Equal(data, expected) {
+                t.Errorf("%s assembled to % X, expected % X as for %s", operand, data, expected, operands[0])
+            }
+        }
+    }
+
+    // Terms must be separated by a + or -
+    for _, operand := range []string{"32[%a0 5]", "32[%a0 data]", "32[data %a0]"} {
+        src := "    mov %v1, " + operand + "\ndata:\n"
+
+        if _, errs := Assemble(strings.NewReader(src), "test.asm"); len(errs) == 0 {
+            t.Errorf("%s: Assemble returned no errors", operand)
+        }
+    }
+}
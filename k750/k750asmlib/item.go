@@ -1,7 +1,8 @@
-package main
+package k750asmlib
 
 import (
     "fmt"
+    "github.com/kierdavis/go/k750/k750obj"
     "strings"
 )
 
@@ -33,6 +34,53 @@ var OperandTypeLookup = [][]OperandType{
     {DynamicType, LiteralType, BitRegType},
 }
 
+// Opcodes maps instruction names to the first byte of their encoding. Instructions with a bit
+// register or literal operand encoded in the opcode byte have it ORed into the low bits. Aliases
+// such as jmp and ret are replaced by the instructions they stand for before encoding.
+var Opcodes = map[string]byte{
+    "nop":   0x00,
+    "mov":   0x01,
+    "not":   0x02,
+    "neg":   0x03,
+    "push":  0x04,
+    "pop":   0x05,
+    "pusha": 0x06,
+    "popa":  0x07,
+    "ab":    0x08,
+    "ob":    0x09,
+    "xb":    0x0A,
+    "ppn":   0x0B,
+    "int":   0x0C,
+    "rih":   0x0D,
+    "jbc":   0x0E,
+    "jbs":   0x0F,
+    "add":   0x10,
+    "sub":   0x11,
+    "and":   0x12,
+    "or":    0x13,
+    "xor":   0x14,
+    "ppi":   0x15,
+    "ppr":   0x16,
+    "ppw":   0x17,
+    "jeq":   0x18,
+    "jne":   0x19,
+    "jlt":   0x1A,
+    "jge":   0x1B,
+    "jac":   0x1C,
+    "jas":   0x1D,
+    "call":  0x1E,
+    "reti":  0x1F,
+    "cb":    0x20,
+    "sb":    0x30,
+    "rll":   0x40,
+    "rlr":   0x60,
+    "shl":   0x80,
+    "lshr":  0xA0,
+    "ashr":  0xC0,
+    "ldb":   0xE0,
+    "stb":   0xE2,
+}
+
 var LengthLookup = []uint32{
     1,
     2,
@@ -48,8 +96,9 @@ var LengthLookup = []uint32{
 
 type Item interface {
     String() string
-    VerifyAndReduce()
-    Encode(map[string]uint32, []byte)
+    GetCoord() Coord
+    VerifyAndReduce(*ErrorList)
+    Encode(map[string]uint32, []byte, *ErrorList)
     Label() (string, bool)
     Length() uint32
     Offset() uint32
@@ -57,6 +106,18 @@ type Item interface {
     Encoded() []byte
 }
 
+// The section that items are placed in until a .section directive is seen.
+const DefaultSection = "text"
+
+// newItem creates an Instruction, or a Directive if the name begins with a dot.
+func newItem(coord Coord, name string, operands []Operand) (item Item) {
+    if strings.HasPrefix(name, ".") {
+        return &Directive{coord: coord, name: name, operands: operands}
+    }
+
+    return &Instruction{coord: coord, name: name, operands: operands}
+}
+
 type Instruction struct {
     coord       Coord
     name        string
@@ -81,9 +142,11 @@ func (item *Instruction) String() (str string) {
     return fmt.Sprintf("%s %s", item.name, strings.Join(operandStrings, ", "))
 }
 
-func (item *Instruction) VerifyAndReduce() {
-    defer waitGroup.Done()
+func (item *Instruction) GetCoord() (coord Coord) {
+    return item.coord
+}
 
+func (item *Instruction) VerifyAndReduce(errs *ErrorList) {
     var operandMode OperandMode
 
     switch item.name {
@@ -118,7 +181,7 @@ func (item *Instruction) VerifyAndReduce() {
         operandMode = OperandModeDLB
 
     default:
-        errChan <- &AsmError{item.coord, fmt.Sprintf("Invalid instruction name: %s", item.name)}
+        errs.Add(item.coord, "Invalid instruction name: %s", item.name)
         return
     }
 
@@ -126,7 +189,7 @@ func (item *Instruction) VerifyAndReduce() {
     length := LengthLookup[operandMode]
 
     if len(item.operands) != len(operandTypes) {
-        errChan <- &AsmError{item.coord, fmt.Sprintf("Invalid number of operands (expected %d, got %d)", len(operandTypes), len(item.operands))}
+        errs.Add(item.coord, "Invalid number of operands (expected %d, got %d)", len(operandTypes), len(item.operands))
         return
     }
 
@@ -134,10 +197,20 @@ func (item *Instruction) VerifyAndReduce() {
         t := operandTypes[i]
 
         if !o.SatisfiesType(t) {
-            errChan <- &AsmError{item.coord, fmt.Sprintf("Invalid type for operand %d (0-indexed) to %s", i, item.name)}
+            errs.Add(item.coord, "Invalid type for operand %d (0-indexed) to %s", i, item.name)
             return
         }
 
+        o.Check(errs)
+
+        if t == LiteralType {
+            // Encoded in 5 bits of the instruction itself
+            lo, ok := o.(*LiteralOperand)
+            if !ok || !lo.Reduced() || lo.Value() > 31 {
+                errs.Add(item.coord, "Operand %d (0-indexed) to %s must be a constant from 0 to 31", i, item.name)
+            }
+        }
+
         if t == DynamicType {
             length += o.Length()
         }
@@ -171,26 +244,15 @@ func (item *Instruction) VerifyAndReduce() {
     item.length = length
 }
 
-func (item *Instruction) Encode(labelMap map[string]uint32, buffer []byte) {
-    defer waitGroup.Done()
-
+func (item *Instruction) Encode(labelMap map[string]uint32, buffer []byte, errs *ErrorList) {
     operands := item.operands
 
     for _, operand := range operands {
-        operand.ReduceLabel(labelMap)
-    }
-
-    var opcode byte
-
-    switch item.name {
-    case "nop":
-        opcode = 0x00
-    case "mov":
-        opcode = 0x01
+        operand.ReduceLabel(labelMap, errs)
     }
 
     //buffer := make([]byte, item.length)
-    buffer[0] = opcode
+    buffer[0] = Opcodes[item.name]
 
     switch item.operandMode {
     case OperandModeNone:
@@ -267,6 +329,41 @@ func (item *Instruction) Encode(labelMap map[string]uint32, buffer []byte) {
     item.encoded = buffer
 }
 
+// Relocations returns a relocation for each label referenced in the instruction's extra data, with
+// offsets relative to the start of its section. Encode must have been called first.
+func (item *Instruction) Relocations() (relocs []k750obj.Reloc) {
+    pos := item.offset + LengthLookup[item.operandMode]
+
+    for i, t := range OperandTypeLookup[item.operandMode] {
+        if t != DynamicType {
+            continue
+        }
+
+        o := item.operands[i]
+
+        if name, ok := o.LabelName(); ok {
+            field, relocType := pos, k750obj.RelocAbs32
+
+            switch o.(type) {
+            case *ArrayOperand:
+                // The displacement follows the register byte
+                field, relocType = pos+1, k750obj.RelocAbs16
+
+            case *MemoryOperand:
+                if o.Length() == 2 {
+                    relocType = k750obj.RelocAbs16
+                }
+            }
+
+            relocs = append(relocs, k750obj.Reloc{Offset: field, Type: relocType, Symbol: name, Addend: o.LabelAddend()})
+        }
+
+        pos += o.Length()
+    }
+
+    return relocs
+}
+
 func (item *Instruction) Label() (label string, ok bool) {
     return "", false
 }
@@ -297,12 +394,14 @@ func (item *Label) String() (str string) {
     return item.name + ":"
 }
 
-func (item *Label) VerifyAndReduce() {
-    defer waitGroup.Done()
+func (item *Label) GetCoord() (coord Coord) {
+    return item.coord
+}
+
+func (item *Label) VerifyAndReduce(errs *ErrorList) {
 }
 
-func (item *Label) Encode(labelMap map[string]uint32, buffer []byte) {
-    defer waitGroup.Done()
+func (item *Label) Encode(labelMap map[string]uint32, buffer []byte, errs *ErrorList) {
 }
 
 func (item *Label) Label() (label string, ok bool) {
@@ -324,3 +423,99 @@ func (item *Label) SetOffset(offset uint32) {
 func (item *Label) Encoded() (encoded []byte) {
     return []byte{}
 }
+
+// Directive is an item that controls assembly rather than producing any code.
+//
+//     .section name       place the following items in the named section
+//     .global name, ...   export labels to other objects
+//     .extern name, ...   declare labels that are defined in another object
+//     .space size         reserve size zero bytes, e.g. for variables
+type Directive struct {
+    coord    Coord
+    name     string
+    operands []Operand
+    args     []string
+    offset   uint32
+    length   uint32
+}
+
+func (item *Directive) String() (str string) {
+    return fmt.Sprintf("%s %s", item.name, strings.Join(item.args, ", "))
+}
+
+func (item *Directive) GetCoord() (coord Coord) {
+    return item.coord
+}
+
+func (item *Directive) VerifyAndReduce(errs *ErrorList) {
+    switch item.name {
+    case ".section":
+        if len(item.operands) != 1 {
+            errs.Add(item.coord, "Expected a single section name")
+            return
+        }
+
+    case ".global", ".extern":
+        if len(item.operands) == 0 {
+            errs.Add(item.coord, "Expected at least one label name for %s", item.name)
+            return
+        }
+
+    case ".space":
+        if len(item.operands) != 1 {
+            errs.Add(item.coord, "Expected a single size for .space")
+            return
+        }
+
+        lo, ok := item.operands[0].(*LiteralOperand)
+        if !ok || !lo.Reduced() {
+            errs.Add(item.coord, "The size given to .space must be a constant")
+            return
+        }
+
+        item.length = lo.Value()
+        item.args = []string{fmt.Sprint(item.length)}
+        return
+
+    default:
+        errs.Add(item.coord, "Invalid directive: %s", item.name)
+        return
+    }
+
+    for i, o := range item.operands {
+        name, ok := "", false
+        if lo, isLiteral := o.(*LiteralOperand); isLiteral {
+            name, ok = lo.LabelName()
+        }
+
+        if !ok {
+            errs.Add(item.coord, "Operand %d (0-indexed) to %s must be a name", i, item.name)
+            return
+        }
+
+        item.args = append(item.args, name)
+    }
+}
+
+func (item *Directive) Encode(labelMap map[string]uint32, buffer []byte, errs *ErrorList) {
+}
+
+func (item *Directive) Label() (label string, ok bool) {
+    return "", false
+}
+
+func (item *Directive) Length() (length uint32) {
+    return item.length
+}
+
+func (item *Directive) Offset() (offset uint32) {
+    return item.offset
+}
+
+func (item *Directive) SetOffset(offset uint32) {
+    item.offset = offset
+}
+
+func (item *Directive) Encoded() (encoded []byte) {
+    return []byte{}
+}
@@ -1,49 +1,48 @@
-package main
+package k750asmlib
 
 import (
 	"bufio"
 	"fmt"
-	"log"
 	"strconv"
-	"strings"
 )
 
+// Coord is a position in a source file. Lines and columns are numbered from 1.
 type Coord struct {
 	Filename string
 	Lineno   int
+	Column   int
 }
 
 func (c Coord) String() (str string) {
-	return fmt.Sprintf("<%s:%d>", c.Filename, c.Lineno)
+	return fmt.Sprintf("<%s:%d:%d>", c.Filename, c.Lineno, c.Column)
 }
 
-var coordStack = make([]Coord, 0, 100)
-
-func pushCoord(c Coord) {
-	coordStack = append(coordStack, c)
-}
-
-func getCoord() (c Coord) {
-	return coordStack[len(coordStack)-1]
-}
+// Before returns whether c comes before other in the source.
+func (c Coord) Before(other Coord) (before bool) {
+	if c.Filename != other.Filename {
+		return c.Filename < other.Filename
+	}
 
-func getCoordRef() (c *Coord) {
-	return &(coordStack[len(coordStack)-1])
-}
+	if c.Lineno != other.Lineno {
+		return c.Lineno < other.Lineno
+	}
 
-func popCoord() {
-	coordStack = coordStack[:len(coordStack)-1]
+	return c.Column < other.Column
 }
 
 type yylexer struct {
-	src     *bufio.Reader
-	buf     []byte
-	empty   bool
-	current byte
+	src      *bufio.Reader
+	buf      []byte
+	empty    bool
+	current  byte
+	coord    Coord // Position of current
+	tokCoord Coord // Position of the last token returned
+	items    []Item
+	errs     *ErrorList
 }
 
-func newLexer(src *bufio.Reader) (y *yylexer) {
-	y = &yylexer{src: src}
+func newLexer(src *bufio.Reader, filename string, errs *ErrorList) (y *yylexer) {
+	y = &yylexer{src: src, coord: Coord{filename, 1, 1}, errs: errs}
 	b, err := src.ReadByte()
 
 	if err == nil {
@@ -56,6 +55,13 @@ func newLexer(src *bufio.Reader) (y *yylexer) {
 func (y *yylexer) getc() (c byte) {
 	if y.current != 0 {
 		y.buf = append(y.buf, y.current)
+
+		if y.current == '\n' {
+			y.coord.Lineno++
+			y.coord.Column = 1
+		} else {
+			y.coord.Column++
+		}
 	}
 
 	y.current = 0
@@ -68,7 +74,7 @@ func (y *yylexer) getc() (c byte) {
 }
 
 func (y *yylexer) Error(e string) {
-	log.Fatal(e)
+	y.errs.Add(y.tokCoord, "%s", e)
 }
 
 func (y *yylexer) Lex(lval *yySymType) int {
@@ -83,7 +89,8 @@ func (y *yylexer) Lex(lval *yySymType) int {
 yystate0:
 
 	y.buf = y.buf[:0]
-	lval.coord = getCoord()
+	lval.coord = y.coord
+	y.tokCoord = y.coord
 
 	goto yystart1
 
@@ -133,6 +140,10 @@ yystate4:
 		goto yyabort
 	case c == 'a':
 		goto yystate5
+	case c == 'b':
+		goto yystate21
+	case c == 'p':
+		goto yystate18
 	case c == 'q':
 		goto yystate8
 	case c == 's':
@@ -186,6 +197,8 @@ yystate11:
 		goto yyabort
 	case c == 'p':
 		goto yystate12
+	case c == 'r':
+		goto yystate20
 	}
 
 yystate12:
@@ -218,7 +231,7 @@ yystate16:
 	c = y.getc()
 	switch {
 	default:
-		goto yyrule9
+		goto yyrule12
 	case c >= '0' && c <= '9':
 		goto yystate16
 	}
@@ -227,18 +240,52 @@ yystate17:
 	c = y.getc()
 	switch {
 	default:
-		goto yyrule10
+		goto yyrule13
 	case c == '.' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c == '_' || c >= 'a' && c <= 'z':
 		goto yystate17
 	}
 
+yystate18:
+	c = y.getc()
+	switch {
+	default:
+		goto yyabort
+	case c == 'c':
+		goto yystate19
+	}
+
+yystate19:
+	c = y.getc()
+	goto yyrule9
+
+yystate20:
+	c = y.getc()
+	goto yyrule10
+
+yystate21:
+	c = y.getc()
+	switch {
+	default:
+		goto yyabort
+	case c >= '0' && c <= '9':
+		goto yystate22
+	}
+
+yystate22:
+	c = y.getc()
+	switch {
+	default:
+		goto yyrule11
+	case c >= '0' && c <= '9':
+		goto yystate22
+	}
+
 yyrule1: // [ \t]+
 
 	goto yystate0
 yyrule2: // [\r\n]+
 	{
 
-		getCoordRef().Lineno += len(strings.Replace(string(y.buf), "\r\n", "\n", -1))
 		return NL
 	}
 yyrule3: // %v[0-7]
@@ -277,18 +324,41 @@ yyrule8: // %at
 		lval.r = AT
 		return REGISTER
 	}
-yyrule9: // [-+]?[0-9]+
+yyrule9: // %pc
+	{
+
+		return PC
+	}
+yyrule10: // %sr
+	{
+
+		return SR
+	}
+yyrule11: // %b[0-9]+
+	{
+
+		n, _ := strconv.Atoi(string(y.buf[2:]))
+		lval.i = n
+		return BITREG
+	}
+yyrule12: // [-+]?[0-9]+
 	{
 
 		i64, err := strconv.ParseInt(string(y.buf), 10, 0)
 		if err != nil {
-			log.Fatal(err)
+			y.errs.Add(lval.coord, "Invalid integer: %s", y.buf)
 		}
 
 		lval.i = int(i64)
+
+		// An explicit sign lets the integer follow a label as an offset
+		if y.buf[0] == '+' || y.buf[0] == '-' {
+			return SIGNED_INTEGER
+		}
+
 		return INTEGER
 	}
-yyrule10: // [a-zA-Z_.][a-zA-Z0-9_.]*
+yyrule13: // [a-zA-Z_.][a-zA-Z0-9_.]*
 	{
 
 		lval.s = string(y.buf)
@@ -299,6 +369,12 @@ yyrule10: // [a-zA-Z_.][a-zA-Z0-9_.]*
 	goto yyabort // silence unused label error
 
 yyabort: // no lexem recognized
+	if len(y.buf) > 0 {
+		// Part of a token was matched (e.g. a sign with no digits after it); return its first
+		// character and leave the lookahead for the next call.
+		return int(y.buf[0])
+	}
+
 	y.empty = true
 	return int(c)
 }
@@ -0,0 +1,122 @@
+package k750asmlib
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "sort"
+    "strings"
+)
+
+// Number of encoded bytes shown on each row of a listing. Longer instructions continue on the
+// following rows.
+const listingBytesPerRow = 8
+
+func formatListingBytes(data []byte) (str string) {
+    parts := make([]string, len(data))
+
+    for i, b := range data {
+        parts[i] = fmt.Sprintf("%02X", b)
+    }
+
+    return strings.Join(parts, " ")
+}
+
+// WriteListing writes a listing to w that shows each line of source next to the offset and encoded
+// bytes of the items assembled from it.
+func (image *Image) WriteListing(w io.Writer, source []string) (err error) {
+    lineItems := make(map[int][]Item)
+
+    for _, item := range image.Items {
+        lineno := item.GetCoord().Lineno
+        lineItems[lineno] = append(lineItems[lineno], item)
+    }
+
+    bw := bufio.NewWriter(w)
+    blank := strings.Repeat(" ", 8)
+    width := listingBytesPerRow*3 - 1
+
+    for i, line := range source {
+        lineno := i + 1
+        offset := blank
+        var encoded []byte
+
+        for _, item := range lineItems[lineno] {
+            if offset == blank {
+                offset = fmt.Sprintf("%08X", item.Offset())
+            }
+
+            encoded = append(encoded, item.Encoded()...)
+        }
+
+        n := len(encoded)
+        if n > listingBytesPerRow {
+            n = listingBytesPerRow
+        }
+
+        fmt.Fprintf(bw, "%s  %-*s  %5d  %s\n", offset, width, formatListingBytes(encoded[:n]), lineno, line)
+
+        for start := n; start < len(encoded); start += listingBytesPerRow {
+            end := start + listingBytesPerRow
+            if end > len(encoded) {
+                end = len(encoded)
+            }
+
+            fmt.Fprintf(bw, "%s  %s\n", blank, formatListingBytes(encoded[start:end]))
+        }
+    }
+
+    return bw.Flush()
+}
+
+type symbol struct {
+    name string
+    addr uint32
+}
+
+type symbolsByAddr []symbol
+
+func (s symbolsByAddr) Len() int {
+    return len(s)
+}
+
+func (s symbolsByAddr) Less(i, j int) bool {
+    if s[i].addr == s[j].addr {
+        return s[i].name < s[j].name
+    }
+
+    return s[i].addr < s[j].addr
+}
+
+func (s symbolsByAddr) Swap(i, j int) {
+    s[i], s[j] = s[j], s[i]
+}
+
+// WriteSymbols writes the image's labels to w, one per line as a hexadecimal address followed by
+// the label name, sorted by address. This is the format read by k750emlib.ReadSymbols.
+func (image *Image) WriteSymbols(w io.Writer) (err error) {
+    symbols := make(symbolsByAddr, 0, len(image.Labels))
+    imported := make(map[string]bool)
+
+    for _, name := range image.Imports {
+        imported[name] = true
+    }
+
+    for name, addr := range image.Labels {
+        if imported[name] {
+            continue
+        }
+
+        symbols = append(symbols, symbol{name, addr})
+    }
+
+    sort.Sort(symbols)
+
+    bw := bufio.NewWriter(w)
+
+    for _, sym := range symbols {
+        fmt.Fprintf(bw, "%08X %s\n", sym.addr, sym.name)
+    }
+
+    return bw.Flush()
+}
@@ -1,4 +1,4 @@
-package main
+package k750asmlib
 
 import (
     "fmt"
@@ -7,7 +7,9 @@ import (
 type Literal interface {
     String() string
     Length() uint32
-    ReduceLabel(map[string]uint32)
+    ReduceLabel(map[string]uint32, *ErrorList)
+    LabelName() (string, bool)
+    LabelAddend() int32
     Reduced() bool
     Value() uint32
 }
@@ -32,10 +34,18 @@ func (l *ConstantLiteral) Length() (length uint32) {
     return 4
 }
 
-func (l *ConstantLiteral) ReduceLabel(labelMap map[string]uint32) {
+func (l *ConstantLiteral) ReduceLabel(labelMap map[string]uint32, errs *ErrorList) {
 
 }
 
+func (l *ConstantLiteral) LabelName() (name string, ok bool) {
+    return "", false
+}
+
+func (l *ConstantLiteral) LabelAddend() (addend int32) {
+    return 0
+}
+
 func (l *ConstantLiteral) Reduced() (reduced bool) {
     return true
 }
@@ -47,15 +57,20 @@ func (l *ConstantLiteral) Value() (value uint32) {
 type LabelLiteral struct {
     coord   Coord
     name    string
+    offset  int32
     value   uint32
     reduced bool
 }
 
 func (l *LabelLiteral) String() (str string) {
+    str = l.name
+    if l.offset != 0 {
+        str += fmt.Sprintf("%+d", l.offset)
+    }
     if l.reduced {
-        return fmt.Sprintf("%s(0x%08X)", l.name, l.value)
+        str += fmt.Sprintf("(0x%08X)", l.value)
     }
-    return l.name
+    return str
 }
 
 func (l *LabelLiteral) Length() (length uint32) {
@@ -63,17 +78,25 @@ func (l *LabelLiteral) Length() (length uint32) {
     return 4
 }
 
-func (l *LabelLiteral) ReduceLabel(labelMap map[string]uint32) {
+func (l *LabelLiteral) ReduceLabel(labelMap map[string]uint32, errs *ErrorList) {
     value, ok := labelMap[l.name]
 
     if !ok {
-        errChan <- &AsmError{l.coord, fmt.Sprintf("Label '%s' not defined", l.name)}
+        errs.Add(l.coord, "Label '%s' not defined", l.name)
     }
 
-    l.value = value
+    l.value = value + uint32(l.offset)
     l.reduced = true
 }
 
+func (l *LabelLiteral) LabelName() (name string, ok bool) {
+    return l.name, true
+}
+
+func (l *LabelLiteral) LabelAddend() (addend int32) {
+    return l.offset
+}
+
 func (l *LabelLiteral) Reduced() (reduced bool) {
     return l.reduced
 }
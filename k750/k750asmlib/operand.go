@@ -0,0 +1,641 @@
+package k750asmlib
+
+import (
+    "fmt"
+)
+
+type Register uint8
+
+const (
+    V0 Register = 0
+    V1 Register = 1
+    V2 Register = 2
+    V3 Register = 3
+    V4 Register = 4
+    V5 Register = 5
+    V6 Register = 6
+    V7 Register = 7
+    A0 Register = 8
+    A1 Register = 9
+    A2 Register = 10
+    A3 Register = 11
+    Q0 Register = 12
+    Q1 Register = 13
+    SP Register = 14
+    AT Register = 15
+
+    NoRegister Register = 255
+)
+
+type MemSize uint8
+
+const (
+    Mem8  MemSize = 8
+    Mem16 MemSize = 16
+    Mem32 MemSize = 32
+)
+
+type Scale uint8
+
+const (
+    Scale2  Scale = 0
+    Scale4  Scale = 1
+    Scale8  Scale = 2
+    Scale16 Scale = 3
+)
+
+func (scale Scale) Factor() (factor int) {
+    return 2 << scale
+}
+
+type OperandType uint8
+
+const (
+    DynamicType OperandType = iota
+    LiteralType
+    BitRegType
+)
+
+var RegisterNames = []string{
+    "v0", "v1", "v2", "v3", "v4", "v5", "v6", "v7",
+    "a0", "a1", "a2", "a3", "q0", "q1", "sp", "at",
+}
+
+func (r Register) String() (str string) {
+    if r == NoRegister {
+        return "0"
+    }
+
+    return "%" + RegisterNames[r]
+}
+
+type Operand interface {
+    String() string
+    Length() uint32
+    Check(*ErrorList)
+    ReduceLabel(map[string]uint32, *ErrorList)
+    LabelName() (string, bool)
+    LabelAddend() int32
+    SetSize(MemSize)
+    SatisfiesType(OperandType) bool
+    LiteralValue() uint32
+    BitValue() uint8
+    EncodeKey() byte
+    EncodeExtra([]byte)
+}
+
+type LiteralOperand struct {
+    coord Coord
+    Literal
+}
+
+func (o *LiteralOperand) Check(errs *ErrorList) {
+
+}
+
+func (o *LiteralOperand) SetSize(size MemSize) {
+
+}
+
+func (o *LiteralOperand) SatisfiesType(t OperandType) (result bool) {
+    return t == DynamicType || t == LiteralType
+}
+
+func (o *LiteralOperand) LiteralValue() (v uint32) {
+    return o.Literal.Value()
+}
+
+func (o *LiteralOperand) BitValue() (v uint8) {
+    return 0
+}
+
+func (o *LiteralOperand) EncodeKey() (key byte) {
+    if o.Length() == 4 {
+        return 0xFF
+    }
+
+    return byte(o.Literal.Value() & 0x7F)
+}
+
+func (o *LiteralOperand) EncodeExtra(extra []byte) {
+    if o.Length() == 4 {
+        v := o.Literal.Value()
+        extra[0] = byte(v >> 24)
+        extra[1] = byte(v >> 16)
+        extra[2] = byte(v >> 8)
+        extra[3] = byte(v)
+    }
+}
+
+type RegisterOperand struct {
+    coord Coord
+    num   Register
+}
+
+func (o *RegisterOperand) String() (str string) {
+    return o.num.String()
+}
+
+func (o *RegisterOperand) Length() (length uint32) {
+    return 0
+}
+
+func (o *RegisterOperand) ReduceLabel(labelMap map[string]uint32, errs *ErrorList) {
+
+}
+
+func (o *RegisterOperand) LabelName() (name string, ok bool) {
+    return "", false
+}
+
+func (o *RegisterOperand) LabelAddend() (addend int32) {
+    return 0
+}
+
+func (o *RegisterOperand) Check(errs *ErrorList) {
+
+}
+
+func (o *RegisterOperand) SetSize(size MemSize) {
+
+}
+
+func (o *RegisterOperand) SatisfiesType(t OperandType) (result bool) {
+    return t == DynamicType
+}
+
+func (o *RegisterOperand) LiteralValue() (v uint32) {
+    return 0
+}
+
+func (o *RegisterOperand) BitValue() (v uint8) {
+    return 0
+}
+
+func (o *RegisterOperand) EncodeKey() (key byte) {
+    return 0x80 | byte(o.num)
+}
+
+func (o *RegisterOperand) EncodeExtra(extra []byte) {
+
+}
+
+type MemoryOperand struct {
+    coord  Coord
+    size   MemSize
+    reg    Register
+    disp   Literal
+    length uint32
+    sized  bool
+}
+
+func (o *MemoryOperand) String() (str string) {
+    if o.reg == NoRegister {
+        return fmt.Sprintf("%d[%s]", o.size, o.disp.String())
+    }
+
+    return fmt.Sprintf("%d[%s + %s]", o.size, o.reg.String(), o.disp.String())
+}
+
+func (o *MemoryOperand) Length() (length uint32) {
+    if o.sized {
+        return o.length
+    }
+
+    v := int32(o.disp.Value())
+
+    if o.reg == NoRegister {
+        length = 4
+
+    } else if !o.disp.Reduced() {
+        length = 2
+
+    } else if v == 0 {
+        length = 0
+
+    } else {
+        length = 2 // Check reports it if it is out of range
+    }
+
+    o.length = length
+    o.sized = true
+    return length
+}
+
+// dispInRange returns whether the displacement fits in 16 bits.
+func (o *MemoryOperand) dispInRange() (ok bool) {
+    v := int32(o.disp.Value())
+    return v >= -0x8000 && v < 0x8000
+}
+
+func (o *MemoryOperand) Check(errs *ErrorList) {
+    // Key 0xFE always reads or writes 32 bits, so a narrower size can't be honoured
+    if o.reg == NoRegister && o.size != Mem32 {
+        errs.Add(o.coord, "Memory operands without a register must be 32 bits wide")
+    }
+
+    o.checkRange(errs)
+}
+
+func (o *MemoryOperand) checkRange(errs *ErrorList) {
+    if o.disp.Reduced() && !o.dispInRange() {
+        errs.Add(o.coord, "Integer displacement out of range (-0x8000 to 0x7FFF): 0x%08X", o.disp.Value())
+    }
+}
+
+func (o *MemoryOperand) ReduceLabel(labelMap map[string]uint32, errs *ErrorList) {
+    if _, ok := o.disp.LabelName(); ok {
+        o.disp.ReduceLabel(labelMap, errs)
+
+        // Constant displacements were already checked in VerifyAndReduce. A label on its own is an
+        // absolute address, which is not limited to 16 bits.
+        if o.reg != NoRegister {
+            o.checkRange(errs)
+        }
+    }
+}
+
+func (o *MemoryOperand) LabelName() (name string, ok bool) {
+    return o.disp.LabelName()
+}
+
+func (o *MemoryOperand) LabelAddend() (addend int32) {
+    return o.disp.LabelAddend()
+}
+
+func (o *MemoryOperand) SetSize(size MemSize) {
+    o.size = size
+}
+
+func (o *MemoryOperand) SatisfiesType(t OperandType) (result bool) {
+    return t == DynamicType
+}
+
+func (o *MemoryOperand) LiteralValue() (v uint32) {
+    return 0
+}
+
+func (o *MemoryOperand) BitValue() (v uint8) {
+    return 0
+}
+
+func (o *MemoryOperand) EncodeKey() (key byte) {
+    if o.reg == NoRegister {
+        return 0xFE
+    }
+
+    if o.Length() == 2 {
+        switch o.size {
+        case Mem8:
+            return 0xC0 | byte(o.reg)
+        case Mem16:
+            return 0xD0 | byte(o.reg)
+        case Mem32:
+            return 0xE0 | byte(o.reg)
+        }
+
+    } else {
+        switch o.size {
+        case Mem8:
+            return 0x90 | byte(o.reg)
+        case Mem16:
+            return 0xA0 | byte(o.reg)
+        case Mem32:
+            return 0xB0 | byte(o.reg)
+        }
+    }
+
+    return 0
+}
+
+func (o *MemoryOperand) EncodeExtra(extra []byte) {
+    if o.reg == NoRegister {
+        v := o.disp.Value()
+        extra[0] = byte(v >> 24)
+        extra[1] = byte(v >> 16)
+        extra[2] = byte(v >> 8)
+        extra[3] = byte(v)
+
+    } else if o.Length() == 2 {
+        v := uint16(int32(o.disp.Value()))
+        extra[0] = byte(v >> 8)
+        extra[1] = byte(v)
+    }
+}
+
+// addressTerm is one of the terms added together between the brackets of a memory operand: a
+// register, a register multiplied by a scale, or a displacement.
+type addressTerm struct {
+    coord Coord
+    reg   Register
+    scale int
+    disp  Literal
+}
+
+// newMemoryOperand combines the terms of a memory operand into a MemoryOperand, or an ArrayOperand
+// if one of the registers is scaled. The forms that can be encoded are:
+//
+//     [disp]
+//     [base], [base + disp]
+//     [base + index*scale], [base + index*scale + disp]
+//
+// where the terms may be written in any order and scale is 2, 4, 8 or 16.
+func newMemoryOperand(coord Coord, terms []addressTerm, errs *ErrorList) (o Operand) {
+    var base, index addressTerm
+    var disp Literal
+
+    base.reg = NoRegister
+    index.reg = NoRegister
+
+    for _, term := range terms {
+        switch {
+        case term.reg == NoRegister:
+            if disp != nil {
+                errs.Add(term.coord, "Memory operand has more than one displacement")
+            }
+
+            disp = term.disp
+
+        case term.scale != 0:
+            if index.reg != NoRegister {
+                errs.Add(term.coord, "Memory operand has more than one scaled index register")
+            }
+
+            index = term
+
+        default:
+            if base.reg != NoRegister {
+                errs.Add(term.coord, "Memory operand has more than one base register (an index register must be scaled by 2, 4, 8 or 16)")
+            }
+
+            base = term
+        }
+    }
+
+    if disp == nil {
+        disp = Zero
+    }
+
+    if index.reg == NoRegister {
+        return &MemoryOperand{coord: coord, reg: base.reg, disp: disp}
+    }
+
+    if base.reg == NoRegister {
+        errs.Add(index.coord, "Scaled index register %s requires a base register", index.reg)
+    }
+
+    var scale Scale
+
+    switch index.scale {
+    case 2:
+        scale = Scale2
+    case 4:
+        scale = Scale4
+    case 8:
+        scale = Scale8
+    case 16:
+        scale = Scale16
+    default:
+        errs.Add(index.coord, "Invalid index scale: %d (expected 2, 4, 8 or 16)", index.scale)
+    }
+
+    return &ArrayOperand{coord: coord, base: base.reg, index: index.reg, scale: scale, disp: disp}
+}
+
+// ArrayOperand addresses memory at base + index*scale + disp, where disp is a signed 16-bit value.
+type ArrayOperand struct {
+    coord Coord
+    size  MemSize
+    base  Register
+    index Register
+    scale Scale
+    disp  Literal
+}
+
+func (o *ArrayOperand) String() (str string) {
+    return fmt.Sprintf("%d[%s + %s*%d + %s]", o.size, o.base.String(), o.index.String(), o.scale.Factor(), o.disp.String())
+}
+
+func (o *ArrayOperand) Length() (length uint32) {
+    // A byte holding the base and index registers, followed by a 16-bit displacement
+    return 3
+}
+
+func (o *ArrayOperand) ReduceLabel(labelMap map[string]uint32, errs *ErrorList) {
+    if _, ok := o.disp.LabelName(); ok {
+        o.disp.ReduceLabel(labelMap, errs)
+        o.Check(errs) // Constant displacements were already checked in VerifyAndReduce
+    }
+}
+
+func (o *ArrayOperand) LabelName() (name string, ok bool) {
+    return o.disp.LabelName()
+}
+
+func (o *ArrayOperand) LabelAddend() (addend int32) {
+    return o.disp.LabelAddend()
+}
+
+func (o *ArrayOperand) Check(errs *ErrorList) {
+    v := int32(o.disp.Value())
+
+    if o.disp.Reduced() && (v < -0x8000 || v >= 0x8000) {
+        errs.Add(o.coord, "Integer displacement out of range (-0x8000 to 0x7FFF): 0x%08X", o.disp.Value())
+    }
+}
+
+func (o *ArrayOperand) SetSize(size MemSize) {
+    o.size = size
+}
+
+func (o *ArrayOperand) SatisfiesType(t OperandType) (result bool) {
+    return t == DynamicType
+}
+
+func (o *ArrayOperand) LiteralValue() (v uint32) {
+    return 0
+}
+
+func (o *ArrayOperand) BitValue() (v uint8) {
+    return 0
+}
+
+func (o *ArrayOperand) EncodeKey() (key byte) {
+    switch o.size {
+    case Mem8:
+        return 0xF0 | byte(o.scale)
+    case Mem16:
+        return 0xF4 | byte(o.scale)
+    case Mem32:
+        return 0xF8 | byte(o.scale)
+    }
+
+    return 0
+}
+
+func (o *ArrayOperand) EncodeExtra(extra []byte) {
+    v := uint16(int32(o.disp.Value()))
+    extra[0] = (byte(o.base) << 4) | (byte(o.index) & 0x0F)
+    extra[1] = byte(v >> 8)
+    extra[2] = byte(v)
+}
+
+type PCOperand struct {
+    coord Coord
+}
+
+func (o *PCOperand) String() (str string) {
+    return "%pc"
+}
+
+func (o *PCOperand) Length() (length uint32) {
+    return 0
+}
+
+func (o *PCOperand) ReduceLabel(labelMap map[string]uint32, errs *ErrorList) {
+
+}
+
+func (o *PCOperand) LabelName() (name string, ok bool) {
+    return "", false
+}
+
+func (o *PCOperand) LabelAddend() (addend int32) {
+    return 0
+}
+
+func (o *PCOperand) Check(errs *ErrorList) {
+
+}
+
+func (o *PCOperand) SetSize(size MemSize) {
+
+}
+
+func (o *PCOperand) SatisfiesType(t OperandType) (result bool) {
+    return t == DynamicType
+}
+
+func (o *PCOperand) LiteralValue() (v uint32) {
+    return 0
+}
+
+func (o *PCOperand) BitValue() (v uint8) {
+    return 0
+}
+
+func (o *PCOperand) EncodeKey() (key byte) {
+    return 0xFC
+}
+
+func (o *PCOperand) EncodeExtra(extra []byte) {
+
+}
+
+type SROperand struct {
+    coord Coord
+}
+
+func (o *SROperand) String() (str string) {
+    return "%sr"
+}
+
+func (o *SROperand) Length() (length uint32) {
+    return 0
+}
+
+func (o *SROperand) ReduceLabel(labelMap map[string]uint32, errs *ErrorList) {
+
+}
+
+func (o *SROperand) LabelName() (name string, ok bool) {
+    return "", false
+}
+
+func (o *SROperand) LabelAddend() (addend int32) {
+    return 0
+}
+
+func (o *SROperand) Check(errs *ErrorList) {
+
+}
+
+func (o *SROperand) SetSize(size MemSize) {
+
+}
+
+func (o *SROperand) SatisfiesType(t OperandType) (result bool) {
+    return t == DynamicType
+}
+
+func (o *SROperand) LiteralValue() (v uint32) {
+    return 0
+}
+
+func (o *SROperand) BitValue() (v uint8) {
+    return 0
+}
+
+func (o *SROperand) EncodeKey() (key byte) {
+    return 0xFD
+}
+
+func (o *SROperand) EncodeExtra(extra []byte) {
+
+}
+
+// BitRegOperand is a single bit of SR, written %b0 to %b15.
+type BitRegOperand struct {
+    coord Coord
+    num   int
+}
+
+func (o *BitRegOperand) String() (str string) {
+    return fmt.Sprintf("%%b%d", o.num)
+}
+
+func (o *BitRegOperand) Length() (length uint32) {
+    return 0
+}
+
+func (o *BitRegOperand) ReduceLabel(labelMap map[string]uint32, errs *ErrorList) {
+
+}
+
+func (o *BitRegOperand) LabelName() (name string, ok bool) {
+    return "", false
+}
+
+func (o *BitRegOperand) LabelAddend() (addend int32) {
+    return 0
+}
+
+func (o *BitRegOperand) Check(errs *ErrorList) {
+    if o.num > 15 {
+        errs.Add(o.coord, "Invalid bit register: %s (expected %%b0 to %%b15)", o)
+    }
+}
+
+func (o *BitRegOperand) SetSize(size MemSize) {
+
+}
+
+func (o *BitRegOperand) SatisfiesType(t OperandType) (result bool) {
+    return t == BitRegType
+}
+
+func (o *BitRegOperand) LiteralValue() (v uint32) {
+    return 0
+}
+
+func (o *BitRegOperand) BitValue() (v uint8) {
+    return uint8(o.num)
+}
+
+func (o *BitRegOperand) EncodeKey() (key byte) {
+    return 0
+}
+
+func (o *BitRegOperand) EncodeExtra(extra []byte) {
+
+}
@@ -0,0 +1,89 @@
+%{
+    package k750asmlib
+%}
+
+%union {
+    i int
+    r Register
+    s string
+    it Item
+    o Operand
+    oL []Operand
+    l Literal
+    t addressTerm
+    tL []addressTerm
+    
+    coord Coord
+}
+
+%token <i> INTEGER, SIGNED_INTEGER, NL, BITREG
+%token <r> REGISTER
+%token <s> IDENTIFIER
+%token PC, SR
+
+// A signed integer straight after a label is taken as its offset rather than as a separate term
+%nonassoc LABEL
+%nonassoc SIGNED_INTEGER
+
+%type <it> rawitem
+%type <o> memory_operand, memory_operand_content, operand
+%type <oL> operands, opt_operands
+%type <l> integer
+%type <t> address_term
+%type <tL> address_terms
+
+%%
+
+assembly:               itemlist
+
+itemlist:               itemlist item
+                    |   item
+
+item:                   rawitem NL                              {yylex.(*yylexer).items = append(yylex.(*yylexer).items, $1)}
+                    |   NL
+                    |   error NL
+
+rawitem:                IDENTIFIER opt_operands                 {$$ = newItem($<coord>1, $1, $2)}
+                    |   IDENTIFIER ':'                          {$$ = Item(&Label       {coord: $<coord>1, name: $1})}
+
+opt_operands:           operands                                {$$ = $1}
+                    |                                           {$$ = nil}
+
+operands:               operands ',' operand                    {$$ = append($1, $3)}
+                    |   operand                                 {$$ = []Operand{$1}}
+
+operand:                integer                                 {$$ = Operand(&LiteralOperand  {coord: $<coord>1, Literal: $1})}
+                    |   REGISTER                                {$$ = Operand(&RegisterOperand {coord: $<coord>1, num: $1})}
+                    |   PC                                      {$$ = Operand(&PCOperand       {coord: $<coord>1})}
+                    |   SR                                      {$$ = Operand(&SROperand       {coord: $<coord>1})}
+                    |   BITREG                                  {$$ = Operand(&BitRegOperand   {coord: $<coord>1, num: $1})}
+                    |   memory_operand                          {$$ = $1}
+
+memory_operand:         INTEGER '[' memory_operand_content ']'
+    {
+        size := $1
+        if size != 8 && size != 16 && size != 32 {
+            yylex.(*yylexer).errs.Add($<coord>1, "Invalid memory addressing size: %d (expected 8, 16 or 32)", size)
+        }
+        
+        $$ = $3
+        $$.SetSize(MemSize(size))
+    }
+
+memory_operand_content: address_terms                           {$$ = newMemoryOperand($<coord>1, $1, yylex.(*yylexer).errs)}
+
+address_terms:          address_terms '+' address_term          {$$ = append($1, $3)}
+                    |   address_terms '-' INTEGER               {$$ = append($1, addressTerm{coord: $<coord>3, reg: NoRegister, disp: &ConstantLiteral{coord: $<coord>3, value: uint32(-$3)}})}
+                    |   address_terms SIGNED_INTEGER            {$$ = append($1, addressTerm{coord: $<coord>2, reg: NoRegister, disp: &ConstantLiteral{coord: $<coord>2, value: uint32($2)}})}
+                    |   address_term                            {$$ = []addressTerm{$1}}
+
+address_term:           REGISTER                                {$$ = addressTerm{coord: $<coord>1, reg: $1}}
+                    |   REGISTER '*' INTEGER                    {$$ = addressTerm{coord: $<coord>1, reg: $1, scale: $3}}
+                    |   integer                                 {$$ = addressTerm{coord: $<coord>1, reg: NoRegister, disp: $1}}
+
+integer:                INTEGER                                 {$$ = Literal(&ConstantLiteral {coord: $<coord>1, value: uint32($1)})}
+                    |   SIGNED_INTEGER                          {$$ = Literal(&ConstantLiteral {coord: $<coord>1, value: uint32($1)})}
+                    |   IDENTIFIER %prec LABEL                  {$$ = Literal(&LabelLiteral    {coord: $<coord>1, name: $1})}
+                    |   IDENTIFIER SIGNED_INTEGER               {$$ = Literal(&LabelLiteral    {coord: $<coord>1, name: $1, offset: int32($2)})}
+
+%%
@@ -13,6 +13,10 @@ type Peripheral interface {
     Stop()
 }
 
+// The bit of SR that allows interrupts to be delivered. It is cleared when a handler is entered
+// and set again by reti.
+const InterruptEnableBit = 0
+
 type Emulator struct {
     PC          uint32
     SR          uint32
@@ -20,6 +24,8 @@ type Emulator struct {
     Regs        [16]uint32
     Memory      []byte
     Peripherals []Peripheral
+    Handlers    [256]uint32 // Handler addresses registered with rih
+    HandlerSet  [256]bool   // Whether a handler has been registered for each interrupt
 }
 
 func NewEmulator() (em *Emulator) {
@@ -28,6 +34,15 @@ func NewEmulator() (em *Emulator) {
     return em
 }
 
+// LoadImage copies data into memory starting at addr, growing the memory if necessary.
+func (em *Emulator) LoadImage(data []byte, addr uint32) {
+    if end := addr + uint32(len(data)); end > uint32(len(em.Memory)) {
+        em.GrowMemory(end)
+    }
+
+    copy(em.Memory[addr:], data)
+}
+
 func (em *Emulator) GrowMemory(newsize uint32) {
     m := make([]byte, newsize)
     copy(m, em.Memory)
@@ -168,12 +183,53 @@ func (em *Emulator) LoadOperand(key uint8) (operand Operand) {
     return nil
 }
 
+// Interrupt enters the handler for interrupt n, if one has been registered: the address of the
+// next instruction is pushed and interrupts are disabled until the handler returns.
+func (em *Emulator) Interrupt(n uint8) {
+    if !em.HandlerSet[n] {
+        return
+    }
+
+    em.Push(em.PC)
+    em.SetBit(InterruptEnableBit, false)
+    em.PC = em.Handlers[n]
+}
+
+// checkInterrupts delivers an interrupt raised by a peripheral, if interrupts are enabled and there
+// is one pending.
+func (em *Emulator) checkInterrupts() {
+    if !em.GetBit(InterruptEnableBit) {
+        return
+    }
+
+    for _, pp := range em.Peripherals {
+        select {
+        case n := <-pp.GetPendingInterruptsChannel():
+            em.Interrupt(n)
+            return
+
+        default:
+        }
+    }
+}
+
+func (em *Emulator) peripheral(p uint32) (pp Peripheral, err error) {
+    if p >= uint32(len(em.Peripherals)) {
+        return nil, &Error{ErrNoPeripheral, fmt.Sprintf("No peripheral %d", p)}
+    }
+
+    return em.Peripherals[p], nil
+}
+
+// RunOne delivers any pending interrupt and then executes a single instruction.
 func (em *Emulator) RunOne() (err error) {
+    em.checkInterrupts()
+
     inst := em.Fetch8()
 
     switch inst {
     case 0x00:
-        //return em.doNop()
+        return nil
     case 0x01:
         return em.doMov()
     case 0x02:
@@ -197,66 +253,66 @@ func (em *Emulator) RunOne() (err error) {
     case 0x0B:
         return em.doPpn()
     case 0x0C:
-        //return em.doInt()
+        return em.doInt()
     case 0x0D:
-        //return em.doRih()
+        return em.doRih()
     case 0x0E:
-        //return em.doJbc()
+        return em.doJumpBit(false)
     case 0x0F:
-        //return em.doJbs()
+        return em.doJumpBit(true)
     case 0x10:
-        //return em.doAdd()
+        return em.doArith(func(x, y uint32) uint32 { return x + y })
     case 0x11:
-        //return em.doSub()
+        return em.doArith(func(x, y uint32) uint32 { return x - y })
     case 0x12:
-        //return em.doAnd()
+        return em.doArith(func(x, y uint32) uint32 { return x & y })
     case 0x13:
-        //return em.doOr()
+        return em.doArith(func(x, y uint32) uint32 { return x | y })
     case 0x14:
-        //return em.doXor()
+        return em.doArith(func(x, y uint32) uint32 { return x ^ y })
     case 0x15:
-        //return em.doPpi()
+        return em.doPpi()
     case 0x16:
-        //return em.doPpr()
+        return em.doPpr()
     case 0x17:
-        //return em.doPpw()
+        return em.doPpw()
     case 0x18:
-        //return em.doJeq()
+        return em.doJump(func(x, y uint32) bool { return x == y })
     case 0x19:
-        //return em.doJne()
+        return em.doJump(func(x, y uint32) bool { return x != y })
     case 0x1A:
-        //return em.doJlt()
+        return em.doJump(func(x, y uint32) bool { return int32(x) < int32(y) })
     case 0x1B:
-        //return em.doJge()
+        return em.doJump(func(x, y uint32) bool { return int32(x) >= int32(y) })
     case 0x1C:
-        //return em.doJac()
+        return em.doJump(func(x, y uint32) bool { return x&y == 0 })
     case 0x1D:
-        //return em.doJas()
+        return em.doJump(func(x, y uint32) bool { return x&y != 0 })
     case 0x1E:
-        //return em.doCall()
+        return em.doCall()
     case 0x1F:
-        //return em.doReti()
+        return em.doReti()
     }
 
     switch {
     case inst&0xF0 == 0x20:
-        //return em.doCb(inst & 0x0F)
+        return em.doCb(inst & 0x0F)
     case inst&0xF0 == 0x30:
-        //return em.doSb(inst & 0x0F)
+        return em.doSb(inst & 0x0F)
     case inst&0xE0 == 0x40:
-        //return em.doRll(inst & 0x1F)
+        return em.doShift(inst&0x1F, func(x uint32, n uint8) uint32 { return x<<n | x>>(32-n) })
     case inst&0xE0 == 0x60:
-        //return em.doRlr(inst & 0x1F)
+        return em.doShift(inst&0x1F, func(x uint32, n uint8) uint32 { return x>>n | x<<(32-n) })
     case inst&0xE0 == 0x80:
-        //return em.doShl(inst & 0x1F)
+        return em.doShift(inst&0x1F, func(x uint32, n uint8) uint32 { return x << n })
     case inst&0xE0 == 0xA0:
-        //return em.doLshr(inst & 0x1F)
+        return em.doShift(inst&0x1F, func(x uint32, n uint8) uint32 { return x >> n })
     case inst&0xE0 == 0xC0:
-        //return em.doAshr(inst & 0x1F)
+        return em.doShift(inst&0x1F, func(x uint32, n uint8) uint32 { return uint32(int32(x) >> n) })
     case inst&0xFE == 0xE0:
-        //return em.doLdb(inst & 0x01)
+        return em.doLdb(inst & 0x01)
     case inst&0xFE == 0xE2:
-        //return em.doStb(inst & 0x01)
+        return em.doStb(inst & 0x01)
     }
 
     return &Error{ErrInvalidOpcode, fmt.Sprintf("Invalid opcode 0x%02X", inst)}
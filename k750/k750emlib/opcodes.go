@@ -142,3 +142,254 @@ func (em *Emulator) doPpn() (err error) {
 
     return nil
 }
+
+func (em *Emulator) doInt() (err error) {
+    var a Operand
+    em.loadOperands(&a)
+
+    x, err := a.Load(em)
+    if err != nil {
+        return err
+    }
+
+    em.Interrupt(uint8(x))
+    return nil
+}
+
+func (em *Emulator) doRih() (err error) {
+    var a, b Operand
+    em.loadOperands(&a, &b)
+
+    x, err := a.Load(em)
+    if err != nil {
+        return err
+    }
+
+    y, err := b.Load(em)
+    if err != nil {
+        return err
+    }
+
+    em.Handlers[uint8(x)] = y
+    em.HandlerSet[uint8(x)] = true
+    return nil
+}
+
+// doJumpBit handles jbc and jbs, which jump if a bit of SR is clear or set respectively.
+func (em *Emulator) doJumpBit(jumpIfSet bool) (err error) {
+    key := em.Fetch8()
+    bit := em.Fetch8() & 0x0F
+    a := em.LoadOperand(key)
+
+    target, err := a.Load(em)
+    if err != nil {
+        return err
+    }
+
+    if em.GetBit(bit) == jumpIfSet {
+        em.PC = target
+    }
+
+    return nil
+}
+
+// doArith handles the three-operand arithmetic and bitwise instructions, which store the result of
+// op applied to the second and third operands in the first.
+func (em *Emulator) doArith(op func(x, y uint32) uint32) (err error) {
+    var a, b, c Operand
+    em.loadOperands(&a, &b, &c)
+
+    x, err := b.Load(em)
+    if err != nil {
+        return err
+    }
+
+    y, err := c.Load(em)
+    if err != nil {
+        return err
+    }
+
+    return a.Store(em, op(x, y))
+}
+
+func (em *Emulator) doPpi() (err error) {
+    var a, b Operand
+    em.loadOperands(&a, &b)
+
+    p, err := a.Load(em)
+    if err != nil {
+        return err
+    }
+
+    n, err := b.Load(em)
+    if err != nil {
+        return err
+    }
+
+    pp, err := em.peripheral(p)
+    if err != nil {
+        return err
+    }
+
+    pp.Interrupt(uint8(n))
+    return nil
+}
+
+func (em *Emulator) doPpr() (err error) {
+    var a, b, c Operand
+    em.loadOperands(&a, &b, &c)
+
+    p, err := b.Load(em)
+    if err != nil {
+        return err
+    }
+
+    reg, err := c.Load(em)
+    if err != nil {
+        return err
+    }
+
+    pp, err := em.peripheral(p)
+    if err != nil {
+        return err
+    }
+
+    return a.Store(em, pp.ReadRegister(uint8(reg)))
+}
+
+func (em *Emulator) doPpw() (err error) {
+    var a, b, c Operand
+    em.loadOperands(&a, &b, &c)
+
+    p, err := a.Load(em)
+    if err != nil {
+        return err
+    }
+
+    reg, err := b.Load(em)
+    if err != nil {
+        return err
+    }
+
+    x, err := c.Load(em)
+    if err != nil {
+        return err
+    }
+
+    pp, err := em.peripheral(p)
+    if err != nil {
+        return err
+    }
+
+    pp.WriteRegister(uint8(reg), x)
+    return nil
+}
+
+// doJump handles the compare-and-jump instructions, which jump to the third operand if cond holds
+// for the first two.
+func (em *Emulator) doJump(cond func(x, y uint32) bool) (err error) {
+    var a, b, c Operand
+    em.loadOperands(&a, &b, &c)
+
+    x, err := a.Load(em)
+    if err != nil {
+        return err
+    }
+
+    y, err := b.Load(em)
+    if err != nil {
+        return err
+    }
+
+    target, err := c.Load(em)
+    if err != nil {
+        return err
+    }
+
+    if cond(x, y) {
+        em.PC = target
+    }
+
+    return nil
+}
+
+func (em *Emulator) doCall() (err error) {
+    var a Operand
+    em.loadOperands(&a)
+
+    target, err := a.Load(em)
+    if err != nil {
+        return err
+    }
+
+    em.Push(em.PC)
+    em.PC = target
+    return nil
+}
+
+func (em *Emulator) doReti() (err error) {
+    em.PC = em.Pop()
+    em.SetBit(InterruptEnableBit, true)
+    return nil
+}
+
+func (em *Emulator) doCb(bit uint8) (err error) {
+    em.SetBit(bit, false)
+    return nil
+}
+
+func (em *Emulator) doSb(bit uint8) (err error) {
+    em.SetBit(bit, true)
+    return nil
+}
+
+// doShift handles the shifts and rotates, which store the result of op applied to the second
+// operand and the count encoded in the opcode in the first.
+func (em *Emulator) doShift(count uint8, op func(x uint32, n uint8) uint32) (err error) {
+    var a, b Operand
+    em.loadOperands(&a, &b)
+
+    x, err := b.Load(em)
+    if err != nil {
+        return err
+    }
+
+    return a.Store(em, op(x, count))
+}
+
+// doLdb loads bit j of the operand into bit x of SR.
+func (em *Emulator) doLdb(jHigh uint8) (err error) {
+    key := em.Fetch8()
+    jx := em.Fetch8()
+    j := (jHigh << 4) | (jx >> 4)
+    a := em.LoadOperand(key)
+
+    v, err := a.Load(em)
+    if err != nil {
+        return err
+    }
+
+    em.SetBit(jx&0x0F, (v>>j)&1 != 0)
+    return nil
+}
+
+// doStb stores bit x of SR into bit j of the operand.
+func (em *Emulator) doStb(jHigh uint8) (err error) {
+    key := em.Fetch8()
+    jx := em.Fetch8()
+    j := (jHigh << 4) | (jx >> 4)
+    a := em.LoadOperand(key)
+
+    v, err := a.Load(em)
+    if err != nil {
+        return err
+    }
+
+    if em.GetBit(jx & 0x0F) {
+        v |= 1 << j
+    } else {
+        v &^= 1 << j
+    }
+
+    return a.Store(em, v)
+}
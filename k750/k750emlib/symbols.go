@@ -0,0 +1,101 @@
+package k750emlib
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+type Symbol struct {
+    Name string
+    Addr uint32
+}
+
+// Symbols is a table of symbols sorted by address, as loaded from a symbol file written by k750asm.
+type Symbols []Symbol
+
+func (s Symbols) Len() int {
+    return len(s)
+}
+
+func (s Symbols) Less(i, j int) bool {
+    return s[i].Addr < s[j].Addr
+}
+
+func (s Symbols) Swap(i, j int) {
+    s[i], s[j] = s[j], s[i]
+}
+
+// ReadSymbols reads a symbol file from r. Each non-blank line holds a hexadecimal address followed
+// by a symbol name.
+func ReadSymbols(r io.Reader) (symbols Symbols, err error) {
+    scanner := bufio.NewScanner(r)
+    lineno := 0
+
+    for scanner.Scan() {
+        lineno++
+
+        fields := strings.Fields(scanner.Text())
+        if len(fields) == 0 {
+            continue
+        }
+
+        if len(fields) != 2 {
+            return nil, fmt.Errorf("[line %d] Expected an address and a name", lineno)
+        }
+
+        addr, err := strconv.ParseUint(fields[0], 16, 32)
+        if err != nil {
+            return nil, fmt.Errorf("[line %d] Invalid address: %s", lineno, fields[0])
+        }
+
+        symbols = append(symbols, Symbol{fields[1], uint32(addr)})
+    }
+
+    if err = scanner.Err(); err != nil {
+        return nil, err
+    }
+
+    sort.Stable(symbols)
+    return symbols, nil
+}
+
+// Addr returns the address of the symbol with the given name.
+func (s Symbols) Addr(name string) (addr uint32, ok bool) {
+    for _, sym := range s {
+        if sym.Name == name {
+            return sym.Addr, true
+        }
+    }
+
+    return 0, false
+}
+
+// Lookup finds the closest symbol at or below addr and returns its name and the distance from it.
+func (s Symbols) Lookup(addr uint32) (name string, offset uint32, ok bool) {
+    i := sort.Search(len(s), func(i int) bool { return s[i].Addr > addr })
+    if i == 0 {
+        return "", 0, false
+    }
+
+    sym := s[i-1]
+    return sym.Name, addr - sym.Addr, true
+}
+
+// Format returns addr in symbolic form (e.g. "loop+0x4") for use in traces, or as a plain hex
+// address if no symbol precedes it.
+func (s Symbols) Format(addr uint32) (str string) {
+    name, offset, ok := s.Lookup(addr)
+
+    switch {
+    case !ok:
+        return fmt.Sprintf("0x%08X", addr)
+    case offset == 0:
+        return name
+    }
+
+    return fmt.Sprintf("%s+0x%X", name, offset)
+}
@@ -45,6 +45,7 @@ type ErrNum uint8
 const (
     ErrInvalidOpcode ErrNum = iota
     ErrStoreToLiteral
+    ErrNoPeripheral
 )
 
 type Error struct {
@@ -0,0 +1,376 @@
+// Command k750ld links relocatable objects produced by k750asm -c into a single image.
+//
+// Sections with the same name are concatenated in the order the objects are given. Each section
+// is placed at the address given with -section, or straight after the previous section otherwise.
+package main
+
+import (
+    "bufio"
+    "flag"
+    "fmt"
+    "github.com/kierdavis/go/binaryimage"
+    "github.com/kierdavis/go/k750/k750asmlib"
+    "github.com/kierdavis/go/k750/k750emlib"
+    "github.com/kierdavis/go/k750/k750obj"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// sectionAddrs implements flag.Value for repeated -section name=addr options.
+type sectionAddrs map[string]uint32
+
+func (s sectionAddrs) String() (str string) {
+    parts := make([]string, 0, len(s))
+
+    for name, addr := range s {
+        parts = append(parts, fmt.Sprintf("%s=0x%X", name, addr))
+    }
+
+    return strings.Join(parts, ",")
+}
+
+func (s sectionAddrs) Set(value string) (err error) {
+    parts := strings.SplitN(value, "=", 2)
+    if len(parts) != 2 {
+        return fmt.Errorf("expected name=address, got %q", value)
+    }
+
+    addr, err := strconv.ParseUint(parts[1], 0, 32)
+    if err != nil {
+        return err
+    }
+
+    s[parts[0]] = uint32(addr)
+    return nil
+}
+
+// input is an object together with the address each of its sections was placed at.
+type input struct {
+    name  string
+    obj   *k750obj.Object
+    addrs map[string]uint32
+}
+
+// outSection is a section of the output image, made up of the same-named sections of each input.
+type outSection struct {
+    name string
+    addr uint32
+    data []byte
+}
+
+type Linker struct {
+    Inputs   []*input
+    Sections []*outSection
+    Globals  map[string]uint32
+    Errors   []error
+}
+
+func (l *Linker) errorf(format string, args ...interface{}) {
+    l.Errors = append(l.Errors, fmt.Errorf(format, args...))
+}
+
+func (l *Linker) getSection(name string) (sec *outSection) {
+    for _, sec := range l.Sections {
+        if sec.name == name {
+            return sec
+        }
+    }
+
+    sec = &outSection{name: name}
+    l.Sections = append(l.Sections, sec)
+    return sec
+}
+
+// Layout concatenates the input sections and assigns an address to each output section.
+func (l *Linker) Layout(addrs sectionAddrs) {
+    offsets := make(map[*input]map[string]uint32)
+
+    for _, in := range l.Inputs {
+        offsets[in] = make(map[string]uint32)
+
+        for _, sec := range in.obj.Sections {
+            out := l.getSection(sec.Name)
+            offsets[in][sec.Name] = uint32(len(out.data))
+            out.data = append(out.data, sec.Data...)
+        }
+    }
+
+    next := uint32(0)
+
+    for _, sec := range l.Sections {
+        if addr, ok := addrs[sec.name]; ok {
+            sec.addr = addr
+        } else {
+            sec.addr = next
+        }
+
+        next = sec.addr + uint32(len(sec.data))
+    }
+
+    for _, in := range l.Inputs {
+        in.addrs = make(map[string]uint32)
+
+        for name, offset := range offsets[in] {
+            in.addrs[name] = l.getSection(name).addr + offset
+        }
+    }
+
+    sorted := make([]*outSection, len(l.Sections))
+    copy(sorted, l.Sections)
+    sort.Sort(sectionsByAddr(sorted))
+
+    for i := 1; i < len(sorted); i++ {
+        prev, sec := sorted[i-1], sorted[i]
+
+        if len(prev.data) > 0 && prev.addr+uint32(len(prev.data)) > sec.addr {
+            l.errorf("Section '%s' (0x%08X) overlaps section '%s' (0x%08X-0x%08X)", sec.name, sec.addr, prev.name, prev.addr, prev.addr+uint32(len(prev.data))-1)
+        }
+    }
+}
+
+type sectionsByAddr []*outSection
+
+func (s sectionsByAddr) Len() int {
+    return len(s)
+}
+
+func (s sectionsByAddr) Less(i, j int) bool {
+    return s[i].addr < s[j].addr
+}
+
+func (s sectionsByAddr) Swap(i, j int) {
+    s[i], s[j] = s[j], s[i]
+}
+
+// symbolAddr returns the final address of a symbol defined in in.
+func (in *input) symbolAddr(sym k750obj.Symbol) (addr uint32) {
+    return in.addrs[sym.Section] + sym.Offset
+}
+
+// imports returns whether in declares name as defined in another object.
+func (in *input) imports(name string) (ok bool) {
+    for _, imported := range in.obj.Imports {
+        if imported == name {
+            return true
+        }
+    }
+
+    return false
+}
+
+// Resolve builds the table of exported symbols.
+func (l *Linker) Resolve() {
+    l.Globals = make(map[string]uint32)
+    definedIn := make(map[string]string)
+
+    for _, in := range l.Inputs {
+        for _, sym := range in.obj.Symbols {
+            if !sym.Exported {
+                continue
+            }
+
+            if other, ok := definedIn[sym.Name]; ok {
+                l.errorf("%s: symbol '%s' already defined in %s", in.name, sym.Name, other)
+                continue
+            }
+
+            l.Globals[sym.Name] = in.symbolAddr(sym)
+            definedIn[sym.Name] = in.name
+        }
+    }
+
+    for _, in := range l.Inputs {
+        for _, name := range in.obj.Imports {
+            if _, ok := l.Globals[name]; !ok {
+                l.errorf("%s: undefined symbol '%s'", in.name, name)
+            }
+        }
+    }
+}
+
+// Relocate patches every relocation in the output sections with its symbol's final address.
+// Symbols defined in the same object take precedence over exported ones.
+func (l *Linker) Relocate() {
+    for _, in := range l.Inputs {
+        for _, sec := range in.obj.Sections {
+            out := l.getSection(sec.Name)
+            base := in.addrs[sec.Name] - out.addr
+
+            for _, reloc := range sec.Relocs {
+                var value uint32
+
+                if sym, ok := in.obj.Symbol(reloc.Symbol); ok {
+                    value = in.symbolAddr(sym)
+                } else if addr, ok := l.Globals[reloc.Symbol]; ok {
+                    value = addr
+                } else {
+                    if !in.imports(reloc.Symbol) { // Otherwise already reported by Resolve
+                        l.errorf("%s: unresolved reference to '%s' in section '%s'", in.name, reloc.Symbol, sec.Name)
+                    }
+
+                    continue
+                }
+
+                value += uint32(reloc.Addend)
+
+                if reloc.Offset+reloc.Type.Size() > uint32(len(sec.Data)) {
+                    l.errorf("%s: relocation at 0x%X lies outside section '%s'", in.name, reloc.Offset, sec.Name)
+                    continue
+                }
+
+                field := out.data[base+reloc.Offset:]
+
+                switch reloc.Type {
+                case k750obj.RelocAbs32:
+                    field[0] = byte(value >> 24)
+                    field[1] = byte(value >> 16)
+                    field[2] = byte(value >> 8)
+                    field[3] = byte(value)
+
+                case k750obj.RelocAbs16:
+                    if int32(value) < -0x8000 || int32(value) >= 0x8000 {
+                        l.errorf("%s: address of '%s' (0x%08X) does not fit in a 16-bit displacement", in.name, reloc.Symbol, value)
+                        continue
+                    }
+
+                    field[0] = byte(value >> 8)
+                    field[1] = byte(value)
+
+                default:
+                    l.errorf("%s: unknown relocation type %d", in.name, reloc.Type)
+                }
+            }
+        }
+    }
+}
+
+// Image returns the linked sections as a binary image, with a segment for each section and every
+// symbol. Execution starts at the beginning of the text section.
+func (l *Linker) Image() (image *binaryimage.Image) {
+    image = binaryimage.New()
+
+    for _, sec := range l.Sections {
+        image.PutBytes(uint64(sec.addr), sec.data)
+
+        // Overlaps have already been reported by Layout
+        kind := binaryimage.KindForName(sec.name)
+        image.AddSegment(binaryimage.NewSegment(sec.name, kind, uint64(sec.addr), uint64(len(sec.data))))
+
+        if kind == binaryimage.Code && sec.name == k750asmlib.DefaultSection {
+            image.SetEntry(uint64(sec.addr))
+        }
+    }
+
+    for _, sym := range l.Symbols() {
+        image.AddSymbol(sym.Name, uint64(sym.Addr))
+    }
+
+    return image
+}
+
+// Symbols returns every symbol of every input at its final address.
+func (l *Linker) Symbols() (symbols k750emlib.Symbols) {
+    for _, in := range l.Inputs {
+        for _, sym := range in.obj.Symbols {
+            symbols = append(symbols, k750emlib.Symbol{Name: sym.Name, Addr: in.symbolAddr(sym)})
+        }
+    }
+
+    sort.Stable(symbols)
+    return symbols
+}
+
+func readObject(fname string) (obj *k750obj.Object, err error) {
+    f, err := os.Open(fname)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    return k750obj.Read(f)
+}
+
+func die(err error) {
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+        os.Exit(1)
+    }
+}
+
+func main() {
+    addrs := make(sectionAddrs)
+
+    outName := flag.String("o", "a.bin", "output file")
+    format := flag.String("f", "raw", "output format: raw, hex or elf")
+    symName := flag.String("s", "", "write a symbol file mapping labels to addresses to this file")
+    flag.Var(addrs, "section", "place a section at an address, as name=addr (may be repeated)")
+    flag.Parse()
+
+    if flag.NArg() < 1 {
+        fmt.Fprintf(os.Stderr, "Not enough arguments\nusage: %s [-o out] [-f raw|hex|elf] [-s file.sym] [-section name=addr]... file.o...\n", os.Args[0])
+        os.Exit(2)
+    }
+
+    if *format != "raw" && *format != "hex" && *format != "elf" {
+        fmt.Fprintf(os.Stderr, "Unknown output format: %s\n", *format)
+        os.Exit(2)
+    }
+
+    l := new(Linker)
+
+    for _, fname := range flag.Args() {
+        obj, err := readObject(fname)
+        if err != nil {
+            die(fmt.Errorf("%s: %s", fname, err))
+        }
+
+        l.Inputs = append(l.Inputs, &input{name: fname, obj: obj})
+    }
+
+    l.Layout(addrs)
+    l.Resolve()
+    l.Relocate()
+
+    if len(l.Errors) > 0 {
+        for _, err := range l.Errors {
+            fmt.Fprintln(os.Stderr, err)
+        }
+
+        os.Exit(1)
+    }
+
+    out, err := os.Create(*outName)
+    die(err)
+    defer out.Close()
+
+    w := bufio.NewWriter(out)
+    image := l.Image()
+
+    switch *format {
+    case "hex":
+        err = image.WriteIHex(w)
+    case "elf":
+        err = image.WriteELF(w, binaryimage.ELFK750)
+    default:
+        err = image.WriteRaw(w)
+    }
+
+    die(err)
+    die(w.Flush())
+
+    if *symName != "" {
+        f, err := os.Create(*symName)
+        die(err)
+        defer f.Close()
+
+        w := bufio.NewWriter(f)
+
+        for _, sym := range l.Symbols() {
+            fmt.Fprintf(w, "%08X %s\n", sym.Addr, sym.Name)
+        }
+
+        die(w.Flush())
+    }
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+    "bytes"
+    "github.com/kierdavis/go/k750/k750obj"
+    "testing"
+)
+
+// link runs every stage of the linker over objs, as main does.
+func link(addrs sectionAddrs, objs ...*k750obj.Object) (l *Linker) {
+    l = new(Linker)
+
+    for i, obj := range objs {
+        l.Inputs = append(l.Inputs, &input{name: string('a' + rune(i)), obj: obj})
+    }
+
+    l.Layout(addrs)
+    l.Resolve()
+    l.Relocate()
+    return l
+}
+
+func TestRelocAbs32(t *testing.T) {
+    // a refers to its own label "loop" and to "data", exported by b.
+    a := &k750obj.Object{
+        Sections: []*k750obj.Section{
+            {Name: "text", Data: make([]byte, 8), Relocs: []k750obj.Reloc{
+                {Offset: 0, Type: k750obj.RelocAbs32, Symbol: "loop"},
+                {Offset: 4, Type: k750obj.RelocAbs32, Symbol: "data", Addend: 6},
+            }},
+        },
+        Symbols: []k750obj.Symbol{{Name: "loop", Section: "text", Offset: 4}},
+        Imports: []string{"data"},
+    }
+    b := &k750obj.Object{
+        Sections: []*k750obj.Section{{Name: "text", Data: make([]byte, 4)}},
+        Symbols:  []k750obj.Symbol{{Name: "data", Section: "text", Offset: 2, Exported: true}},
+    }
+
+    l := link(sectionAddrs{"text": 0x1000}, a, b)
+    if len(l.Errors) > 0 {
+        t.Fatalf("unexpected errors: %v", l.Errors)
+    }
+
+    expected := []byte{0x00, 0x00, 0x10, 0x04, 0x00, 0x00, 0x10, 0x10, 0x00, 0x00, 0x00, 0x00}
+    if sec := l.getSection("text"); !bytes.Equal(sec.data, expected) {
+        t.Errorf("got % X, expected % X", sec.data, expected)
+    }
+}
+
+func TestRelocAbs16(t *testing.T) {
+    obj := &k750obj.Object{
+        Sections: []*k750obj.Section{
+            {Name: "text", Data: make([]byte, 2), Relocs: []k750obj.Reloc{
+                {Offset: 0, Type: k750obj.RelocAbs16, Symbol: "data", Addend: -1},
+            }},
+            {Name: "data", Data: make([]byte, 4)},
+        },
+        Symbols: []k750obj.Symbol{{Name: "data", Section: "data", Offset: 0}},
+    }
+
+    l := link(sectionAddrs{"data": 0x7F00}, obj)
+    if len(l.Errors) > 0 {
+        t.Fatalf("unexpected errors: %v", l.Errors)
+    }
+
+    expected := []byte{0x7E, 0xFF}
+    if sec := l.getSection("text"); !bytes.Equal(sec.data, expected) {
+        t.Errorf("got % X, expected % X", sec.data, expected)
+    }
+
+    l = link(sectionAddrs{"data": 0x8000}, obj)
+    if len(l.Errors) != 0 {
+        t.Errorf("0x7FFF should fit in a 16-bit displacement, got %v", l.Errors)
+    }
+
+    obj.Sections[0].Relocs[0].Addend = 0
+    l = link(sectionAddrs{"data": 0x8000}, obj)
+    if len(l.Errors) != 1 {
+        t.Errorf("expected an out-of-range error for 0x8000, got %v", l.Errors)
+    }
+}
+
+func TestOverlap(t *testing.T) {
+    obj := &k750obj.Object{
+        Sections: []*k750obj.Section{
+            {Name: "text", Data: make([]byte, 16)},
+            {Name: "data", Data: make([]byte, 16)},
+        },
+    }
+
+    l := link(sectionAddrs{"data": 0x10}, obj)
+    if len(l.Errors) != 0 {
+        t.Errorf("adjacent sections should not overlap, got %v", l.Errors)
+    }
+
+    l = link(sectionAddrs{"data": 0x0F}, obj)
+    if len(l.Errors) != 1 {
+        t.Errorf("expected one overlap error, got %v", l.Errors)
+    }
+
+    l = link(sectionAddrs{"text": 0x100, "data": 0x108}, obj)
+    if len(l.Errors) != 1 {
+        t.Errorf("expected one overlap error, got %v", l.Errors)
+    }
+}
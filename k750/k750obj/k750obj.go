@@ -0,0 +1,248 @@
+// Package k750obj implements the relocatable object format produced by k750asm and combined into
+// images by k750ld.
+//
+// An object holds a number of named sections, each with its data and the relocations that must be
+// applied to it once its final address is known, along with the symbols it defines and the ones
+// it imports from other objects.
+package k750obj
+
+import (
+    "bufio"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+)
+
+const Magic = "K75O"
+const Version = 1
+
+type RelocType uint8
+
+const (
+    RelocAbs32 RelocType = iota // 32-bit big-endian address
+    RelocAbs16                  // 16-bit big-endian signed displacement
+)
+
+// Size returns the number of bytes patched by a relocation of this type.
+func (t RelocType) Size() (size uint32) {
+    switch t {
+    case RelocAbs32:
+        return 4
+    case RelocAbs16:
+        return 2
+    }
+
+    return 0
+}
+
+// Reloc is a reference from a section to a symbol. When the object is linked, the field at Offset
+// is overwritten with the address of Symbol plus Addend.
+type Reloc struct {
+    Offset uint32
+    Type   RelocType
+    Symbol string
+    Addend int32
+}
+
+// Symbol is a label defined in one of the object's sections. Only exported symbols are visible to
+// other objects.
+type Symbol struct {
+    Name     string
+    Section  string
+    Offset   uint32
+    Exported bool
+}
+
+type Section struct {
+    Name   string
+    Data   []byte
+    Relocs []Reloc
+}
+
+type Object struct {
+    Sections []*Section
+    Symbols  []Symbol
+    Imports  []string
+}
+
+// Section returns the section with the given name, or nil if the object does not contain it.
+func (obj *Object) Section(name string) (section *Section) {
+    for _, section := range obj.Sections {
+        if section.Name == name {
+            return section
+        }
+    }
+
+    return nil
+}
+
+// Symbol returns the symbol with the given name.
+func (obj *Object) Symbol(name string) (symbol Symbol, ok bool) {
+    for _, symbol := range obj.Symbols {
+        if symbol.Name == name {
+            return symbol, true
+        }
+    }
+
+    return Symbol{}, false
+}
+
+type writer struct {
+    w   *bufio.Writer
+    err error
+}
+
+func (w *writer) write(v interface{}) {
+    if w.err == nil {
+        w.err = binary.Write(w.w, binary.BigEndian, v)
+    }
+}
+
+func (w *writer) writeString(s string) {
+    w.write(uint16(len(s)))
+    w.write([]byte(s))
+}
+
+// Write encodes the object and writes it to w.
+func (obj *Object) Write(w io.Writer) (err error) {
+    ow := &writer{w: bufio.NewWriter(w)}
+
+    ow.write([]byte(Magic))
+    ow.write(uint16(Version))
+
+    ow.write(uint32(len(obj.Sections)))
+    for _, section := range obj.Sections {
+        ow.writeString(section.Name)
+        ow.write(uint32(len(section.Data)))
+        ow.write(section.Data)
+
+        ow.write(uint32(len(section.Relocs)))
+        for _, reloc := range section.Relocs {
+            ow.write(reloc.Offset)
+            ow.write(uint8(reloc.Type))
+            ow.writeString(reloc.Symbol)
+            ow.write(reloc.Addend)
+        }
+    }
+
+    ow.write(uint32(len(obj.Symbols)))
+    for _, symbol := range obj.Symbols {
+        ow.writeString(symbol.Name)
+        ow.writeString(symbol.Section)
+        ow.write(symbol.Offset)
+        ow.write(symbol.Exported)
+    }
+
+    ow.write(uint32(len(obj.Imports)))
+    for _, name := range obj.Imports {
+        ow.writeString(name)
+    }
+
+    if ow.err != nil {
+        return ow.err
+    }
+
+    return ow.w.Flush()
+}
+
+type reader struct {
+    r   io.Reader
+    err error
+}
+
+func (r *reader) read(v interface{}) {
+    if r.err == nil {
+        r.err = binary.Read(r.r, binary.BigEndian, v)
+    }
+}
+
+func (r *reader) readCount() (n uint32) {
+    r.read(&n)
+    return n
+}
+
+func (r *reader) readBytes(n uint32) (data []byte) {
+    if r.err != nil {
+        return nil
+    }
+
+    data = make([]byte, n)
+    _, r.err = io.ReadFull(r.r, data)
+    return data
+}
+
+func (r *reader) readString() (s string) {
+    var n uint16
+    r.read(&n)
+    return string(r.readBytes(uint32(n)))
+}
+
+var ErrBadMagic = errors.New("Not a K750 object file")
+
+// Read decodes an object from r.
+func Read(r io.Reader) (obj *Object, err error) {
+    or := &reader{r: bufio.NewReader(r)}
+
+    magic := or.readBytes(uint32(len(Magic)))
+    if or.err == nil && string(magic) != Magic {
+        return nil, ErrBadMagic
+    }
+
+    var version uint16
+    or.read(&version)
+    if or.err == nil && version != Version {
+        return nil, fmt.Errorf("Unsupported K750 object file version: %d", version)
+    }
+
+    obj = new(Object)
+
+    numSections := or.readCount()
+    for i := uint32(0); i < numSections && or.err == nil; i++ {
+        section := &Section{Name: or.readString()}
+        section.Data = or.readBytes(or.readCount())
+
+        numRelocs := or.readCount()
+        for j := uint32(0); j < numRelocs && or.err == nil; j++ {
+            var reloc Reloc
+            var t uint8
+
+            or.read(&reloc.Offset)
+            or.read(&t)
+            reloc.Type = RelocType(t)
+            reloc.Symbol = or.readString()
+            or.read(&reloc.Addend)
+
+            section.Relocs = append(section.Relocs, reloc)
+        }
+
+        obj.Sections = append(obj.Sections, section)
+    }
+
+    numSymbols := or.readCount()
+    for i := uint32(0); i < numSymbols && or.err == nil; i++ {
+        var symbol Symbol
+
+        symbol.Name = or.readString()
+        symbol.Section = or.readString()
+        or.read(&symbol.Offset)
+        or.read(&symbol.Exported)
+
+        obj.Symbols = append(obj.Symbols, symbol)
+    }
+
+    numImports := or.readCount()
+    for i := uint32(0); i < numImports && or.err == nil; i++ {
+        obj.Imports = append(obj.Imports, or.readString())
+    }
+
+    if or.err != nil {
+        if or.err == io.EOF {
+            or.err = io.ErrUnexpectedEOF
+        }
+
+        return nil, or.err
+    }
+
+    return obj, nil
+}
@@ -4,8 +4,14 @@ import (
     "sync"
 )
 
+// The number of received bytes that can be buffered before more are dropped.
+const FifoSize = 16
+
+// GenericSerial is a serial port. Bytes received from Port are buffered until the CPU reads them,
+// raising an interrupt if one has been configured; bytes written by the CPU are sent to Output.
 type GenericSerial struct {
     Port              chan byte
+    Output            chan byte
     Fifo              []byte
     Lock              sync.Mutex
     StopChan          chan bool
@@ -13,10 +19,11 @@ type GenericSerial struct {
     PendingInterrupts chan uint8
 }
 
-func NewGenericSerial(port chan byte) (pp *GenericSerial) {
+func NewGenericSerial(port chan byte, output chan byte) (pp *GenericSerial) {
     pp = new(GenericSerial)
     pp.Port = port
-    pp.Fifo = make([]byte, 16)
+    pp.Output = output
+    pp.Fifo = make([]byte, 0, FifoSize)
     pp.StopChan = make(chan bool)
     pp.PendingInterrupts = make(chan uint8, 16)
     return pp
@@ -43,7 +50,7 @@ func (pp *GenericSerial) ReadRegister(reg uint8) (value uint32) {
         pp.Lock.Unlock()
 
     case 0x12:
-        value = 16
+        value = FifoSize
 
     case 0x20:
         pp.Lock.Lock()
@@ -60,7 +67,7 @@ func (pp *GenericSerial) ReadRegister(reg uint8) (value uint32) {
 func (pp *GenericSerial) WriteRegister(reg uint8, value uint32) {
     switch reg {
     case 0x10:
-        pp.Port <- uint8(value)
+        pp.Output <- uint8(value)
 
     case 0x20:
         pp.Lock.Lock()
@@ -69,6 +76,10 @@ func (pp *GenericSerial) WriteRegister(reg uint8, value uint32) {
     }
 }
 
+func (pp *GenericSerial) Interrupt(n uint8) {
+
+}
+
 func (pp *GenericSerial) GetPendingInterruptsChannel() (ch chan uint8) {
     return pp.PendingInterrupts
 }
@@ -76,9 +87,18 @@ func (pp *GenericSerial) GetPendingInterruptsChannel() (ch chan uint8) {
 func (pp *GenericSerial) RunService() {
     for {
         select {
-        case b := <-pp.Port:
+        case b, ok := <-pp.Port:
+            if !ok {
+                // Nothing more will be received.
+                pp.Port = nil
+                continue
+            }
+
             pp.Lock.Lock()
-            pp.Fifo = append(pp.Fifo, b)
+
+            if len(pp.Fifo) < FifoSize {
+                pp.Fifo = append(pp.Fifo, b)
+            }
 
             if pp.InterruptNumber != 0 {
                 pp.PendingInterrupts <- pp.InterruptNumber
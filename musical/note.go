@@ -78,3 +78,11 @@ func (note Note) Transpose(halfsteps int) (res Note) {
 func (note Note) Frequency() (freq float64) {
 	return 440.0 * math.Pow(2.0, float64(note-57)/12.0)
 }
+
+// FrequencyNote returns the note nearest to freq, and how far freq is from it in cents
+// (hundredths of a half step).
+func FrequencyNote(freq float64) (note Note, cents float64) {
+	steps := 12.0*math.Log2(freq/440.0) + 57.0
+	note = Note(math.Floor(steps + 0.5))
+	return note, (steps - float64(note)) * 100.0
+}
@@ -1,20 +1,23 @@
 package musical
 
 import (
+	"github.com/kierdavis/go/audio"
 	"math"
 )
 
+// A Stream carries a single channel of samples; only the sample rate of the audio.Format given to
+// the generators is used.
 type Stream chan float64
 
-func Silence(length float64, rate float64, output Stream) {
+func Silence(length float64, format audio.Format, output Stream) {
 	go func() {
 		output <- 0.0
 	}()
 }
 
-func generateWaveInput(freq float64, rate uint, phase float64, output Stream) {
-	factor := (freq * math.Pi * 2) / float64(rate)
-	phase *= float64(rate) / 2.0
+func generateWaveInput(freq float64, format audio.Format, phase float64, output Stream) {
+	factor := (freq * math.Pi * 2) / format.SampleRate
+	phase *= format.SampleRate / 2.0
 
 	go func() {
 		i := 0
@@ -26,9 +29,9 @@ func generateWaveInput(freq float64, rate uint, phase float64, output Stream) {
 	}()
 }
 
-func Sine(freq float64, rate uint, phase float64, output Stream) {
+func Sine(freq float64, format audio.Format, phase float64, output Stream) {
 	input := make(Stream)
-	generateWaveInput(freq, rate, phase, input)
+	generateWaveInput(freq, format, phase, input)
 
 	go func() {
 		for {
@@ -37,9 +40,9 @@ func Sine(freq float64, rate uint, phase float64, output Stream) {
 	}()
 }
 
-func Sawtooth(freq float64, rate uint, phase float64, output Stream) {
+func Sawtooth(freq float64, format audio.Format, phase float64, output Stream) {
 	input := make(Stream)
-	generateWaveInput(freq, rate, phase, input)
+	generateWaveInput(freq, format, phase, input)
 
 	go func() {
 		for {
@@ -49,9 +52,9 @@ func Sawtooth(freq float64, rate uint, phase float64, output Stream) {
 	}()
 }
 
-func Square(freq float64, rate uint, phase float64, output Stream) {
+func Square(freq float64, format audio.Format, phase float64, output Stream) {
 	input := make(Stream)
-	generateWaveInput(freq, rate, phase, input)
+	generateWaveInput(freq, format, phase, input)
 
 	go func() {
 		for {
@@ -0,0 +1,301 @@
+package sound
+
+import (
+	"github.com/kierdavis/go/audio"
+	"github.com/kierdavis/go/musical"
+	"math"
+	"math/cmplx"
+	"time"
+)
+
+// FFT returns the discrete Fourier transform of x, whose length must be a power of two.
+func FFT(x []complex128) (y []complex128) {
+	n := len(x)
+	y = make([]complex128, n)
+
+	// Put the input in bit-reversed order, then combine pairs of ever larger transforms.
+	bits := 0
+	for 1<<bits < n {
+		bits++
+	}
+
+	for i := range x {
+		r := 0
+		for b := 0; b < bits; b++ {
+			r |= (i >> b & 1) << (bits - 1 - b)
+		}
+
+		y[r] = x[i]
+	}
+
+	for size := 2; size <= n; size *= 2 {
+		w := cmplx.Rect(1.0, -2.0*math.Pi/float64(size))
+
+		for start := 0; start < n; start += size {
+			t := complex(1.0, 0.0)
+
+			for i := start; i < start+size/2; i++ {
+				a, b := y[i], y[i+size/2]*t
+				y[i], y[i+size/2] = a+b, a-b
+				t *= w
+			}
+		}
+	}
+
+	return y
+}
+
+// Spectrum is the magnitude of each frequency band in a block of samples. Band i is centred on
+// i * SampleRate / (2 * (len(Magnitudes) - 1)) Hz.
+type Spectrum struct {
+	Format     audio.Format
+	Magnitudes []float64
+}
+
+// Analyse returns the spectrum of samples, mixed down to mono. The samples are shaped with a Hann
+// window and padded with silence to a power of two.
+func Analyse(format audio.Format, samples []Sample) (s Spectrum) {
+	n := 1
+	for n < len(samples) {
+		n *= 2
+	}
+
+	x := make([]complex128, n)
+	for i, sample := range samples {
+		window := 0.5 - 0.5*math.Cos(2.0*math.Pi*float64(i)/float64(len(samples)))
+		x[i] = complex(sample.Mono()*window, 0.0)
+	}
+
+	y := FFT(x)
+
+	// Scale so that a full-scale sine wave filling the block has a magnitude of about 1.
+	scale := 4.0 / float64(max(len(samples), 1))
+
+	s = Spectrum{Format: format, Magnitudes: make([]float64, n/2+1)}
+	for i := range s.Magnitudes {
+		s.Magnitudes[i] = cmplx.Abs(y[i]) * scale
+	}
+
+	return s
+}
+
+// BandWidth returns the width of each band in Hz.
+func (s Spectrum) BandWidth() (width float64) {
+	return s.Format.SampleRate / float64(2*(len(s.Magnitudes)-1))
+}
+
+// Frequency returns the centre frequency of band i.
+func (s Spectrum) Frequency(i int) (freq float64) {
+	return float64(i) * s.BandWidth()
+}
+
+// Peak returns the frequency and magnitude of the strongest band, ignoring the DC band. The
+// frequency is refined by fitting a parabola to the band and its neighbours. It returns 0, 0 if
+// the spectrum has no bands other than DC.
+func (s Spectrum) Peak() (freq float64, magnitude float64) {
+	if len(s.Magnitudes) < 2 {
+		return 0.0, 0.0
+	}
+
+	best := 1
+	for i := 2; i < len(s.Magnitudes); i++ {
+		if s.Magnitudes[i] > s.Magnitudes[best] {
+			best = i
+		}
+	}
+
+	if best+1 >= len(s.Magnitudes) {
+		return s.Frequency(best), s.Magnitudes[best]
+	}
+
+	a, b, c := s.Magnitudes[best-1], s.Magnitudes[best], s.Magnitudes[best+1]
+	offset := 0.0
+
+	if d := a - 2.0*b + c; d != 0 {
+		offset = 0.5 * (a - c) / d
+	}
+
+	return (float64(best) + offset) * s.BandWidth(), b
+}
+
+// RMSLevel returns the root mean square level of samples, over both channels.
+func RMSLevel(samples []Sample) (level float64) {
+	if len(samples) == 0 {
+		return 0.0
+	}
+
+	for _, sample := range samples {
+		level += sample.Left*sample.Left + sample.Right*sample.Right
+	}
+
+	return math.Sqrt(level / float64(2*len(samples)))
+}
+
+// PeakLevel returns the largest absolute value in samples, over both channels.
+func PeakLevel(samples []Sample) (level float64) {
+	for _, sample := range samples {
+		level = math.Max(level, math.Max(math.Abs(sample.Left), math.Abs(sample.Right)))
+	}
+
+	return level
+}
+
+// Decibels converts a level to decibels relative to 1.
+func Decibels(level float64) (db float64) {
+	return 20.0 * math.Log10(level)
+}
+
+// The range of frequencies that DetectPitch looks for.
+var (
+	MinPitch = 40.0
+	MaxPitch = 4000.0
+)
+
+// pitchThreshold is how aperiodic a candidate period can be and still be accepted by DetectPitch.
+const pitchThreshold = 0.15
+
+// DetectPitch returns the fundamental frequency of samples, mixed down to mono, using the YIN
+// algorithm. ok is false if no pitch between MinPitch and MaxPitch could be found, such as in
+// silence or noise. The block should hold at least two periods of the lowest pitch of interest.
+func DetectPitch(format audio.Format, samples []Sample) (freq float64, ok bool) {
+	x := make([]float64, len(samples))
+	for i, sample := range samples {
+		x[i] = sample.Mono()
+	}
+
+	minLag := max(int(format.SampleRate/MaxPitch), 2)
+	maxLag := min(int(format.SampleRate/MinPitch), len(x)/2)
+	if maxLag <= minLag {
+		return 0.0, false
+	}
+
+	// d[lag] is the difference between the signal and itself shifted by lag, normalised by its
+	// running mean so that it dips well below 1 only at whole periods.
+	d := make([]float64, maxLag+2)
+	d[0] = 1.0
+	sum := 0.0
+	width := len(x) - maxLag - 1
+
+	for lag := 1; lag < len(d); lag++ {
+		for i := 0; i < width; i++ {
+			diff := x[i] - x[i+lag]
+			d[lag] += diff * diff
+		}
+
+		sum += d[lag]
+		if sum == 0 {
+			return 0.0, false
+		}
+
+		d[lag] *= float64(lag) / sum
+	}
+
+	for lag := minLag; lag <= maxLag; lag++ {
+		if d[lag] >= pitchThreshold {
+			continue
+		}
+
+		// Follow the dip to its lowest point and refine it with a parabola.
+		for lag+1 <= maxLag && d[lag+1] < d[lag] {
+			lag++
+		}
+
+		period := float64(lag)
+		a, b, c := d[lag-1], d[lag], d[lag+1]
+		if den := a - 2.0*b + c; den != 0 {
+			period += 0.5 * (a - c) / den
+		}
+
+		return format.SampleRate / period, true
+	}
+
+	return 0.0, false
+}
+
+// DetectNote returns the note nearest to the pitch of samples and how far off it is in cents, as
+// DetectPitch and musical.FrequencyNote.
+func DetectNote(format audio.Format, samples []Sample) (note musical.Note, cents float64, ok bool) {
+	freq, ok := DetectPitch(format, samples)
+	if !ok {
+		return 0, 0.0, false
+	}
+
+	note, cents = musical.FrequencyNote(freq)
+	return note, cents, true
+}
+
+// Measurement is the analysis of a block of samples passed through a tap.
+type Measurement struct {
+	Start    time.Duration // The time of the first sample in the block
+	RMS      float64
+	Peak     float64
+	Spectrum Spectrum
+	Pitch    float64 // 0 if no pitch was detected
+}
+
+// Note returns the note nearest to the measured pitch. ok is false if no pitch was detected.
+func (m Measurement) Note() (note musical.Note, ok bool) {
+	if m.Pitch == 0 {
+		return 0, false
+	}
+
+	note, _ = musical.FrequencyNote(m.Pitch)
+	return note, true
+}
+
+func measure(format audio.Format, start int, samples []Sample) (m Measurement) {
+	m = Measurement{
+		Start:    format.Duration(start),
+		RMS:      RMSLevel(samples),
+		Peak:     PeakLevel(samples),
+		Spectrum: Analyse(format, samples),
+	}
+
+	m.Pitch, _ = DetectPitch(format, samples)
+	return m
+}
+
+// TapProcessor returns a Processor that passes its input through unchanged, calling f with a
+// Measurement of each window worth of it. Whatever is left over when the input ends is too short
+// to analyse like the rest, so it is not measured.
+func TapProcessor(window time.Duration, f func(m Measurement), in Processor) (p Processor) {
+	format := in.Format()
+	block := make([]Sample, 0, max(format.NumSamples(window), 1))
+	start := 0
+
+	return ProcessorFunc(format, func(buf []Sample) (n int) {
+		n = in.Process(buf)
+
+		for _, sample := range buf[:n] {
+			block = append(block, sample)
+
+			if len(block) == cap(block) {
+				f(measure(format, start, block))
+				start += len(block)
+				block = block[:0]
+			}
+		}
+
+		return n
+	})
+}
+
+// Tap passes the samples from in on to out unchanged, sending a Measurement of each window worth
+// of them to measurements. Both out and measurements are closed at the end, and both must be
+// received from to keep the audio flowing.
+func Tap(format audio.Format, window time.Duration, in chan Sample, out chan Sample, measurements chan Measurement) {
+	defer close(measurements)
+
+	send := func(m Measurement) {
+		measurements <- m
+	}
+
+	ToChan(TapProcessor(window, send, FromChan(format, in)), out)
+}
+
+func GoTap(format audio.Format, window time.Duration, in chan Sample) (out chan Sample, measurements chan Measurement) {
+	out = make(chan Sample, ChannelBuffer)
+	measurements = make(chan Measurement, ChannelBuffer)
+	go Tap(format, window, in, out, measurements)
+	return out, measurements
+}
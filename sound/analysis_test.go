@@ -0,0 +1,53 @@
+package sound
+
+import (
+	"github.com/kierdavis/go/audio"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPeak(t *testing.T) {
+	samples := Render(SineProcessor(audio.CD, 1000, time.Second/10))
+
+	freq, magnitude := Analyse(audio.CD, samples).Peak()
+	if math.Abs(freq-1000) > 5.0 || math.Abs(magnitude-1.0) > 0.2 {
+		t.Errorf("Peak of a 1000 Hz sine is %.1f Hz at %.2f", freq, magnitude)
+	}
+}
+
+func TestPeakShortBlocks(t *testing.T) {
+	for n := 0; n <= 2; n++ {
+		samples := make([]Sample, n)
+		for i := range samples {
+			samples[i] = Sample{0.5, 0.5}
+		}
+
+		// Should not panic.
+		Analyse(audio.CD, samples).Peak()
+	}
+
+	if freq, magnitude := (Spectrum{Format: audio.CD}).Peak(); freq != 0 || magnitude != 0 {
+		t.Errorf("Peak of an empty spectrum is %f, %f", freq, magnitude)
+	}
+}
+
+func TestTapSkipsShortBlocks(t *testing.T) {
+	window := 10 * time.Millisecond
+	length := 105 * time.Millisecond
+	measurements := 0
+
+	check := func(m Measurement) {
+		measurements++
+	}
+
+	n := len(Render(TapProcessor(window, check, SineProcessor(audio.CD, 440, length))))
+
+	if n != audio.CD.NumSamples(length) {
+		t.Errorf("Tap passed on %d samples, expected %d", n, audio.CD.NumSamples(length))
+	}
+
+	if measurements != 10 {
+		t.Errorf("Tap made %d measurements, expected 10", measurements)
+	}
+}
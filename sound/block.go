@@ -0,0 +1,312 @@
+package sound
+
+import (
+	"github.com/kierdavis/go/audio"
+	"math"
+	"time"
+)
+
+// BlockSize is the number of samples that adapters and Render ask processors for at a time.
+var BlockSize = 1024
+
+// Processor generates or transforms audio a block at a time. Process fills buf with the next
+// samples of its output, pulling as much input as it needs from the processors it reads from, and
+// returns the number of samples written. A result less than len(buf) means that the output has
+// ended, and every call after that returns 0.
+//
+// Nothing happens until the processor at the end of a chain is asked for output, so a chain does
+// no more work than its consumer needs and uses no goroutines.
+//
+// Format returns the format of the processor's output. Generators are given one, and effects
+// output the format of their input.
+type Processor interface {
+	Process(buf []Sample) (n int)
+	Format() (format audio.Format)
+}
+
+// ProcessorFunc returns a Processor whose output, in the given format, is produced by f.
+func ProcessorFunc(format audio.Format, f func(buf []Sample) (n int)) (p Processor) {
+	return &funcProcessor{format, f}
+}
+
+type funcProcessor struct {
+	format  audio.Format
+	process func(buf []Sample) (n int)
+}
+
+func (p *funcProcessor) Process(buf []Sample) (n int) {
+	return p.process(buf)
+}
+
+func (p *funcProcessor) Format() (format audio.Format) {
+	return p.format
+}
+
+// generator is a Processor that calls next for each of a fixed number of samples.
+type generator struct {
+	format    audio.Format
+	remaining int
+	next      func() Sample
+}
+
+func (g *generator) Process(buf []Sample) (n int) {
+	if len(buf) > g.remaining {
+		buf = buf[:g.remaining]
+	}
+
+	for i := range buf {
+		buf[i] = g.next()
+	}
+
+	g.remaining -= len(buf)
+	return len(buf)
+}
+
+func (g *generator) Format() (format audio.Format) {
+	return g.format
+}
+
+// Generator returns a Processor that produces length worth of samples in the given format by
+// calling next.
+func Generator(format audio.Format, length time.Duration, next func() Sample) (p Processor) {
+	return &generator{format, format.NumSamples(length), next}
+}
+
+func SineProcessor(format audio.Format, freq float64, length time.Duration) (p Processor) {
+	step := (math.Pi * freq * 2.0) / format.SampleRate
+	x := 0.0
+
+	return Generator(format, length, func() Sample {
+		y := math.Sin(x)
+
+		x += step
+		if x >= math.Pi {
+			x -= math.Pi * 2
+		}
+
+		return Sample{y, y}
+	})
+}
+
+func SawProcessor(format audio.Format, freq float64, length time.Duration) (p Processor) {
+	step := (2.0 * freq) / format.SampleRate
+	x := 0.0
+
+	return Generator(format, length, func() Sample {
+		y := x
+
+		x += step
+		if x >= 1.0 {
+			x -= 2.0
+		}
+
+		return Sample{y, y}
+	})
+}
+
+func TriangleProcessor(format audio.Format, freq float64, length time.Duration) (p Processor) {
+	step := (4.0 * freq) / format.SampleRate
+	x := 0.0
+
+	return Generator(format, length, func() Sample {
+		y := x
+
+		x += step
+		if x >= 1.0 || x <= -1.0 {
+			step = -step
+			x += step * 2
+		}
+
+		return Sample{y, y}
+	})
+}
+
+func SquareProcessor(format audio.Format, freq float64, length time.Duration) (p Processor) {
+	return ClipProcessor(1e-6, TriangleProcessor(format, freq, length))
+}
+
+func SilenceProcessor(format audio.Format, length time.Duration) (p Processor) {
+	return Generator(format, length, func() Sample { return Sample{} })
+}
+
+func ClipProcessor(threshold float64, in Processor) (p Processor) {
+	return ProcessorFunc(in.Format(), func(buf []Sample) (n int) {
+		n = in.Process(buf)
+
+		for i, sample := range buf[:n] {
+			sample.Left = math.Max(-threshold, math.Min(threshold, sample.Left))
+			sample.Right = math.Max(-threshold, math.Min(threshold, sample.Right))
+			buf[i] = sample.Div(threshold)
+		}
+
+		return n
+	})
+}
+
+// mixProcessor implements MixProcessor.
+type mixProcessor struct {
+	inputs  []Processor
+	scratch []Sample
+	counts  []int
+}
+
+// MixProcessor returns a Processor that mixes its inputs as Mix does: at each point the inputs
+// that haven't ended are averaged, and the result is clipped to 1. The inputs should all have the
+// same format, which is that of the output.
+func MixProcessor(inputs ...Processor) (p Processor) {
+	return ClipProcessor(1.0, &mixProcessor{inputs: inputs})
+}
+
+func (m *mixProcessor) Format() (format audio.Format) {
+	return formatOf(m.inputs)
+}
+
+// formatOf returns the format of the first of inputs, or audio.CD if there are none.
+func formatOf(inputs []Processor) (format audio.Format) {
+	if len(inputs) == 0 {
+		return audio.CD
+	}
+
+	return inputs[0].Format()
+}
+
+func (m *mixProcessor) Process(buf []Sample) (n int) {
+	if len(m.scratch) < len(buf) {
+		m.scratch = make([]Sample, len(buf))
+		m.counts = make([]int, len(buf))
+	}
+
+	scratch, counts := m.scratch[:len(buf)], m.counts[:len(buf)]
+
+	for i := range buf {
+		buf[i] = Sample{}
+		counts[i] = 0
+	}
+
+	open := m.inputs[:0]
+
+	for _, input := range m.inputs {
+		k := input.Process(scratch)
+
+		for i, sample := range scratch[:k] {
+			buf[i] = buf[i].Add(sample)
+			counts[i]++
+		}
+
+		if k > n {
+			n = k
+		}
+
+		if k == len(buf) {
+			open = append(open, input)
+		}
+	}
+
+	m.inputs = open
+
+	for i := range buf[:n] {
+		buf[i] = buf[i].Div(float64(counts[i]))
+	}
+
+	return n
+}
+
+// ConcatenateProcessor returns a Processor that plays each of its inputs in turn. The inputs
+// should all have the same format, which is that of the output.
+func ConcatenateProcessor(inputs ...Processor) (p Processor) {
+	return ProcessorFunc(formatOf(inputs), func(buf []Sample) (n int) {
+		for n < len(buf) && len(inputs) > 0 {
+			k := inputs[0].Process(buf[n:])
+			n += k
+
+			if n < len(buf) {
+				inputs = inputs[1:]
+			}
+		}
+
+		return n
+	})
+}
+
+func DelayProcessor(d time.Duration, in Processor) (p Processor) {
+	return ConcatenateProcessor(SilenceProcessor(in.Format(), d), in)
+}
+
+// CopyForProcessor returns a Processor that plays at most length worth of its input.
+func CopyForProcessor(length time.Duration, in Processor) (p Processor) {
+	remaining := in.Format().NumSamples(length)
+
+	return ProcessorFunc(in.Format(), func(buf []Sample) (n int) {
+		if len(buf) > remaining {
+			buf = buf[:remaining]
+		}
+
+		n = in.Process(buf)
+		remaining -= n
+
+		if n < len(buf) {
+			remaining = 0
+		}
+
+		return n
+	})
+}
+
+// FromChan returns a Processor that reads its output, which is in the given format, from a
+// channel, so that channel-based code can feed a processor chain. Process blocks until buf is full
+// or the channel is closed.
+func FromChan(format audio.Format, in chan Sample) (p Processor) {
+	return ProcessorFunc(format, func(buf []Sample) (n int) {
+		for n < len(buf) {
+			sample, ok := <-in
+			if !ok {
+				return n
+			}
+
+			buf[n] = sample
+			n++
+		}
+
+		return n
+	})
+}
+
+// ToChan pulls blocks from p and sends their samples to out, closing it when p's output ends, so
+// that a processor chain can feed channel-based code.
+func ToChan(p Processor, out chan Sample) {
+	defer close(out)
+
+	buf := make([]Sample, BlockSize)
+
+	for {
+		n := p.Process(buf)
+
+		for _, sample := range buf[:n] {
+			out <- sample
+		}
+
+		if n < len(buf) {
+			return
+		}
+	}
+}
+
+func GoToChan(p Processor) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go ToChan(p, out)
+	return out
+}
+
+// Render pulls all of p's output and returns it.
+func Render(p Processor) (samples []Sample) {
+	buf := make([]Sample, BlockSize)
+
+	for {
+		n := p.Process(buf)
+		samples = append(samples, buf[:n]...)
+
+		if n < len(buf) {
+			return samples
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package sound
+
+import (
+	"github.com/kierdavis/go/audio"
+	"testing"
+	"time"
+)
+
+const benchLength = time.Second
+
+// The benchmarks render the same signal, two sines mixed together and delayed, once through
+// channels a sample at a time and once through processors a block at a time.
+
+func BenchmarkChanMixDelay(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		out := GoDelay(audio.CD, 10*time.Millisecond, GoMix(GoSine(audio.CD, 440, benchLength), GoSine(audio.CD, 660, benchLength)))
+
+		for _ = range out {
+		}
+	}
+}
+
+func BenchmarkProcessorMixDelay(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		p := MixProcessor(SineProcessor(audio.CD, 440, benchLength), SineProcessor(audio.CD, 660, benchLength))
+		Render(DelayProcessor(10*time.Millisecond, p))
+	}
+}
+
+func BenchmarkChanFlange(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		out := GoFlange(audio.CD, 0.5, time.Millisecond, 5*time.Millisecond, 10*time.Millisecond, GoSine(audio.CD, 440, benchLength))
+
+		for _ = range out {
+		}
+	}
+}
+
+func BenchmarkProcessorFlange(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		p := SineProcessor(audio.CD, 440, benchLength)
+		Render(FlangeProcessor(0.5, time.Millisecond, 5*time.Millisecond, 10*time.Millisecond, p))
+	}
+}
+
+// The two paths should produce the same samples.
+func TestProcessorMatchesChan(t *testing.T) {
+	var fromChan []Sample
+	for sample := range GoDelay(audio.CD, 10*time.Millisecond, GoMix(GoSine(audio.CD, 440, benchLength), GoSine(audio.CD, 660, benchLength))) {
+		fromChan = append(fromChan, sample)
+	}
+
+	p := MixProcessor(SineProcessor(audio.CD, 440, benchLength), SineProcessor(audio.CD, 660, benchLength))
+	fromProcessor := Render(DelayProcessor(10*time.Millisecond, p))
+
+	if len(fromChan) != len(fromProcessor) {
+		t.Fatalf("Channel path produced %d samples, processor path %d", len(fromChan), len(fromProcessor))
+	}
+
+	for i := range fromChan {
+		if fromChan[i] != fromProcessor[i] {
+			t.Fatalf("Sample %d differs: %v from channels, %v from processors", i, fromChan[i], fromProcessor[i])
+		}
+	}
+}
+
+func TestFlangeEnds(t *testing.T) {
+	n := 0
+	for _ = range GoFlange(audio.CD, 0.5, time.Millisecond, 5*time.Millisecond, 10*time.Millisecond, GoSine(audio.CD, 440, benchLength)) {
+		n++
+	}
+
+	expected := audio.CD.NumSamples(benchLength) + audio.CD.NumSamples(5*time.Millisecond)
+	if n != expected {
+		t.Errorf("Flange produced %d samples, expected %d", n, expected)
+	}
+}
@@ -0,0 +1,92 @@
+package sound
+
+import (
+	"github.com/kierdavis/go/audio"
+	"math"
+	"time"
+)
+
+// Compressor reduces the level of its input when it rises above Threshold, so that for every Ratio
+// decibels the input is above the threshold, the output is only 1 decibel above it. Attack and
+// release set how quickly it reacts to the level rising and falling. Makeup is a gain, in
+// decibels, applied afterwards to make up for the reduction. Threshold and levels are measured
+// in decibels relative to 1, the loudest sample that can be encoded.
+type Compressor struct {
+	Threshold float64
+	Ratio     float64
+	Makeup    float64
+
+	attack  float64
+	release float64
+	level   float64
+}
+
+func NewCompressor(format audio.Format, threshold float64, ratio float64, attack time.Duration, release time.Duration, makeup float64) (c *Compressor) {
+	return &Compressor{
+		Threshold: threshold,
+		Ratio:     ratio,
+		Makeup:    makeup,
+		attack:    smoothing(format, attack),
+		release:   smoothing(format, release),
+	}
+}
+
+// smoothing returns the coefficient of a one-pole smoother that settles in about d.
+func smoothing(format audio.Format, d time.Duration) (coeff float64) {
+	if d <= 0 {
+		return 0.0
+	}
+
+	return math.Exp(-1.0 / (d.Seconds() * format.SampleRate))
+}
+
+// Gain returns the gain, in decibels, that the compressor is applying.
+func (c *Compressor) Gain() (gain float64) {
+	gain = c.Makeup
+
+	over := 20.0*math.Log10(c.level) - c.Threshold
+	if over > 0 {
+		gain -= over * (1.0 - 1.0/c.Ratio)
+	}
+
+	return gain
+}
+
+func (c *Compressor) Filter(in Sample) (out Sample) {
+	level := math.Max(math.Abs(in.Left), math.Abs(in.Right))
+
+	coeff := c.release
+	if level > c.level {
+		coeff = c.attack
+	}
+
+	c.level = level + (c.level-level)*coeff
+	return in.Mul(math.Pow(10.0, c.Gain()/20.0))
+}
+
+func Compress(format audio.Format, threshold float64, ratio float64, attack time.Duration, release time.Duration, makeup float64, in chan Sample, out chan Sample) {
+	ApplyFilter(NewCompressor(format, threshold, ratio, attack, release, makeup), in, out)
+}
+
+func GoCompress(format audio.Format, threshold float64, ratio float64, attack time.Duration, release time.Duration, makeup float64, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go Compress(format, threshold, ratio, attack, release, makeup, in, out)
+	return out
+}
+
+// NewLimiter returns a compressor that keeps its output at or below ceiling decibels, reacting
+// instantly to peaks and recovering over release. Unlike Clip, it turns the whole sound down
+// rather than squaring off the peaks, so it doesn't add distortion.
+func NewLimiter(format audio.Format, ceiling float64, release time.Duration) (c *Compressor) {
+	return NewCompressor(format, ceiling, math.Inf(1), 0, release, 0.0)
+}
+
+func Limit(format audio.Format, ceiling float64, release time.Duration, in chan Sample, out chan Sample) {
+	ApplyFilter(NewLimiter(format, ceiling, release), in, out)
+}
+
+func GoLimit(format audio.Format, ceiling float64, release time.Duration, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go Limit(format, ceiling, release, in, out)
+	return out
+}
@@ -0,0 +1,46 @@
+package sound
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLimiterCeiling(t *testing.T) {
+	const ceiling = -6.0
+	limit := math.Pow(10.0, ceiling/20.0)
+
+	l := NewLimiter(testFormat, ceiling, 50*time.Millisecond)
+
+	// A loud burst, then a quieter one that should be turned down less, then silence.
+	for i := 0; i < 600; i++ {
+		amplitude := 1.0
+		switch {
+		case i >= 200 && i < 400:
+			amplitude = 0.7
+		case i >= 400:
+			amplitude = 0.0
+		}
+
+		x := amplitude * math.Sin(float64(i)*0.3)
+		out := l.Filter(Sample{x, -x})
+
+		if math.Abs(out.Left) > limit+1e-9 || math.Abs(out.Right) > limit+1e-9 {
+			t.Fatalf("sample %d: output %v is above the ceiling of %.6f", i, out, limit)
+		}
+	}
+}
+
+func TestLimiterQuiet(t *testing.T) {
+	// Input that stays below the ceiling is passed through unchanged.
+	l := NewLimiter(testFormat, -6.0, 50*time.Millisecond)
+
+	for i := 0; i < 100; i++ {
+		x := 0.25 * math.Sin(float64(i)*0.3)
+		out := l.Filter(Sample{x, x})
+
+		if math.Abs(out.Left-x) > 1e-9 {
+			t.Fatalf("sample %d: output %.6f, expected %.6f", i, out.Left, x)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package sound
+
+import (
+	"github.com/kierdavis/go/audio"
+	"time"
+)
+
+// EnvelopeSegment is a linear ramp from the level the envelope is at to Level, taking Time.
+type EnvelopeSegment struct {
+	Level float64
+	Time  time.Duration
+}
+
+// Envelope describes how the level of a note changes over time. When the note starts, the
+// envelope starts at 0 and plays Segments in turn. If Sustain is true, it then holds the level it
+// reached until the note is released; if not, it ends there. When the note is released, which may
+// happen part way through Segments, it plays Release from whatever level it is at, and then ends.
+type Envelope struct {
+	Segments []EnvelopeSegment
+	Sustain  bool
+	Release  []EnvelopeSegment
+}
+
+// ADSR returns an envelope that rises to 1 over attack, falls to sustain over decay, holds there
+// until the note is released and then falls to 0 over release.
+func ADSR(attack, decay time.Duration, sustain float64, release time.Duration) (env Envelope) {
+	return Envelope{
+		Segments: []EnvelopeSegment{{1.0, attack}, {sustain, decay}},
+		Sustain:  true,
+		Release:  []EnvelopeSegment{{0.0, release}},
+	}
+}
+
+// ReleaseTime returns the total length of the envelope's release segments.
+func (env Envelope) ReleaseTime() (d time.Duration) {
+	for _, seg := range env.Release {
+		d += seg.Time
+	}
+
+	return d
+}
+
+// envelopeGen produces the level of an envelope one sample at a time.
+type envelopeGen struct {
+	env      Envelope
+	format   audio.Format
+	segments []EnvelopeSegment // The segments after the current one
+	level    float64
+	step     float64
+	steps    int // The number of samples left in the current segment
+	released bool
+	done     bool
+}
+
+func newEnvelopeGen(format audio.Format, env Envelope) (g *envelopeGen) {
+	g = &envelopeGen{env: env, format: format, segments: env.Segments}
+	g.nextSegment()
+	return g
+}
+
+// nextSegment starts the next segment that takes at least one sample, jumping straight to the
+// level of any that don't. If there are none left, the envelope holds or ends.
+func (g *envelopeGen) nextSegment() {
+	for len(g.segments) > 0 {
+		seg := g.segments[0]
+		g.segments = g.segments[1:]
+
+		n := g.format.NumSamples(seg.Time)
+		if n == 0 {
+			g.level = seg.Level
+			continue
+		}
+
+		g.step = (seg.Level - g.level) / float64(n)
+		g.steps = n
+		return
+	}
+
+	g.steps = 0
+	g.done = g.released || !g.env.Sustain
+}
+
+// next returns the level for the next sample.
+func (g *envelopeGen) next() (level float64) {
+	level = g.level
+
+	if g.steps > 0 {
+		g.level += g.step
+		g.steps--
+
+		if g.steps == 0 {
+			g.nextSegment()
+		}
+	}
+
+	return level
+}
+
+// release starts the release segments, if they haven't been started already.
+func (g *envelopeGen) release() {
+	if g.released {
+		return
+	}
+
+	g.released = true
+	g.segments = g.env.Release
+	g.nextSegment()
+}
+
+// EnvelopeProcessor returns a Processor that shapes its input with env, releasing the note after
+// gate. Its output ends when the envelope or the input does.
+func EnvelopeProcessor(env Envelope, gate time.Duration, in Processor) (p Processor) {
+	g := newEnvelopeGen(in.Format(), env)
+	held := in.Format().NumSamples(gate)
+
+	return ProcessorFunc(in.Format(), func(buf []Sample) (n int) {
+		n = in.Process(buf)
+
+		for i := range buf[:n] {
+			if held == 0 {
+				g.release()
+			}
+
+			held--
+
+			if g.done {
+				return i
+			}
+
+			buf[i] = buf[i].Mul(g.next())
+		}
+
+		return n
+	})
+}
+
+// ApplyEnvelope shapes the samples from in with env, releasing the note after gate.
+func ApplyEnvelope(format audio.Format, env Envelope, gate time.Duration, in chan Sample, out chan Sample) {
+	ToChan(EnvelopeProcessor(env, gate, FromChan(format, in)), out)
+}
+
+func GoApplyEnvelope(format audio.Format, env Envelope, gate time.Duration, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go ApplyEnvelope(format, env, gate, in, out)
+	return out
+}
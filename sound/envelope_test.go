@@ -0,0 +1,92 @@
+package sound
+
+import (
+	"github.com/kierdavis/go/audio"
+	"math"
+	"testing"
+	"time"
+)
+
+// testFormat has one sample per millisecond, so that envelope times map directly to samples.
+var testFormat = audio.Format{SampleRate: 1000, Channels: 2}
+
+// constantProcessor returns a Processor whose output is level forever.
+func constantProcessor(format audio.Format, level float64) (p Processor) {
+	return ProcessorFunc(format, func(buf []Sample) (n int) {
+		for i := range buf {
+			buf[i] = Sample{level, level}
+		}
+
+		return len(buf)
+	})
+}
+
+// checkLevels checks the left channel of samples against the expected level at each index.
+func checkLevels(t *testing.T, samples []Sample, expected map[int]float64) {
+	t.Helper()
+
+	for i, level := range expected {
+		if i >= len(samples) {
+			t.Errorf("sample %d: output ended after %d samples, expected %.3f", i, len(samples), level)
+			continue
+		}
+
+		if got := samples[i].Left; math.Abs(got-level) > 1e-9 {
+			t.Errorf("sample %d: got %.6f, expected %.3f", i, got, level)
+		}
+	}
+}
+
+func TestEnvelopeADSR(t *testing.T) {
+	env := ADSR(10*time.Millisecond, 10*time.Millisecond, 0.5, 20*time.Millisecond)
+	samples := Render(EnvelopeProcessor(env, 30*time.Millisecond, constantProcessor(testFormat, 1.0)))
+
+	checkLevels(t, samples, map[int]float64{
+		0:  0.0,  // Start of attack
+		5:  0.5,  // Half way through attack
+		10: 1.0,  // End of attack, start of decay
+		15: 0.75, // Half way through decay
+		20: 0.5,  // End of decay, start of sustain
+		29: 0.5,  // Last sample before release
+		30: 0.5,  // Start of release
+		40: 0.25, // Half way through release
+		49: 0.025,
+	})
+
+	if len(samples) != 50 {
+		t.Errorf("output has %d samples, expected 50", len(samples))
+	}
+}
+
+func TestEnvelopeEarlyRelease(t *testing.T) {
+	// Releasing during the attack falls from the level reached so far, skipping decay and sustain.
+	env := ADSR(10*time.Millisecond, 10*time.Millisecond, 0.5, 20*time.Millisecond)
+	samples := Render(EnvelopeProcessor(env, 4*time.Millisecond, constantProcessor(testFormat, 1.0)))
+
+	checkLevels(t, samples, map[int]float64{
+		3:  0.3,
+		4:  0.4,
+		14: 0.2,
+		23: 0.02,
+	})
+
+	if len(samples) != 24 {
+		t.Errorf("output has %d samples, expected 24", len(samples))
+	}
+}
+
+func TestEnvelopeNoSustain(t *testing.T) {
+	// Without sustain, the envelope ends after its segments even though the gate is longer.
+	env := Envelope{Segments: []EnvelopeSegment{{1.0, 10 * time.Millisecond}, {0.0, 10 * time.Millisecond}}}
+	samples := Render(EnvelopeProcessor(env, time.Second, constantProcessor(testFormat, 1.0)))
+
+	checkLevels(t, samples, map[int]float64{
+		10: 1.0,
+		15: 0.5,
+		19: 0.1,
+	})
+
+	if len(samples) != 20 {
+		t.Errorf("output has %d samples, expected 20", len(samples))
+	}
+}
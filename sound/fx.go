@@ -1,6 +1,8 @@
 package sound
 
 import (
+	"github.com/kierdavis/go/audio"
+	"math"
 	"time"
 )
 
@@ -33,51 +35,535 @@ func GoClip(threshold float64, in chan Sample) (out chan Sample) {
 	return out
 }
 
-func Flange(freq float64, minPeriod, maxPeriod, windowSize time.Duration, in chan Sample, out chan Sample) {
-	defer close(out)
+// flangeProcessor implements FlangeProcessor.
+type flangeProcessor struct {
+	in       Processor
+	format   audio.Format
+	rate     float64
+	minDelay float64 // In samples
+	maxDelay float64 // In samples
+	window   int     // In samples
+	count    int     // The number of samples since the delay last changed
+	delay    float64 // In samples
+	phase    float64
+	line     []Sample // The most recent input samples, as a ring buffer
+	pos      int      // The index in line of the most recent input sample
+}
+
+// FlangeProcessor returns a Processor that mixes its input with a copy whose delay is swept
+// between minPeriod and maxPeriod by a sine wave running at freq Hz. The delay only changes at the
+// start of each window, so it steps rather than gliding between them. The output is followed by
+// enough silence for the delayed copy to finish.
+func FlangeProcessor(freq float64, minPeriod, maxPeriod, windowSize time.Duration, in Processor) (p Processor) {
+	format := in.Format()
+
+	return &flangeProcessor{
+		in:       ConcatenateProcessor(in, SilenceProcessor(format, maxPeriod)),
+		format:   format,
+		rate:     freq,
+		minDelay: minPeriod.Seconds() * format.SampleRate,
+		maxDelay: maxPeriod.Seconds() * format.SampleRate,
+		window:   max(format.NumSamples(windowSize), 1),
+		line:     make([]Sample, format.NumSamples(maxPeriod)+2),
+	}
+}
 
-	windowSizeSamples := int(windowSize / SampleTime)
-	ctl := sineInput(freq, SampleRate/float64(windowSizeSamples))
-	periodRange := maxPeriod - minPeriod
+// delayed returns the input from d samples ago, interpolating between samples.
+func (f *flangeProcessor) delayed(d float64) (sample Sample) {
+	i := int(d)
+	frac := d - float64(i)
 
-	var lastStream2 chan Sample
+	a := f.line[(f.pos-i+len(f.line))%len(f.line)]
+	b := f.line[(f.pos-i-1+len(f.line))%len(f.line)]
+	return a.Mul(1.0 - frac).Add(b.Mul(frac))
+}
+
+func (f *flangeProcessor) Process(buf []Sample) (n int) {
+	n = f.in.Process(buf)
+
+	for i, sample := range buf[:n] {
+		if f.count == 0 {
+			f.delay = f.minDelay + (f.maxDelay-f.minDelay)*(math.Sin(2.0*math.Pi*f.phase)+1.0)/2.0
 
-	for {
-		// Read a window
-		window := make([]Sample, 0, windowSizeSamples)
-		for i := 0; i < windowSizeSamples; i++ {
-			window = append(window, <-in)
+			f.phase += f.rate * float64(f.window) / f.format.SampleRate
+			f.phase -= math.Floor(f.phase)
 		}
 
-		// Create two streams that play the window
-		stream1 := make(chan Sample, ChannelBuffer)
-		stream2 := make(chan Sample, ChannelBuffer)
+		f.count = (f.count + 1) % f.window
+
+		f.pos = (f.pos + 1) % len(f.line)
+		f.line[f.pos] = sample
+
+		buf[i] = sample.Add(f.delayed(f.delay)).Div(2.0)
+	}
+
+	return n
+}
+
+func (f *flangeProcessor) Format() (format audio.Format) {
+	return f.format
+}
+
+// Flange mixes in with a copy of itself delayed by a period that sweeps between minPeriod and
+// maxPeriod, as FlangeProcessor does, and closes out once in is closed and the delayed copy has
+// finished.
+func Flange(format audio.Format, freq float64, minPeriod, maxPeriod, windowSize time.Duration, in chan Sample, out chan Sample) {
+	ToChan(FlangeProcessor(freq, minPeriod, maxPeriod, windowSize, FromChan(format, in)), out)
+}
+
+func GoFlange(format audio.Format, freq float64, minPeriod, maxPeriod, windowSize time.Duration, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go Flange(format, freq, minPeriod, maxPeriod, windowSize, in, out)
+	return out
+}
+
+// Filter processes a stream of samples one at a time, keeping whatever state it needs between
+// them. A Filter should only be used for one stream.
+type Filter interface {
+	Filter(in Sample) (out Sample)
+}
+
+// OnePole is a gentle (6 dB per octave) low-pass filter.
+type OnePole struct {
+	a    float64
+	last Sample
+}
 
-		go func() {
-			defer close(stream1)
-			defer close(stream2)
+// NewOnePole returns a OnePole filter for samples in the given format, with the given cutoff
+// frequency.
+func NewOnePole(format audio.Format, cutoff float64) (f *OnePole) {
+	f = new(OnePole)
+	f.SetCutoff(format, cutoff)
+	return f
+}
 
-			for _, sample := range window {
-				stream1 <- sample
-				stream2 <- sample
-			}
-		}()
+// SetCutoff changes the cutoff frequency of the filter.
+func (f *OnePole) SetCutoff(format audio.Format, cutoff float64) {
+	f.a = 1.0 - math.Exp(-2.0*math.Pi*cutoff/format.SampleRate)
+}
 
-		// Delay the second stream by a set period
-		period := (time.Duration(<-ctl) * periodRange) + minPeriod
-		stream2 = GoDelay(period, stream2)
+func (f *OnePole) Filter(in Sample) (out Sample) {
+	f.last = f.last.Add(in.Sub(f.last).Mul(f.a))
+	return f.last
+}
 
-		// Mix the last stream2 with the current stream1 and stream2
-		// Make sure we cut off the current stream2, so there's some left to mix into the next window.
-		var windowOutput chan Sample
+// FilterProcessor returns a Processor that passes its input through f.
+func FilterProcessor(f Filter, in Processor) (p Processor) {
+	return ProcessorFunc(in.Format(), func(buf []Sample) (n int) {
+		n = in.Process(buf)
 
-		if lastStream2 == nil {
-			windowOutput = GoMix(stream1, GoCopyFor(windowSize, stream2))
-		} else {
-			windowOutput = GoMix(stream1, GoCopyFor(windowSize, stream2), lastStream2)
+		for i, sample := range buf[:n] {
+			buf[i] = f.Filter(sample)
 		}
 
-		go Append(out, windowOutput)
-		lastStream2 = stream2
+		return n
+	})
+}
+
+func ApplyFilter(f Filter, in chan Sample, out chan Sample) {
+	defer close(out)
+
+	for sample := range in {
+		out <- f.Filter(sample)
+	}
+}
+
+func GoApplyFilter(f Filter, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go ApplyFilter(f, in, out)
+	return out
+}
+
+// BiquadType selects the response of a Biquad filter.
+type BiquadType int
+
+const (
+	BiquadLowPass   BiquadType = iota // Passes frequencies below Freq
+	BiquadHighPass                    // Passes frequencies above Freq
+	BiquadBandPass                    // Passes frequencies around Freq
+	BiquadNotch                       // Removes frequencies around Freq
+	BiquadPeak                        // Boosts or cuts frequencies around Freq by Gain
+	BiquadLowShelf                    // Boosts or cuts frequencies below Freq by Gain
+	BiquadHighShelf                   // Boosts or cuts frequencies above Freq by Gain
+)
+
+// DefaultQ is a Q that gives low-pass and high-pass filters a flat passband with no resonant peak.
+const DefaultQ = 0.7071
+
+// Biquad is a second-order filter, using the designs from Robert Bristow-Johnson's "Audio EQ
+// Cookbook". Q controls the width of the band-pass, notch and peak filters, the resonance of the
+// low-pass and high-pass filters and the steepness of the shelves. Gain, in decibels, is only used
+// by the peak and shelf filters.
+type Biquad struct {
+	Type BiquadType
+	Freq float64
+	Q    float64
+	Gain float64
+
+	format             audio.Format
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     Sample
+}
+
+func NewBiquad(format audio.Format, typ BiquadType, freq float64, q float64, gain float64) (f *Biquad) {
+	f = &Biquad{Type: typ, Freq: freq, Q: q, Gain: gain, format: format}
+	f.Update()
+	return f
+}
+
+// SetFreq changes the filter's frequency.
+func (f *Biquad) SetFreq(freq float64) {
+	f.Freq = freq
+	f.Update()
+}
+
+// Update recalculates the filter's coefficients. It must be called after changing Type, Freq, Q or
+// Gain directly.
+func (f *Biquad) Update() {
+	// Keep the frequency below Nyquist, where the designs break down.
+	freq := math.Min(f.Freq, f.format.SampleRate*0.49)
+
+	w0 := 2.0 * math.Pi * freq / f.format.SampleRate
+	cos, sin := math.Cos(w0), math.Sin(w0)
+	alpha := sin / (2.0 * f.Q)
+	A := math.Pow(10.0, f.Gain/40.0)
+	sq := 2.0 * math.Sqrt(A) * alpha
+
+	var b0, b1, b2, a0, a1, a2 float64
+
+	switch f.Type {
+	case BiquadLowPass:
+		b0, b1, b2 = (1.0-cos)/2.0, 1.0-cos, (1.0-cos)/2.0
+		a0, a1, a2 = 1.0+alpha, -2.0*cos, 1.0-alpha
+	case BiquadHighPass:
+		b0, b1, b2 = (1.0+cos)/2.0, -(1.0 + cos), (1.0+cos)/2.0
+		a0, a1, a2 = 1.0+alpha, -2.0*cos, 1.0-alpha
+	case BiquadBandPass:
+		b0, b1, b2 = alpha, 0.0, -alpha
+		a0, a1, a2 = 1.0+alpha, -2.0*cos, 1.0-alpha
+	case BiquadNotch:
+		b0, b1, b2 = 1.0, -2.0*cos, 1.0
+		a0, a1, a2 = 1.0+alpha, -2.0*cos, 1.0-alpha
+	case BiquadPeak:
+		b0, b1, b2 = 1.0+alpha*A, -2.0*cos, 1.0-alpha*A
+		a0, a1, a2 = 1.0+alpha/A, -2.0*cos, 1.0-alpha/A
+	case BiquadLowShelf:
+		b0 = A * ((A + 1.0) - (A-1.0)*cos + sq)
+		b1 = 2.0 * A * ((A - 1.0) - (A+1.0)*cos)
+		b2 = A * ((A + 1.0) - (A-1.0)*cos - sq)
+		a0 = (A + 1.0) + (A-1.0)*cos + sq
+		a1 = -2.0 * ((A - 1.0) + (A+1.0)*cos)
+		a2 = (A + 1.0) + (A-1.0)*cos - sq
+	case BiquadHighShelf:
+		b0 = A * ((A + 1.0) + (A-1.0)*cos + sq)
+		b1 = -2.0 * A * ((A - 1.0) + (A+1.0)*cos)
+		b2 = A * ((A + 1.0) + (A-1.0)*cos - sq)
+		a0 = (A + 1.0) - (A-1.0)*cos + sq
+		a1 = 2.0 * ((A - 1.0) - (A+1.0)*cos)
+		a2 = (A + 1.0) - (A-1.0)*cos - sq
+	}
+
+	f.b0, f.b1, f.b2 = b0/a0, b1/a0, b2/a0
+	f.a1, f.a2 = a1/a0, a2/a0
+}
+
+func (f *Biquad) Filter(in Sample) (out Sample) {
+	out = in.Mul(f.b0).Add(f.x1.Mul(f.b1)).Add(f.x2.Mul(f.b2)).Sub(f.y1.Mul(f.a1)).Sub(f.y2.Mul(f.a2))
+
+	f.x2, f.x1 = f.x1, in
+	f.y2, f.y1 = f.y1, out
+	return out
+}
+
+func LowPass(format audio.Format, freq float64, q float64, in chan Sample, out chan Sample) {
+	ApplyFilter(NewBiquad(format, BiquadLowPass, freq, q, 0), in, out)
+}
+
+func GoLowPass(format audio.Format, freq float64, q float64, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go LowPass(format, freq, q, in, out)
+	return out
+}
+
+func HighPass(format audio.Format, freq float64, q float64, in chan Sample, out chan Sample) {
+	ApplyFilter(NewBiquad(format, BiquadHighPass, freq, q, 0), in, out)
+}
+
+func GoHighPass(format audio.Format, freq float64, q float64, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go HighPass(format, freq, q, in, out)
+	return out
+}
+
+func BandPass(format audio.Format, freq float64, q float64, in chan Sample, out chan Sample) {
+	ApplyFilter(NewBiquad(format, BiquadBandPass, freq, q, 0), in, out)
+}
+
+func GoBandPass(format audio.Format, freq float64, q float64, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go BandPass(format, freq, q, in, out)
+	return out
+}
+
+func Notch(format audio.Format, freq float64, q float64, in chan Sample, out chan Sample) {
+	ApplyFilter(NewBiquad(format, BiquadNotch, freq, q, 0), in, out)
+}
+
+func GoNotch(format audio.Format, freq float64, q float64, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go Notch(format, freq, q, in, out)
+	return out
+}
+
+// LowShelf boosts (or, if gain is negative, cuts) frequencies below freq by gain decibels.
+func LowShelf(format audio.Format, freq float64, gain float64, in chan Sample, out chan Sample) {
+	ApplyFilter(NewBiquad(format, BiquadLowShelf, freq, DefaultQ, gain), in, out)
+}
+
+func GoLowShelf(format audio.Format, freq float64, gain float64, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go LowShelf(format, freq, gain, in, out)
+	return out
+}
+
+// HighShelf boosts (or, if gain is negative, cuts) frequencies above freq by gain decibels.
+func HighShelf(format audio.Format, freq float64, gain float64, in chan Sample, out chan Sample) {
+	ApplyFilter(NewBiquad(format, BiquadHighShelf, freq, DefaultQ, gain), in, out)
+}
+
+func GoHighShelf(format audio.Format, freq float64, gain float64, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go HighShelf(format, freq, gain, in, out)
+	return out
+}
+
+// EQBand is one band of a parametric equalizer. Type is usually BiquadPeak, or BiquadLowShelf or
+// BiquadHighShelf for the lowest and highest bands.
+type EQBand struct {
+	Type BiquadType
+	Freq float64
+	Q    float64
+	Gain float64 // In decibels
+}
+
+// EQ is a parametric equalizer: a chain of Biquads, one per band.
+type EQ struct {
+	Bands []*Biquad
+}
+
+func NewEQ(format audio.Format, bands ...EQBand) (eq *EQ) {
+	eq = new(EQ)
+	for _, band := range bands {
+		eq.Bands = append(eq.Bands, NewBiquad(format, band.Type, band.Freq, band.Q, band.Gain))
+	}
+
+	return eq
+}
+
+func (eq *EQ) Filter(in Sample) (out Sample) {
+	for _, band := range eq.Bands {
+		in = band.Filter(in)
+	}
+
+	return in
+}
+
+func Equalize(format audio.Format, bands []EQBand, in chan Sample, out chan Sample) {
+	ApplyFilter(NewEQ(format, bands...), in, out)
+}
+
+func GoEqualize(format audio.Format, bands []EQBand, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go Equalize(format, bands, in, out)
+	return out
+}
+
+// sweepInterval is the number of samples between updates of a Sweep's coefficients.
+const sweepInterval = 16
+
+// Sweep is a Biquad whose frequency is swept between Min and Max by a low-frequency oscillator
+// running at Rate Hz, as in an auto-wah. The sweep is exponential, so that it sounds even.
+type Sweep struct {
+	*Biquad
+	Min      float64
+	Max      float64
+	Rate     float64
+	Waveform Waveform
+
+	phase float64
+	count int
+}
+
+func NewSweep(format audio.Format, typ BiquadType, min float64, max float64, q float64, rate float64) (s *Sweep) {
+	return &Sweep{
+		Biquad:   NewBiquad(format, typ, min, q, 0),
+		Min:      min,
+		Max:      max,
+		Rate:     rate,
+		Waveform: SineWave,
+	}
+}
+
+func (s *Sweep) Filter(in Sample) (out Sample) {
+	if s.count == 0 {
+		y := (s.Waveform(s.phase) + 1.0) / 2.0
+		s.SetFreq(s.Min * math.Pow(s.Max/s.Min, y))
+		s.count = sweepInterval
+	}
+
+	s.count--
+
+	s.phase += s.Rate / s.format.SampleRate
+	if s.phase >= 1.0 {
+		s.phase -= 1.0
+	}
+
+	return s.Biquad.Filter(in)
+}
+
+// SweepFilter passes the samples from in through a filter of the given type whose frequency is
+// swept between min and max at rate Hz.
+func SweepFilter(format audio.Format, typ BiquadType, min float64, max float64, q float64, rate float64, in chan Sample, out chan Sample) {
+	ApplyFilter(NewSweep(format, typ, min, max, q, rate), in, out)
+}
+
+func GoSweepFilter(format audio.Format, typ BiquadType, min float64, max float64, q float64, rate float64, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go SweepFilter(format, typ, min, max, q, rate, in, out)
+	return out
+}
+
+// decayTail returns how long a signal fed back through a delay of period with the given feedback
+// takes to die away to -60 dB.
+func decayTail(period time.Duration, feedback float64) (d time.Duration) {
+	if feedback <= 0 {
+		return period
+	}
+
+	repeats := math.Ceil(math.Log(0.001) / math.Log(math.Min(feedback, 0.999)))
+	return time.Duration(repeats) * period
+}
+
+// withTail passes the samples from in on to out, followed by tail worth of silence, so that
+// effects with a tail such as echoes have time to die away.
+func withTail(format audio.Format, tail time.Duration, in chan Sample) (out chan Sample) {
+	return GoConcatenate(in, GoSilence(format, tail))
+}
+
+// delayLine is a circular buffer of the last len(buf) samples.
+type delayLine struct {
+	buf []Sample
+	pos int
+}
+
+func newDelayLine(n int) (d *delayLine) {
+	return &delayLine{buf: make([]Sample, max(n, 1))}
+}
+
+// at returns the sample i samples ago, where 1 is the one most recently written.
+func (d *delayLine) at(i int) (sample Sample) {
+	return d.buf[(d.pos-i+len(d.buf)*2)%len(d.buf)]
+}
+
+// interp returns the sample t samples ago, interpolating linearly between samples.
+func (d *delayLine) interp(t float64) (sample Sample) {
+	i := int(t)
+	a, b := d.at(i), d.at(i+1)
+	return a.Add(b.Sub(a).Mul(t - float64(i)))
+}
+
+func (d *delayLine) write(sample Sample) {
+	d.buf[d.pos] = sample
+	d.pos = (d.pos + 1) % len(d.buf)
+}
+
+// EchoFilter repeats its input after a delay, feeding each repeat back in at a lower level. Mix is
+// the proportion of the output that is echo rather than the original.
+type EchoFilter struct {
+	Feedback float64
+	Mix      float64
+
+	delay time.Duration
+	line  *delayLine
+}
+
+func NewEchoFilter(format audio.Format, delay time.Duration, feedback float64, mix float64) (f *EchoFilter) {
+	return &EchoFilter{
+		Feedback: feedback,
+		Mix:      mix,
+		delay:    delay,
+		line:     newDelayLine(format.NumSamples(delay)),
+	}
+}
+
+// Tail returns how long the echoes last after the input ends.
+func (f *EchoFilter) Tail() (d time.Duration) {
+	return decayTail(f.delay, f.Feedback)
+}
+
+func (f *EchoFilter) Filter(in Sample) (out Sample) {
+	echo := f.line.at(len(f.line.buf))
+	f.line.write(in.Add(echo.Mul(f.Feedback)))
+	return in.Mul(1.0 - f.Mix).Add(echo.Mul(f.Mix))
+}
+
+func Echo(format audio.Format, delay time.Duration, feedback float64, mix float64, in chan Sample, out chan Sample) {
+	f := NewEchoFilter(format, delay, feedback, mix)
+	ApplyFilter(f, withTail(format, f.Tail(), in), out)
+}
+
+func GoEcho(format audio.Format, delay time.Duration, feedback float64, mix float64, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go Echo(format, delay, feedback, mix, in, out)
+	return out
+}
+
+// ChorusFilter mixes its input with a copy whose delay is swept between Delay and Delay + Depth
+// by a low-frequency oscillator running at Rate Hz. The oscillators for the two channels are a
+// quarter of a cycle apart, which widens the sound.
+type ChorusFilter struct {
+	Rate float64
+	Mix  float64
+
+	format audio.Format
+	delay  float64 // In samples
+	depth  float64 // In samples
+	phase  float64
+	line   *delayLine
+}
+
+func NewChorusFilter(format audio.Format, delay time.Duration, depth time.Duration, rate float64, mix float64) (f *ChorusFilter) {
+	return &ChorusFilter{
+		Rate:   rate,
+		Mix:    mix,
+		format: format,
+		delay:  delay.Seconds() * format.SampleRate,
+		depth:  depth.Seconds() * format.SampleRate,
+		line:   newDelayLine(format.NumSamples(delay+depth) + 2),
 	}
 }
+
+func (f *ChorusFilter) Filter(in Sample) (out Sample) {
+	f.line.write(in)
+
+	left := f.delay + f.depth*(SineWave(f.phase)+1.0)/2.0
+	right := f.delay + f.depth*(SineWave(math.Mod(f.phase+0.25, 1.0))+1.0)/2.0
+	wet := Sample{f.line.interp(left + 1.0).Left, f.line.interp(right + 1.0).Right}
+
+	f.phase += f.Rate / f.format.SampleRate
+	if f.phase >= 1.0 {
+		f.phase -= 1.0
+	}
+
+	return in.Mul(1.0 - f.Mix).Add(wet.Mul(f.Mix))
+}
+
+func Chorus(format audio.Format, delay time.Duration, depth time.Duration, rate float64, mix float64, in chan Sample, out chan Sample) {
+	ApplyFilter(NewChorusFilter(format, delay, depth, rate, mix), in, out)
+}
+
+func GoChorus(format audio.Format, delay time.Duration, depth time.Duration, rate float64, mix float64, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go Chorus(format, delay, depth, rate, mix, in, out)
+	return out
+}
@@ -0,0 +1,120 @@
+package sound
+
+import (
+	"github.com/kierdavis/go/audio"
+	"math"
+	"testing"
+	"time"
+)
+
+// steadyGain returns the gain of f once it has settled, for a constant input (DC) if alternate is
+// false or for an input alternating between 1 and -1 (the Nyquist frequency) if it is true.
+func steadyGain(f Filter, alternate bool) (gain float64) {
+	x := 1.0
+	var out Sample
+
+	for i := 0; i < 10000; i++ {
+		out = f.Filter(Sample{x, x})
+
+		if alternate {
+			x = -x
+		}
+	}
+
+	return math.Abs(out.Left)
+}
+
+func TestBiquadGain(t *testing.T) {
+	format := audio.CD
+	boost := math.Pow(10.0, 6.0/20.0) // 6 dB
+
+	tests := []struct {
+		name    string
+		typ     BiquadType
+		gain    float64
+		dc      float64
+		nyquist float64
+	}{
+		{"low-pass", BiquadLowPass, 0, 1.0, 0.0},
+		{"high-pass", BiquadHighPass, 0, 0.0, 1.0},
+		{"notch", BiquadNotch, 0, 1.0, 1.0},
+		{"low shelf", BiquadLowShelf, 6, boost, 1.0},
+		{"high shelf", BiquadHighShelf, 6, 1.0, boost},
+	}
+
+	for _, test := range tests {
+		dc := steadyGain(NewBiquad(format, test.typ, 1000, DefaultQ, test.gain), false)
+		if math.Abs(dc-test.dc) > 1e-6 {
+			t.Errorf("%s: gain at DC is %.6f, expected %.6f", test.name, dc, test.dc)
+		}
+
+		nyquist := steadyGain(NewBiquad(format, test.typ, 1000, DefaultQ, test.gain), true)
+		if math.Abs(nyquist-test.nyquist) > 1e-6 {
+			t.Errorf("%s: gain at Nyquist is %.6f, expected %.6f", test.name, nyquist, test.nyquist)
+		}
+	}
+}
+
+// collect reads out until it is closed, failing the test if that takes too long.
+func collect(t *testing.T, out chan Sample) (samples []Sample) {
+	t.Helper()
+
+	timeout := time.After(10 * time.Second)
+
+	for {
+		select {
+		case sample, ok := <-out:
+			if !ok {
+				return samples
+			}
+
+			samples = append(samples, sample)
+
+		case <-timeout:
+			t.Fatalf("output was not closed after %d samples", len(samples))
+		}
+	}
+}
+
+// peak returns the largest absolute sample in samples.
+func peak(samples []Sample) (level float64) {
+	for _, sample := range samples {
+		level = math.Max(level, math.Max(math.Abs(sample.Left), math.Abs(sample.Right)))
+	}
+
+	return level
+}
+
+func TestEchoTail(t *testing.T) {
+	const length = 50 * time.Millisecond
+
+	f := NewEchoFilter(audio.CD, 10*time.Millisecond, 0.5, 0.5)
+	samples := collect(t, GoEcho(audio.CD, 10*time.Millisecond, 0.5, 0.5, GoSine(audio.CD, 440, length)))
+
+	expected := audio.CD.NumSamples(length) + audio.CD.NumSamples(f.Tail())
+	if len(samples) != expected {
+		t.Errorf("output has %d samples, expected %d", len(samples), expected)
+	}
+
+	last := samples[len(samples)-audio.CD.NumSamples(10*time.Millisecond):]
+	if level := peak(last); level > 0.01 {
+		t.Errorf("echo has only died down to %.4f by the end of the output", level)
+	}
+}
+
+func TestReverbTail(t *testing.T) {
+	const length = 50 * time.Millisecond
+
+	f := NewReverbFilter(audio.CD, 0.5, 0.5, 0.5)
+	samples := collect(t, GoReverb(audio.CD, 0.5, 0.5, 0.5, GoSine(audio.CD, 440, length)))
+
+	expected := audio.CD.NumSamples(length) + audio.CD.NumSamples(f.Tail())
+	if len(samples) != expected {
+		t.Errorf("output has %d samples, expected %d", len(samples), expected)
+	}
+
+	last := samples[len(samples)-audio.CD.NumSamples(50*time.Millisecond):]
+	if level := peak(last); level > 0.01 {
+		t.Errorf("reverberation has only died down to %.4f by the end of the output", level)
+	}
+}
@@ -1,13 +1,14 @@
 package sound
 
 import (
+	"github.com/kierdavis/go/audio"
 	"time"
 )
 
 func Mix(out chan Sample, streams ...chan Sample) {
-	defer close(out)
-
+	// Clip closes out once it has passed on everything sent to intermediate.
 	intermediate := make(chan Sample, ChannelBuffer)
+	defer close(intermediate)
 	go Clip(1.0, intermediate, out)
 
 	for {
@@ -60,13 +61,13 @@ func Append(out chan Sample, in chan Sample) {
 	}
 }
 
-func Delay(d time.Duration, in chan Sample, out chan Sample) {
-	Concatenate(out, GoSilence(d), in)
+func Delay(format audio.Format, d time.Duration, in chan Sample, out chan Sample) {
+	Concatenate(out, GoSilence(format, d), in)
 }
 
-func GoDelay(d time.Duration, in chan Sample) (out chan Sample) {
+func GoDelay(format audio.Format, d time.Duration, in chan Sample) (out chan Sample) {
 	out = make(chan Sample, ChannelBuffer)
-	go Delay(d, in, out)
+	go Delay(format, d, in, out)
 	return out
 }
 
@@ -0,0 +1,237 @@
+package sound
+
+import (
+	"github.com/kierdavis/go/audio"
+	"math"
+	"time"
+)
+
+// ResampleTaps is the number of zero crossings of the resampling filter on each side of its
+// centre. More taps give a sharper anti-aliasing filter at the cost of more work per sample.
+var ResampleTaps = 16
+
+// resampleRolloff is the cutoff of the resampling filter, as a proportion of the lower of the two
+// Nyquist frequencies. Keeping it below 1 leaves room for the filter's transition band.
+const resampleRolloff = 0.95
+
+// resampleKaiserBeta sets the shape of the Kaiser window applied to the filter. 8 gives around
+// 80 dB of stopband attenuation.
+const resampleKaiserBeta = 8.0
+
+// kernelResolution is the number of entries in the filter table between zero crossings.
+const kernelResolution = 256
+
+// resampleKernel is a table of the windowed sinc function for ResampleTaps zero crossings.
+type resampleKernel struct {
+	taps  int
+	table []float64
+}
+
+func newResampleKernel(taps int) (k *resampleKernel) {
+	k = &resampleKernel{taps: taps, table: make([]float64, taps*kernelResolution+2)}
+	norm := besselI0(resampleKaiserBeta)
+
+	for i := range k.table {
+		x := float64(i) / kernelResolution
+
+		if x >= float64(taps) {
+			continue
+		}
+
+		sinc := 1.0
+		if x != 0 {
+			sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+		}
+
+		r := x / float64(taps)
+		k.table[i] = sinc * besselI0(resampleKaiserBeta*math.Sqrt(1.0-r*r)) / norm
+	}
+
+	return k
+}
+
+// at returns the value of the kernel x zero crossings from its centre.
+func (k *resampleKernel) at(x float64) (y float64) {
+	x = math.Abs(x) * kernelResolution
+	i := int(x)
+
+	if i >= len(k.table)-1 {
+		return 0.0
+	}
+
+	return k.table[i] + (k.table[i+1]-k.table[i])*(x-float64(i))
+}
+
+// besselI0 returns the zeroth-order modified Bessel function of the first kind at x.
+func besselI0(x float64) (y float64) {
+	y, term := 1.0, 1.0
+
+	for k := 1; term > y*1e-12; k++ {
+		term *= (x / (2.0 * float64(k))) * (x / (2.0 * float64(k)))
+		y += term
+	}
+
+	return y
+}
+
+// resampler implements ResampleProcessor.
+type resampler struct {
+	in     Processor
+	kernel *resampleKernel
+	rate   float64 // The output rate
+	inRate float64 // The input rate
+	step   float64 // The number of input samples per output sample
+	cutoff float64 // The filter's cutoff, relative to the input's Nyquist frequency
+	half   int     // The number of input samples either side of an output sample that affect it
+
+	out     int      // The index of the next output sample
+	hist    []Sample // Input samples, starting from input sample histPos
+	histPos int
+	block   []Sample
+	ended   bool
+	length  int // The number of output samples, once the input has ended
+}
+
+// ResampleProcessor returns a Processor that converts its input to the given sample rate, using a
+// windowed-sinc filter that also removes frequencies too high for the new rate. The cost per output
+// sample is proportional to ResampleTaps, and to the ratio of the rates when reducing the rate.
+// n input samples give floor(n * rate / inRate) output samples.
+func ResampleProcessor(rate float64, in Processor) (p Processor) {
+	inRate := in.Format().SampleRate
+	if rate == inRate {
+		return in
+	}
+
+	r := &resampler{
+		in:     in,
+		kernel: newResampleKernel(ResampleTaps),
+		rate:   rate,
+		inRate: inRate,
+		step:   inRate / rate,
+		cutoff: math.Min(1.0, rate/inRate) * resampleRolloff,
+		block:  make([]Sample, BlockSize),
+	}
+
+	r.half = int(math.Ceil(float64(ResampleTaps)/r.cutoff)) + 1
+	return r
+}
+
+func (r *resampler) Format() (format audio.Format) {
+	return r.in.Format().WithRate(r.rate)
+}
+
+// fill reads input until it holds the samples up to and including index end, or the input ends.
+func (r *resampler) fill(end int) {
+	for !r.ended && r.histPos+len(r.hist) <= end {
+		k := r.in.Process(r.block)
+		r.hist = append(r.hist, r.block[:k]...)
+
+		if k < len(r.block) {
+			r.ended = true
+			r.length = int(math.Floor(float64(r.histPos+len(r.hist)) * r.rate / r.inRate))
+		}
+	}
+}
+
+func (r *resampler) Process(buf []Sample) (n int) {
+	for n < len(buf) {
+		t := float64(r.out) * r.step // The position of the output sample, in input samples
+		centre := int(math.Floor(t))
+		r.fill(centre + r.half)
+
+		if r.ended && r.out >= r.length {
+			return n
+		}
+
+		// Drop input that no later output sample needs.
+		if drop := centre - r.half - r.histPos; drop > len(r.hist)/2 && drop > BlockSize {
+			r.hist = append(r.hist[:0], r.hist[drop:]...)
+			r.histPos += drop
+		}
+
+		var sum Sample
+
+		first := max(centre-r.half, r.histPos)
+		last := min(centre+r.half, r.histPos+len(r.hist)-1)
+
+		for i := first; i <= last; i++ {
+			w := r.kernel.at((t - float64(i)) * r.cutoff)
+			if w != 0 {
+				sum = sum.Add(r.hist[i-r.histPos].Mul(w))
+			}
+		}
+
+		buf[n] = sum.Mul(r.cutoff)
+		n++
+		r.out++
+	}
+
+	return n
+}
+
+// Resample converts a stream of samples at inRate to one at outRate, as ResampleProcessor does.
+func Resample(inRate float64, outRate float64, in chan Sample, out chan Sample) {
+	if inRate == outRate {
+		defer close(out)
+		Append(out, in)
+		return
+	}
+
+	ToChan(ResampleProcessor(outRate, FromChan(audio.Format{SampleRate: inRate, Channels: 2}, in)), out)
+}
+
+func GoResample(inRate float64, outRate float64, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go Resample(inRate, outRate, in, out)
+	return out
+}
+
+// PitchShifter changes the pitch of its input by Ratio without changing its length. It reads the
+// input back through two heads whose delays sweep across a window at a rate that gives the new
+// pitch, crossfading between them so that each jump back to the start of the window is hidden.
+// Longer windows suit lower sounds but smear transients.
+type PitchShifter struct {
+	Ratio float64
+
+	window float64 // In samples
+	phase  float64
+	line   *delayLine
+}
+
+func NewPitchShifter(format audio.Format, ratio float64, window time.Duration) (f *PitchShifter) {
+	n := format.NumSamples(window)
+
+	return &PitchShifter{
+		Ratio:  ratio,
+		window: float64(n),
+		line:   newDelayLine(n + 3),
+	}
+}
+
+func (f *PitchShifter) Filter(in Sample) (out Sample) {
+	f.line.write(in)
+
+	f.phase += (1.0 - f.Ratio) / f.window
+	f.phase -= math.Floor(f.phase)
+
+	for _, offset := range []float64{0.0, 0.5} {
+		p := f.phase + offset
+		p -= math.Floor(p)
+
+		gain := math.Sin(math.Pi * p)
+		out = out.Add(f.line.interp(p*f.window + 1.0).Mul(gain * gain))
+	}
+
+	return out
+}
+
+// PitchShift changes the pitch of the samples from in by ratio without changing their length.
+func PitchShift(format audio.Format, ratio float64, window time.Duration, in chan Sample, out chan Sample) {
+	ApplyFilter(NewPitchShifter(format, ratio, window), in, out)
+}
+
+func GoPitchShift(format audio.Format, ratio float64, window time.Duration, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go PitchShift(format, ratio, window, in, out)
+	return out
+}
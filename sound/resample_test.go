@@ -0,0 +1,30 @@
+package sound
+
+import (
+	"github.com/kierdavis/go/audio"
+	"testing"
+	"time"
+)
+
+func TestResampleLength(t *testing.T) {
+	rates := []struct {
+		in, out float64
+		length  time.Duration
+	}{
+		{44100, 48000, time.Second},
+		{44100, 22050, time.Second},
+		{48000, 44100, time.Second},
+		{44100, 96000, time.Second / 3},
+		{22050, 44100, 0},
+	}
+
+	for _, r := range rates {
+		format := audio.CD.WithRate(r.in)
+		n := format.NumSamples(r.length)
+		expected := int(float64(n) * r.out / r.in)
+
+		if got := len(Render(ResampleProcessor(r.out, SilenceProcessor(format, r.length)))); got != expected {
+			t.Errorf("Resampling %d samples from %.0f to %.0f Hz gave %d, expected %d", n, r.in, r.out, got, expected)
+		}
+	}
+}
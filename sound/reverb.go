@@ -0,0 +1,129 @@
+package sound
+
+import (
+	"github.com/kierdavis/go/audio"
+	"time"
+)
+
+// The delays, in samples at 44.1 kHz, of the comb and all-pass filters in Jezar's Freeverb, and
+// how many samples longer the right channel's are.
+var (
+	freeverbCombs     = []int{1116, 1188, 1277, 1356, 1422, 1491, 1557, 1617}
+	freeverbAllPasses = []int{556, 441, 341, 225}
+	freeverbSpread    = 23
+)
+
+const freeverbGain = 0.015
+
+// comb is a feedback comb filter with a low-pass filter in the feedback path.
+type comb struct {
+	buf      []float64
+	pos      int
+	feedback float64
+	damp     float64
+	store    float64
+}
+
+func (c *comb) filter(in float64) (out float64) {
+	out = c.buf[c.pos]
+	c.store = out*(1.0-c.damp) + c.store*c.damp
+	c.buf[c.pos] = in + c.store*c.feedback
+	c.pos = (c.pos + 1) % len(c.buf)
+	return out
+}
+
+// allPass is a Schroeder all-pass filter.
+type allPass struct {
+	buf []float64
+	pos int
+}
+
+func (a *allPass) filter(in float64) (out float64) {
+	delayed := a.buf[a.pos]
+	out = delayed - in
+	a.buf[a.pos] = in + delayed*0.5
+	a.pos = (a.pos + 1) % len(a.buf)
+	return out
+}
+
+// ReverbFilter is a Freeverb-style algorithmic reverb: for each channel, eight damped comb filters
+// in parallel followed by four all-pass filters in series. RoomSize (0 to 1) sets how long the
+// reverberation lasts, Damping (0 to 1) how quickly its high frequencies die away, and Mix the
+// proportion of the output that is reverberation rather than the original.
+type ReverbFilter struct {
+	RoomSize float64
+	Damping  float64
+	Mix      float64
+
+	format    audio.Format
+	combs     [2][]*comb
+	allPasses [2][]*allPass
+}
+
+func NewReverbFilter(format audio.Format, roomSize float64, damping float64, mix float64) (f *ReverbFilter) {
+	f = &ReverbFilter{RoomSize: roomSize, Damping: damping, Mix: mix, format: format}
+	scale := format.SampleRate / 44100.0
+
+	for ch := 0; ch < 2; ch++ {
+		for _, n := range freeverbCombs {
+			n = int(float64(n+freeverbSpread*ch) * scale)
+			f.combs[ch] = append(f.combs[ch], &comb{buf: make([]float64, max(n, 1))})
+		}
+
+		for _, n := range freeverbAllPasses {
+			n = int(float64(n+freeverbSpread*ch) * scale)
+			f.allPasses[ch] = append(f.allPasses[ch], &allPass{buf: make([]float64, max(n, 1))})
+		}
+	}
+
+	f.Update()
+	return f
+}
+
+// Update applies changes to RoomSize and Damping.
+func (f *ReverbFilter) Update() {
+	for _, combs := range f.combs {
+		for _, c := range combs {
+			c.feedback = f.feedback()
+			c.damp = f.Damping * 0.4
+		}
+	}
+}
+
+func (f *ReverbFilter) feedback() (feedback float64) {
+	return f.RoomSize*0.28 + 0.7
+}
+
+// Tail returns how long the reverberation lasts after the input ends.
+func (f *ReverbFilter) Tail() (d time.Duration) {
+	longest := freeverbCombs[len(freeverbCombs)-1] + freeverbSpread
+	return decayTail(audio.CD.Duration(longest), f.feedback())
+}
+
+func (f *ReverbFilter) Filter(in Sample) (out Sample) {
+	x := (in.Left + in.Right) * freeverbGain
+	var wet [2]float64
+
+	for ch := 0; ch < 2; ch++ {
+		for _, c := range f.combs[ch] {
+			wet[ch] += c.filter(x)
+		}
+
+		for _, a := range f.allPasses[ch] {
+			wet[ch] = a.filter(wet[ch])
+		}
+	}
+
+	return in.Mul(1.0 - f.Mix).Add(Sample{wet[0], wet[1]}.Mul(f.Mix))
+}
+
+func Reverb(format audio.Format, roomSize float64, damping float64, mix float64, in chan Sample, out chan Sample) {
+	f := NewReverbFilter(format, roomSize, damping, mix)
+	ApplyFilter(f, withTail(format, f.Tail(), in), out)
+}
+
+func GoReverb(format audio.Format, roomSize float64, damping float64, mix float64, in chan Sample) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go Reverb(format, roomSize, damping, mix, in, out)
+	return out
+}
@@ -0,0 +1,208 @@
+package sound
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kierdavis/go/audio"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Sink plays audio as it is produced. Write blocks until the sink has accepted the samples, which
+// must be in the sink's format, so a sink paces whatever is feeding it.
+type Sink interface {
+	Format() (format audio.Format)
+	Write(samples []Sample) (err error)
+	Close() (err error)
+}
+
+// Play writes the samples from in, which are in format, to sink until in is closed. They are
+// resampled if the sink runs at a different rate. The sink is not closed.
+func Play(sink Sink, format audio.Format, in chan Sample) (err error) {
+	if rate := sink.Format().SampleRate; rate != format.SampleRate {
+		in = GoResample(format.SampleRate, rate, in)
+	}
+
+	return PlayProcessor(sink, FromChan(sink.Format(), in))
+}
+
+// PlayProcessor writes p's output to sink until it ends. p should be in the sink's format. The sink
+// is not closed.
+func PlayProcessor(sink Sink, p Processor) (err error) {
+	buf := make([]Sample, BlockSize)
+
+	for {
+		n := p.Process(buf)
+
+		err = sink.Write(buf[:n])
+		if err != nil {
+			return err
+		}
+
+		if n < len(buf) {
+			return nil
+		}
+	}
+}
+
+// PipeSink writes samples to a stream as raw PCM, encoded as in the data chunk of a WAV file.
+type PipeSink struct {
+	opts WAVOptions
+	w    io.Writer
+	cmd  *exec.Cmd
+	data []byte
+}
+
+// NewPipeSink returns a PipeSink that writes to w. If w is an io.Closer, closing the sink closes
+// it.
+func NewPipeSink(w io.Writer, opts WAVOptions) (sink *PipeSink, err error) {
+	err = opts.check()
+	if err != nil {
+		return nil, err
+	}
+
+	return &PipeSink{opts: opts, w: w}, nil
+}
+
+// NewCommandSink starts a command that plays raw PCM from its standard input, such as aplay, and
+// returns a PipeSink that writes to it. Closing the sink waits for the command to finish.
+func NewCommandSink(opts WAVOptions, name string, args ...string) (sink *PipeSink, err error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	sink, err = NewPipeSink(stdin, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	sink.cmd = cmd
+	return sink, nil
+}
+
+// NewAplaySink plays samples through ALSA's aplay command.
+func NewAplaySink(opts WAVOptions) (sink *PipeSink, err error) {
+	var format string
+
+	switch {
+	case opts.Float:
+		format = "FLOAT_LE"
+	case opts.BitDepth == 8:
+		format = "U8"
+	case opts.BitDepth == 24:
+		format = "S24_3LE"
+	default:
+		format = fmt.Sprintf("S%d_LE", opts.BitDepth)
+	}
+
+	return NewCommandSink(opts, "aplay", "-q", "-t", "raw", "-f", format,
+		"-r", fmt.Sprint(opts.SampleRate), "-c", fmt.Sprint(opts.Channels))
+}
+
+// NewPwPlaySink plays samples through PipeWire's pw-play command.
+func NewPwPlaySink(opts WAVOptions) (sink *PipeSink, err error) {
+	format := fmt.Sprintf("s%d", opts.BitDepth)
+
+	switch {
+	case opts.Float:
+		format = "f32"
+	case opts.BitDepth == 8:
+		format = "u8"
+	}
+
+	return NewCommandSink(opts, "pw-play", "--format", format,
+		"--rate", fmt.Sprint(opts.SampleRate), "--channels", fmt.Sprint(opts.Channels), "-")
+}
+
+// NewDefaultSink plays samples through pw-play if it is installed, or aplay if not.
+func NewDefaultSink(opts WAVOptions) (sink *PipeSink, err error) {
+	if _, err := exec.LookPath("pw-play"); err == nil {
+		return NewPwPlaySink(opts)
+	}
+
+	if _, err := exec.LookPath("aplay"); err == nil {
+		return NewAplaySink(opts)
+	}
+
+	return nil, errors.New("Neither pw-play nor aplay is installed")
+}
+
+func (sink *PipeSink) Format() (format audio.Format) {
+	return sink.opts.Format
+}
+
+func (sink *PipeSink) Write(samples []Sample) (err error) {
+	data := sink.data[:0]
+
+	for _, sample := range samples {
+		if sink.opts.Channels == 1 {
+			data = encodeWAVValue(data, sample.Mono(), sink.opts)
+		} else {
+			data = encodeWAVValue(data, sample.Left, sink.opts)
+			data = encodeWAVValue(data, sample.Right, sink.opts)
+		}
+	}
+
+	sink.data = data
+
+	_, err = sink.w.Write(data)
+	return err
+}
+
+func (sink *PipeSink) Close() (err error) {
+	if closer, ok := sink.w.(io.Closer); ok {
+		err = closer.Close()
+	}
+
+	if sink.cmd != nil {
+		if werr := sink.cmd.Wait(); err == nil {
+			err = werr
+		}
+	}
+
+	return err
+}
+
+// NullSink discards samples, but accepts them no faster than they would be played, so that it can
+// stand in for a real sink when testing.
+type NullSink struct {
+	Samples int // The number of samples written so far
+
+	format audio.Format
+	start  time.Time
+}
+
+func NewNullSink(format audio.Format) (sink *NullSink) {
+	return &NullSink{format: format}
+}
+
+func (sink *NullSink) Format() (format audio.Format) {
+	return sink.format
+}
+
+// Write returns once the samples would have finished playing, measuring from the first Write.
+func (sink *NullSink) Write(samples []Sample) (err error) {
+	if sink.start.IsZero() {
+		sink.start = time.Now()
+	}
+
+	sink.Samples += len(samples)
+	time.Sleep(time.Until(sink.start.Add(sink.format.Duration(sink.Samples))))
+	return nil
+}
+
+func (sink *NullSink) Close() (err error) {
+	return nil
+}
@@ -2,12 +2,8 @@ package sound
 
 import (
 	"io"
-	"time"
 )
 
-const SampleRate = 44100.0
-const SampleTime = time.Second / SampleRate
-
 var ChannelBuffer = 10
 
 type Sample struct {
@@ -0,0 +1,289 @@
+package sound
+
+import (
+	"github.com/kierdavis/go/audio"
+	"github.com/kierdavis/go/musical"
+	"sync"
+	"time"
+)
+
+// Patch describes the sound of a synthesizer voice.
+type Patch struct {
+	Waveform Waveform
+	Envelope Envelope
+	Gain     float64 // The level of a voice played at full velocity
+
+	// If NewFilter is not nil, it is called to create a filter for each voice, which the voice's
+	// output is passed through.
+	NewFilter func(format audio.Format, note musical.Note) (f Filter)
+}
+
+// Voice plays a single note of a Patch. It is a Processor whose output ends once the note has been
+// released and its envelope has finished.
+type Voice struct {
+	Note     musical.Note
+	Velocity float64
+
+	patch  Patch
+	format audio.Format
+	env    *envelopeGen
+	filter Filter
+	phase  float64
+	step   float64
+	gate   int // The number of samples until the note is released, or -1
+}
+
+// NewVoice starts playing note on a new voice. Velocity scales the voice's level, from 0 to 1.
+func NewVoice(format audio.Format, patch Patch, note musical.Note, velocity float64) (v *Voice) {
+	v = &Voice{
+		Note:     note,
+		Velocity: velocity,
+		patch:    patch,
+		format:   format,
+		env:      newEnvelopeGen(format, patch.Envelope),
+		step:     note.Frequency() / format.SampleRate,
+		gate:     -1,
+	}
+
+	if patch.NewFilter != nil {
+		v.filter = patch.NewFilter(format, note)
+	}
+
+	return v
+}
+
+// Release releases the note, starting the release segments of its envelope.
+func (v *Voice) Release() {
+	v.env.release()
+}
+
+// ReleaseAfter arranges for the note to be released after d more of the voice's output.
+func (v *Voice) ReleaseAfter(d time.Duration) {
+	v.gate = v.format.NumSamples(d)
+}
+
+// Released returns whether the note has been released.
+func (v *Voice) Released() (released bool) {
+	return v.env.released
+}
+
+// Done returns whether the voice has finished.
+func (v *Voice) Done() (done bool) {
+	return v.env.done
+}
+
+func (v *Voice) Format() (format audio.Format) {
+	return v.format
+}
+
+func (v *Voice) Process(buf []Sample) (n int) {
+	level := v.patch.Gain * v.Velocity
+
+	for n = range buf {
+		if v.gate == 0 {
+			v.Release()
+		}
+
+		if v.gate >= 0 {
+			v.gate--
+		}
+
+		if v.env.done {
+			return n
+		}
+
+		y := v.patch.Waveform(v.phase) * v.env.next() * level
+		sample := Sample{y, y}
+
+		if v.filter != nil {
+			sample = v.filter.Filter(sample)
+		}
+
+		buf[n] = sample
+
+		v.phase += v.step
+		if v.phase >= 1.0 {
+			v.phase -= 1.0
+		}
+	}
+
+	return len(buf)
+}
+
+// EventType is the type of a synthesizer event.
+type EventType int
+
+const (
+	NoteOn EventType = iota
+	NoteOff
+)
+
+// Event is a note starting or ending. Time is measured from the start of the performance.
+type Event struct {
+	Time     time.Duration
+	Type     EventType
+	Note     musical.Note
+	Velocity float64 // Only used by NoteOn
+}
+
+// Synth is a polyphonic synthesizer that plays a Patch. It is a Processor whose output never ends,
+// so that notes can be played on it as it runs; its methods may be called from any goroutine.
+type Synth struct {
+	Patch     Patch
+	MaxVoices int // The number of notes that can play at once, or 0 for no limit
+
+	format  audio.Format
+	voices  []*Voice // In the order they were started
+	scratch []Sample
+	lock    sync.Mutex
+}
+
+func NewSynth(format audio.Format, patch Patch, maxVoices int) (s *Synth) {
+	return &Synth{Patch: patch, MaxVoices: maxVoices, format: format}
+}
+
+func (s *Synth) Format() (format audio.Format) {
+	return s.format
+}
+
+// NoteOn starts playing note. If MaxVoices notes are already playing, the oldest one is stopped,
+// preferring notes that have been released.
+func (s *Synth) NoteOn(note musical.Note, velocity float64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.noteOn(note, velocity)
+}
+
+func (s *Synth) noteOn(note musical.Note, velocity float64) (v *Voice) {
+	if s.MaxVoices > 0 && len(s.voices) >= s.MaxVoices {
+		steal := 0
+
+		for i, voice := range s.voices {
+			if voice.Released() {
+				steal = i
+				break
+			}
+		}
+
+		s.voices = append(s.voices[:steal], s.voices[steal+1:]...)
+	}
+
+	v = NewVoice(s.format, s.Patch, note, velocity)
+	s.voices = append(s.voices, v)
+	return v
+}
+
+// NoteOff releases every playing instance of note.
+func (s *Synth) NoteOff(note musical.Note) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, voice := range s.voices {
+		if voice.Note == note {
+			voice.Release()
+		}
+	}
+}
+
+// Trigger starts playing note and releases it after gate.
+func (s *Synth) Trigger(note musical.Note, velocity float64, gate time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.noteOn(note, velocity).ReleaseAfter(gate)
+}
+
+// Apply applies an event to the synthesizer immediately, ignoring its Time.
+func (s *Synth) Apply(event Event) {
+	switch event.Type {
+	case NoteOn:
+		s.NoteOn(event.Note, event.Velocity)
+	case NoteOff:
+		s.NoteOff(event.Note)
+	}
+}
+
+// Active returns the number of voices that are still playing.
+func (s *Synth) Active() (n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return len(s.voices)
+}
+
+// Process fills buf with the sum of the voices' output. It always returns len(buf).
+func (s *Synth) Process(buf []Sample) (n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.scratch) < len(buf) {
+		s.scratch = make([]Sample, len(buf))
+	}
+
+	scratch := s.scratch[:len(buf)]
+
+	for i := range buf {
+		buf[i] = Sample{}
+	}
+
+	playing := s.voices[:0]
+
+	for _, voice := range s.voices {
+		k := voice.Process(scratch)
+
+		for i, sample := range scratch[:k] {
+			buf[i] = buf[i].Add(sample)
+		}
+
+		if k == len(buf) {
+			playing = append(playing, voice)
+		}
+	}
+
+	for i := len(playing); i < len(s.voices); i++ {
+		s.voices[i] = nil
+	}
+
+	s.voices = playing
+	return len(buf)
+}
+
+// Play plays events, which must be in order of time, on the synthesizer, sending its output to
+// out. Once the events have run out, it carries on until every voice has finished, so each NoteOn
+// should be followed by a NoteOff for the same note (or the patch's envelope should not sustain).
+func (s *Synth) Play(events []Event, out chan Sample) {
+	defer close(out)
+
+	buf := make([]Sample, BlockSize)
+	pos := 0
+
+	for len(events) > 0 || s.Active() > 0 {
+		n := len(buf)
+
+		for len(events) > 0 {
+			at := s.format.NumSamples(events[0].Time)
+			if at > pos {
+				n = min(n, at-pos)
+				break
+			}
+
+			s.Apply(events[0])
+			events = events[1:]
+		}
+
+		s.Process(buf[:n])
+
+		for _, sample := range buf[:n] {
+			out <- sample
+		}
+
+		pos += n
+	}
+}
+
+func (s *Synth) GoPlay(events []Event) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go s.Play(events, out)
+	return out
+}
@@ -0,0 +1,86 @@
+package sound
+
+import (
+	"github.com/kierdavis/go/musical"
+	"testing"
+	"time"
+)
+
+// flatWave is a waveform that stays at 1, so that a voice's output follows its envelope.
+func flatWave(phase float64) (y float64) {
+	return 1.0
+}
+
+func voiceNotes(s *Synth) (notes []musical.Note) {
+	for _, voice := range s.voices {
+		notes = append(notes, voice.Note)
+	}
+
+	return notes
+}
+
+func TestSynthVoiceStealing(t *testing.T) {
+	patch := Patch{
+		Waveform: flatWave,
+		Envelope: ADSR(10*time.Millisecond, 10*time.Millisecond, 0.5, 20*time.Millisecond),
+		Gain:     1.0,
+	}
+
+	const a, b, c, d = musical.Note(57), musical.Note(59), musical.Note(60), musical.Note(62)
+
+	s := NewSynth(testFormat, patch, 2)
+	s.NoteOn(a, 1.0)
+	s.NoteOn(b, 1.0)
+
+	// With no voice released, the oldest is stolen.
+	s.NoteOn(c, 1.0)
+
+	if notes := voiceNotes(s); len(notes) != 2 || notes[0] != b || notes[1] != c {
+		t.Fatalf("voices are playing %v, expected [%v %v]", notes, b, c)
+	}
+
+	// A released voice is stolen in preference to an older one that is still held.
+	s.NoteOff(c)
+	s.NoteOn(d, 1.0)
+
+	if notes := voiceNotes(s); len(notes) != 2 || notes[0] != b || notes[1] != d {
+		t.Fatalf("voices are playing %v, expected [%v %v]", notes, b, d)
+	}
+
+	// The output is the sum of two voices at their attack level.
+	buf := make([]Sample, 6)
+	s.Process(buf)
+
+	if got := buf[5].Left; got < 0.99 || got > 1.01 {
+		t.Errorf("sample 5 is %.3f, expected 1.0 (two voices at 0.5)", got)
+	}
+}
+
+func TestSynthVoicesFinish(t *testing.T) {
+	patch := Patch{
+		Waveform: flatWave,
+		Envelope: ADSR(10*time.Millisecond, 10*time.Millisecond, 0.5, 20*time.Millisecond),
+		Gain:     1.0,
+	}
+
+	s := NewSynth(testFormat, patch, 0)
+	s.Trigger(musical.Note(57), 1.0, 30*time.Millisecond)
+
+	// 30 samples held and 20 released.
+	buf := make([]Sample, 50)
+	s.Process(buf)
+
+	if s.Active() != 1 {
+		t.Fatalf("voice finished before the end of its release")
+	}
+
+	if got := buf[49].Left; got < 0.02 || got > 0.03 {
+		t.Errorf("last sample of the release is %.3f, expected 0.025", got)
+	}
+
+	s.Process(buf[:1])
+
+	if s.Active() != 0 {
+		t.Errorf("%d voices still active after the end of the release, expected 0", s.Active())
+	}
+}
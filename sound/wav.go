@@ -1,9 +1,20 @@
 package sound
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/kierdavis/go/audio"
 	"io"
+	"math"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
 )
 
 type wavheader struct {
@@ -22,22 +33,60 @@ type wavheader struct {
 	Subchunk2Size uint32
 }
 
-type wavsample struct {
-	Left  int16
-	Right int16
+// wavfmt is the body of a WAV file's "fmt " chunk, without any extension.
+type wavfmt struct {
+	AudioFormat   uint16
+	NumChannels   uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+}
+
+// WAVOptions describes the encoding of the samples in a WAV file. Format.Channels must be 1 or 2.
+type WAVOptions struct {
+	audio.Format
+	BitDepth int  // 8, 16, 24 or 32
+	Float    bool // Whether samples are IEEE floats rather than integers; BitDepth must be 32
 }
 
-func newWavHeader(dataLength uint, numChannels uint, bytesPerSample uint) (header *wavheader) {
+// DefaultWAVOptions are the options used by WriteWAV: 16-bit samples in CD format.
+var DefaultWAVOptions = WAVOptions{Format: audio.CD, BitDepth: 16}
+
+func (opts WAVOptions) check() (err error) {
+	switch {
+	case opts.Float && opts.BitDepth != 32:
+		return fmt.Errorf("WAV: %d-bit float samples are not supported", opts.BitDepth)
+	case opts.BitDepth != 8 && opts.BitDepth != 16 && opts.BitDepth != 24 && opts.BitDepth != 32:
+		return fmt.Errorf("WAV: %d-bit samples are not supported", opts.BitDepth)
+	case opts.Channels != 1 && opts.Channels != 2:
+		return fmt.Errorf("WAV: %d channels are not supported", opts.Channels)
+	case opts.SampleRate <= 0:
+		return fmt.Errorf("WAV: invalid sample rate %g", opts.SampleRate)
+	}
+
+	return nil
+}
+
+func newWavHeader(dataLength uint, opts WAVOptions) (header *wavheader) {
+	bytesPerSample := uint(opts.BitDepth / 8)
+	numChannels := uint(opts.Channels)
+
+	audioFormat := uint16(wavFormatPCM)
+	if opts.Float {
+		audioFormat = wavFormatFloat
+	}
+
 	return &wavheader{
 		ChunkID:       [4]byte{'R', 'I', 'F', 'F'},
 		ChunkSize:     uint32(dataLength + 36),
 		Format:        [4]byte{'W', 'A', 'V', 'E'},
 		Subchunk1ID:   [4]byte{'f', 'm', 't', ' '},
 		Subchunk1Size: 16,
-		AudioFormat:   1,
+		AudioFormat:   audioFormat,
 		NumChannels:   uint16(numChannels),
-		SampleRate:    uint32(SampleRate),
-		ByteRate:      uint32(SampleRate * numChannels * bytesPerSample),
+		SampleRate:    uint32(opts.SampleRate),
+		ByteRate:      uint32(uint(opts.SampleRate) * numChannels * bytesPerSample),
 		BlockAlign:    uint16(numChannels * bytesPerSample),
 		BitsPerSample: uint16(bytesPerSample * 8),
 		Subchunk2ID:   [4]byte{'d', 'a', 't', 'a'},
@@ -45,56 +94,263 @@ func newWavHeader(dataLength uint, numChannels uint, bytesPerSample uint) (heade
 	}
 }
 
-func WriteWAV(w io.Writer, in chan Sample) (err error) {
-	buffer := new(bytes.Buffer)
+// encodeWAVValue appends x, clipped to [-1, 1], to buf in the encoding given by opts.
+func encodeWAVValue(buf []byte, x float64, opts WAVOptions) (result []byte) {
+	x = math.Max(-1.0, math.Min(1.0, x))
 
-	for sample := range in {
-		left := int16(sample.Left * 32767.0)
-		right := int16(sample.Right * 32767.0)
+	if opts.Float {
+		return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(x)))
+	}
 
-		err := binary.Write(buffer, binary.LittleEndian, wavsample{left, right})
-		if err != nil {
-			return err
-		}
+	switch opts.BitDepth {
+	case 8:
+		// 8-bit samples are unsigned, centred on 128.
+		return append(buf, byte(int(x*127.0)+128))
+	case 16:
+		return binary.LittleEndian.AppendUint16(buf, uint16(int16(x*32767.0)))
+	case 24:
+		v := uint32(int32(x * 8388607.0))
+		return append(buf, byte(v), byte(v>>8), byte(v>>16))
 	}
 
-	l := uint(buffer.Len())
+	return binary.LittleEndian.AppendUint32(buf, uint32(int32(x*2147483647.0)))
+}
+
+// decodeWAVValue decodes a single sample value from buf in the encoding given by opts.
+func decodeWAVValue(buf []byte, opts WAVOptions) (x float64) {
+	if opts.Float {
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(buf)))
+	}
+
+	switch opts.BitDepth {
+	case 8:
+		return float64(int(buf[0])-128) / 128.0
+	case 16:
+		return float64(int16(binary.LittleEndian.Uint16(buf))) / 32768.0
+	case 24:
+		v := int32(uint32(buf[0])<<8|uint32(buf[1])<<16|uint32(buf[2])<<24) >> 8
+		return float64(v) / 8388608.0
+	}
+
+	return float64(int32(binary.LittleEndian.Uint32(buf))) / 2147483648.0
+}
 
-	err = binary.Write(w, binary.LittleEndian, newWavHeader(l, 2, 2))
+// WriteWAVOptions writes the samples from in, which are in format, to w as a WAV file encoded as
+// opts describes. If opts.SampleRate differs from format's, the samples are resampled.
+func WriteWAVOptions(w io.Writer, format audio.Format, opts WAVOptions, in chan Sample) (err error) {
+	err = opts.check()
 	if err != nil {
 		return err
 	}
 
-	_, err = io.Copy(w, buffer)
+	if opts.SampleRate != format.SampleRate {
+		in = GoResample(format.SampleRate, opts.SampleRate, in)
+	}
+
+	var data []byte
+
+	for sample := range in {
+		if opts.Channels == 1 {
+			data = encodeWAVValue(data, sample.Mono(), opts)
+		} else {
+			data = encodeWAVValue(data, sample.Left, opts)
+			data = encodeWAVValue(data, sample.Right, opts)
+		}
+	}
+
+	err = binary.Write(w, binary.LittleEndian, newWavHeader(uint(len(data)), opts))
 	if err != nil {
 		return err
 	}
 
-	return nil
+	_, err = w.Write(data)
+	return err
+}
+
+// WAVEncoder returns an Encoder that writes samples at opts.SampleRate to WAV files encoded as opts
+// describes.
+func WAVEncoder(opts WAVOptions) (enc Encoder) {
+	return func(w io.Writer, in chan Sample) error {
+		return WriteWAVOptions(w, opts.Format, opts, in)
+	}
+}
+
+// WriteWAV writes the samples from in, which are in CD format, to w as a 16-bit stereo WAV file.
+func WriteWAV(w io.Writer, in chan Sample) (err error) {
+	return WriteWAVOptions(w, audio.CD, DefaultWAVOptions, in)
 }
 
 func WriteWAVMono(w io.Writer, in chan Sample) (err error) {
-	buffer := new(bytes.Buffer)
+	opts := DefaultWAVOptions
+	opts.Format = opts.Mono()
+	return WriteWAVOptions(w, audio.CD, opts, in)
+}
+
+// ReadWAVHeader reads the header of a WAV file from r, up to the start of the sample data. It
+// returns the encoding of the samples and the length of the data in bytes.
+func ReadWAVHeader(r io.Reader) (opts WAVOptions, dataLength uint32, err error) {
+	var riff struct {
+		ChunkID   [4]byte
+		ChunkSize uint32
+		Format    [4]byte
+	}
+
+	err = binary.Read(r, binary.LittleEndian, &riff)
+	if err != nil {
+		return opts, 0, err
+	}
+
+	if string(riff.ChunkID[:]) != "RIFF" || string(riff.Format[:]) != "WAVE" {
+		return opts, 0, errors.New("WAV: not a RIFF WAVE file")
+	}
+
+	haveFmt := false
+
+	for {
+		var chunk struct {
+			ID   [4]byte
+			Size uint32
+		}
+
+		err = binary.Read(r, binary.LittleEndian, &chunk)
+		if err == io.EOF {
+			return opts, 0, errors.New("WAV: no data chunk")
+		}
 
-	for sample := range in {
-		x := int16(sample.Mono() * 32767.0)
-		err := binary.Write(buffer, binary.LittleEndian, x)
 		if err != nil {
-			return err
+			return opts, 0, err
+		}
+
+		switch string(chunk.ID[:]) {
+		case "fmt ":
+			body := make([]byte, chunk.Size+chunk.Size%2)
+			_, err = io.ReadFull(r, body)
+			if err != nil {
+				return opts, 0, err
+			}
+
+			var f wavfmt
+			err = binary.Read(bytes.NewReader(body), binary.LittleEndian, &f)
+			if err != nil {
+				return opts, 0, errors.New("WAV: fmt chunk is too short")
+			}
+
+			// WAVE_FORMAT_EXTENSIBLE gives the real format in the first two bytes of its subformat
+			// GUID, after cbSize, wValidBitsPerSample and dwChannelMask.
+			if f.AudioFormat == wavFormatExtensible && len(body) >= 26 {
+				f.AudioFormat = binary.LittleEndian.Uint16(body[24:])
+			}
+
+			if f.AudioFormat != wavFormatPCM && f.AudioFormat != wavFormatFloat {
+				return opts, 0, fmt.Errorf("WAV: unsupported audio format 0x%X", f.AudioFormat)
+			}
+
+			opts = WAVOptions{
+				Format:   audio.Format{SampleRate: float64(f.SampleRate), Channels: int(f.NumChannels)},
+				BitDepth: int(f.BitsPerSample),
+				Float:    f.AudioFormat == wavFormatFloat,
+			}
+
+			err = opts.check()
+			if err != nil {
+				return opts, 0, err
+			}
+
+			haveFmt = true
+
+		case "data":
+			if !haveFmt {
+				return opts, 0, errors.New("WAV: data chunk comes before fmt chunk")
+			}
+
+			return opts, chunk.Size, nil
+
+		default:
+			// Skip chunks we don't need, such as LIST and fact. Chunks are padded to an even size.
+			_, err = io.CopyN(io.Discard, r, int64(chunk.Size+chunk.Size%2))
+			if err != nil {
+				return opts, 0, err
+			}
 		}
 	}
+}
 
-	l := uint(buffer.Len())
+// ReadWAV decodes a WAV file from r, sending its samples to out resampled to CD format, as
+// ReadWAVFormat does. ReadWAV is a Decoder.
+func ReadWAV(r io.Reader, out chan Sample) (err error) {
+	return ReadWAVFormat(r, audio.CD, out)
+}
 
-	err = binary.Write(w, binary.LittleEndian, newWavHeader(l, 1, 2))
+// ReadWAVFormat decodes a WAV file from r, sending its samples to out resampled to the rate of
+// format. 8, 16, 24 and 32-bit integer and 32-bit float samples are supported, in mono (which is
+// sent to both channels) or stereo.
+func ReadWAVFormat(r io.Reader, format audio.Format, out chan Sample) (err error) {
+	opts, dataLength, err := ReadWAVHeader(r)
 	if err != nil {
+		close(out)
 		return err
 	}
 
-	_, err = io.Copy(w, buffer)
-	if err != nil {
-		return err
+	data := bufio.NewReader(io.LimitReader(r, int64(dataLength)))
+
+	if opts.SampleRate == format.SampleRate {
+		defer close(out)
+		return readWAVData(data, opts, out)
 	}
 
-	return nil
+	// Resample closes out once the raw samples run out.
+	raw := make(chan Sample, ChannelBuffer)
+	done := make(chan bool)
+
+	go func() {
+		Resample(opts.SampleRate, format.SampleRate, raw, out)
+		close(done)
+	}()
+
+	err = readWAVData(data, opts, raw)
+	close(raw)
+	<-done
+	return err
+}
+
+// readWAVData decodes sample data encoded as opts describes from r and sends it to out. A partial
+// frame at the end of the data is ignored.
+func readWAVData(r io.Reader, opts WAVOptions, out chan Sample) (err error) {
+	width := opts.BitDepth / 8
+	frame := make([]byte, width*opts.Channels)
+
+	for {
+		_, err = io.ReadFull(r, frame)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		left := decodeWAVValue(frame, opts)
+		right := left
+
+		if opts.Channels == 2 {
+			right = decodeWAVValue(frame[width:], opts)
+		}
+
+		out <- Sample{left, right}
+	}
+}
+
+func GoReadWAV(r io.Reader) (out chan Sample, errs chan error) {
+	return GoReadWAVFormat(r, audio.CD)
+}
+
+func GoReadWAVFormat(r io.Reader, format audio.Format) (out chan Sample, errs chan error) {
+	out = make(chan Sample, ChannelBuffer)
+	errs = make(chan error, 1)
+
+	go func() {
+		errs <- ReadWAVFormat(r, format, out)
+	}()
+
+	return out, errs
 }
@@ -0,0 +1,52 @@
+package sound
+
+import (
+	"bytes"
+	"github.com/kierdavis/go/audio"
+	"testing"
+	"time"
+)
+
+func TestWAVFormat(t *testing.T) {
+	const length = 100 * time.Millisecond
+
+	low := audio.Format{SampleRate: 8000, Channels: 2}
+	high := low.WithRate(16000)
+
+	var buf bytes.Buffer
+
+	// Samples generated at 8 kHz are written without resampling.
+	err := WriteWAVOptions(&buf, low, WAVOptions{Format: low, BitDepth: 16}, GoSine(low, 440, length))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts, dataLength, err := ReadWAVHeader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.SampleRate != low.SampleRate {
+		t.Errorf("file has a sample rate of %g, expected %g", opts.SampleRate, low.SampleRate)
+	}
+
+	if n := int(dataLength) / 4; n != low.NumSamples(length) {
+		t.Errorf("file holds %d samples, expected %d", n, low.NumSamples(length))
+	}
+
+	// Reading it at 16 kHz doubles the number of samples.
+	samples, errs := GoReadWAVFormat(bytes.NewReader(buf.Bytes()), high)
+
+	n := 0
+	for _ = range samples {
+		n++
+	}
+
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := high.NumSamples(length); n < expected-2 || n > expected+2 {
+		t.Errorf("read %d samples at 16 kHz, expected about %d", n, expected)
+	}
+}
@@ -1,123 +1,85 @@
 package sound
 
 import (
+	"github.com/kierdavis/go/audio"
 	"math"
 	"time"
 )
 
-func sineInput(freq float64, outFreq float64) (out chan float64) {
-	out = make(chan float64)
-
-	go func() {
-		step := (math.Pi * freq * 2.0) / outFreq
-		x := 0.0
-
-		for {
-			out <- math.Sin(x)
-
-			x += step
-			if x >= math.Pi {
-				x -= math.Pi * 2
-			}
-		}
-	}()
+func Sine(format audio.Format, freq float64, length time.Duration, out chan Sample) {
+	ToChan(SineProcessor(format, freq, length), out)
+}
 
+func GoSine(format audio.Format, freq float64, length time.Duration) (out chan Sample) {
+	out = make(chan Sample, ChannelBuffer)
+	go Sine(format, freq, length, out)
 	return out
 }
 
-func Sine(freq float64, length time.Duration, out chan Sample) {
-	defer close(out)
-
-	ctl := sineInput(freq, SampleRate)
-
-	var t time.Duration
-
-	for t = 0; t < length; t += SampleTime {
-		x := <-ctl
-		out <- Sample{x, x}
-	}
+func Saw(format audio.Format, freq float64, length time.Duration, out chan Sample) {
+	ToChan(SawProcessor(format, freq, length), out)
 }
 
-func GoSine(freq float64, length time.Duration) (out chan Sample) {
+func GoSaw(format audio.Format, freq float64, length time.Duration) (out chan Sample) {
 	out = make(chan Sample, ChannelBuffer)
-	go Sine(freq, length, out)
+	go Saw(format, freq, length, out)
 	return out
 }
 
-func Saw(freq float64, length time.Duration, out chan Sample) {
-	defer close(out)
-
-	step := (2.0 * freq) / 44100.0
-	x := 0.0
-
-	var t time.Duration
-
-	for t = 0; t < length; t += SampleTime {
-		out <- Sample{x, x}
-
-		x += step
-		if x >= 1.0 {
-			x -= 2.0
-		}
-	}
+func Triangle(format audio.Format, freq float64, length time.Duration, out chan Sample) {
+	ToChan(TriangleProcessor(format, freq, length), out)
 }
 
-func GoSaw(freq float64, length time.Duration) (out chan Sample) {
+func GoTriangle(format audio.Format, freq float64, length time.Duration) (out chan Sample) {
 	out = make(chan Sample, ChannelBuffer)
-	go Saw(freq, length, out)
+	go Triangle(format, freq, length, out)
 	return out
 }
 
-func Triangle(freq float64, length time.Duration, out chan Sample) {
-	defer close(out)
-
-	step := (4.0 * freq) / 44100.0
-	x := 0.0
-
-	var t time.Duration
-
-	for t = 0; t < length; t += SampleTime {
-		out <- Sample{x, x}
-
-		x += step
-		if x >= 1.0 || x <= -1.0 {
-			step = -step
-
-			// Reverse the change we made before the if statement, and then go another step
-			// in the new direction.
-			x += step * 2
-		}
-	}
+func Square(format audio.Format, freq float64, length time.Duration, out chan Sample) {
+	ToChan(SquareProcessor(format, freq, length), out)
 }
 
-func GoTriangle(freq float64, length time.Duration) (out chan Sample) {
+func GoSquare(format audio.Format, freq float64, length time.Duration) (out chan Sample) {
 	out = make(chan Sample, ChannelBuffer)
-	go Triangle(freq, length, out)
+	go Square(format, freq, length, out)
 	return out
 }
 
-func Square(freq float64, length time.Duration, out chan Sample) {
-	Clip(1e-6, GoTriangle(freq, length), out)
+func Silence(format audio.Format, length time.Duration, out chan Sample) {
+	ToChan(SilenceProcessor(format, length), out)
 }
 
-func GoSquare(freq float64, length time.Duration) (out chan Sample) {
+func GoSilence(format audio.Format, length time.Duration) (out chan Sample) {
 	out = make(chan Sample, ChannelBuffer)
-	go Square(freq, length, out)
+	go Silence(format, length, out)
 	return out
 }
 
-func Silence(length time.Duration, out chan Sample) {
-	defer close(out)
+// Waveform gives the value of a periodic wave at a point in its cycle, from 0 (inclusive) to 1
+// (exclusive). Waveforms are used by the synthesizer's voices.
+type Waveform func(phase float64) (y float64)
+
+func SineWave(phase float64) (y float64) {
+	return math.Sin(phase * math.Pi * 2.0)
+}
 
-	var t time.Duration
+func SawWave(phase float64) (y float64) {
+	return phase*2.0 - 1.0
+}
 
-	for t = 0; t < length; t += SampleTime {
-		out <- Sample{0.0, 0.0}
+func TriangleWave(phase float64) (y float64) {
+	if phase < 0.5 {
+		return phase*4.0 - 1.0
 	}
+
+	return 3.0 - phase*4.0
 }
 
-func GoSilence(length time.Duration) (out chan Sample) {
-	out = make(chan Sample, ChannelBuffer)
-	go Silence(length, out)
-	return out
+func SquareWave(phase float64) (y float64) {
+	if phase < 0.5 {
+		return 1.0
+	}
+
+	return -1.0
 }